@@ -9,6 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"office-export-server/internal/api"
 	"office-export-server/internal/config"
+	"office-export-server/internal/reload"
+	"office-export-server/internal/service/template"
 )
 
 func main() {
@@ -22,7 +24,7 @@ func main() {
 	}
 
 	// 设置Gin模式
-	if config.GlobalConfig.Log.Level == "debug" {
+	if config.Get().Log.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
@@ -32,10 +34,14 @@ func main() {
 	router := gin.Default()
 
 	// 配置路由
-	api.SetupRoutes(router)
+	templateService := template.NewTemplateService()
+	api.SetupRoutes(router, templateService)
+
+	// 配置文件或模板目录变化、收到SIGHUP时重新加载
+	reload.NewWatcher(*configFile, templateService).Start()
 
 	// 启动服务器
-	serverAddr := fmt.Sprintf("%s:%d", config.GlobalConfig.Server.Host, config.GlobalConfig.Server.Port)
+	serverAddr := fmt.Sprintf("%s:%d", config.Get().Server.Host, config.Get().Server.Port)
 	log.Printf("Office Export Server is starting on %s", serverAddr)
 
 	if err := router.Run(serverAddr); err != nil {
@@ -1,22 +1,30 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 
+	"office-export-server/internal/config"
 	"office-export-server/internal/model"
 	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/preview"
+	"office-export-server/internal/service/template"
 	"github.com/gin-gonic/gin"
 )
 
 // ExportHandler 导出处理器
 type ExportHandler struct {
-	exportService export.ExportService
+	exportService  export.ExportService
+	previewService preview.PreviewService
 }
 
 // NewExportHandler 创建导出处理器实例
-func NewExportHandler(exportService export.ExportService) *ExportHandler {
+func NewExportHandler(exportService export.ExportService, previewService preview.PreviewService) *ExportHandler {
 	return &ExportHandler{
-		exportService: exportService,
+		exportService:  exportService,
+		previewService: previewService,
 	}
 }
 
@@ -42,43 +50,206 @@ func (h *ExportHandler) ExportFile(c *gin.Context) {
 		return
 	}
 
-	// 根据文件类型导出
-	var fileBytes []byte
-	var err error
-	var contentType string
-	var filename string
-
-	switch fileType {
-	case "excel":
-		fileBytes, err = h.exportService.ExportExcel(&req)
-		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
-		filename = "export.xlsx"
-	case "word":
-		fileBytes, err = h.exportService.ExportWord(&req)
-		contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
-		filename = "export.docx"
-	case "pdf":
-		fileBytes, err = h.exportService.ExportPDF(&req)
-		contentType = "application/pdf"
-		filename = "export.pdf"
-	default:
+	h.export(c, fileType, &req)
+}
+
+// ExportBatch 批量导出，返回包含全部文件和 manifest.json 的ZIP
+// 单项失败记录在 manifest.json 中，不影响其他项
+func (h *ExportHandler) ExportBatch(c *gin.Context) {
+	var req model.BatchExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request parameters: " + err.Error(),
+		})
+		return
+	}
+	if maxItems := config.Get().Batch.MaxItems; maxItems > 0 && len(req.Items) > maxItems {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("too many items: %d, at most %d", len(req.Items), maxItems),
+		})
+		return
+	}
+
+	// 边生成边写入响应，响应头发出后无法再返回错误状态
+	c.Header("Content-Disposition", "attachment; filename=\"export.zip\"")
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	manifest, err := h.exportService.ExportBatch(req.Items, c.Writer)
+	if err != nil {
+		log.Printf("批量导出写入失败：%v", err)
+		return
+	}
+	log.Printf("批量导出完成：共%d项，成功%d项，失败%d项", manifest.Total, manifest.Succeeded, manifest.Failed)
+}
+
+// PreviewTemplate 使用模板的示例数据导出预览文件，文件类型通过查询参数 type 指定
+func (h *ExportHandler) PreviewTemplate(c *gin.Context) {
+	fileType := c.Query("type")
+	if fileType == "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "file type is required",
+		})
+		return
+	}
+
+	req := model.ExportRequest{
+		TemplateID: c.Param("id"),
+		DataType:   fileType,
+		Preview:    true,
+	}
+	if format := c.Query("format"); format != "" {
+		h.renderPreview(c, fileType, format, &req)
+		return
+	}
+	h.export(c, fileType, &req)
+}
+
+// renderPreview 返回模板填入示例数据后的预览：png 为首页图片，pdf 为完整文档
+func (h *ExportHandler) renderPreview(c *gin.Context, fileType string, format string, req *model.ExportRequest) {
+	if _, ok := export.LookupFormat(fileType); !ok {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
 			Code:    http.StatusBadRequest,
 			Message: "unsupported file type",
 		})
 		return
 	}
+	if !prepareRequest(c, h.exportService, fileType, req) {
+		return
+	}
 
+	result, err := h.previewService.Render(fileType, format, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "failed to export file: " + err.Error(),
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, preview.ErrUnsupportedFormat), errors.Is(err, export.ErrInvalidData):
+			status = http.StatusBadRequest
+		case errors.Is(err, preview.ErrConverterUnavailable):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, model.ErrorResponse{
+			Code:    status,
+			Message: "failed to render preview: " + err.Error(),
+		})
+		return
+	}
+
+	// 预览内容只随模板和示例数据变化，客户端可凭ETag复用缓存
+	etag := `"` + result.Checksum + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Header("Content-Disposition", "inline; filename=\""+req.TemplateID+"."+format+"\"")
+	c.Data(http.StatusOK, result.ContentType, result.Data)
+}
+
+// export 校验请求数据并导出文件
+func (h *ExportHandler) export(c *gin.Context, fileType string, req *model.ExportRequest) {
+	format, ok := export.LookupFormat(fileType)
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "unsupported file type",
 		})
 		return
 	}
 
+	if !prepareRequest(c, h.exportService, fileType, req) {
+		return
+	}
+
+	if fileType == "excel" && req.Stream {
+		h.exportExcelStream(c, req)
+		return
+	}
+
+	fileBytes, err := h.exportService.Export(fileType, req)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+
 	// 设置响应头
-	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	c.Header("Content-Type", contentType)
-	c.Data(http.StatusOK, contentType, fileBytes)
+	c.Header("Content-Disposition", "attachment; filename=\"export"+format.Extension+"\"")
+	c.Header("Content-Type", format.ContentType)
+	c.Data(http.StatusOK, format.ContentType, fileBytes)
+}
+
+// exportExcelStream 流式导出Excel，工作簿生成完成后直接写入响应，不在内存中缓存整个文件
+func (h *ExportHandler) exportExcelStream(c *gin.Context, req *model.ExportRequest) {
+	file, err := h.exportService.ExportExcelStream(req)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	defer file.Close()
+
+	format, _ := export.LookupFormat("excel")
+	c.Header("Content-Disposition", "attachment; filename=\"export"+format.Extension+"\"")
+	c.Header("Content-Type", format.ContentType)
+	c.Status(http.StatusOK)
+	if _, err := file.WriteTo(c.Writer); err != nil {
+		log.Printf("流式导出写入失败：%v", err)
+	}
+}
+
+// prepareRequest 预览模式下加载示例数据，并按模板声明的Schema校验请求数据
+// 失败时写入错误响应并返回 false
+func prepareRequest(c *gin.Context, exportService export.ExportService, fileType string, req *model.ExportRequest) bool {
+	// 预览模式使用模板的示例数据
+	if req.Preview {
+		if err := exportService.ApplyPreview(fileType, req); err != nil {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Code:    http.StatusNotFound,
+				Message: "failed to load preview data: " + err.Error(),
+			})
+			return false
+		}
+	}
+
+	// 按模板声明的Schema校验请求数据，列出全部字段错误
+	fieldErrors, err := exportService.Validate(fileType, req)
+	if err == template.ErrVersionNotFound {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: fmt.Sprintf("%s: %s version %d", err.Error(), req.TemplateID, req.TemplateVersion),
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to validate request data: " + err.Error(),
+		})
+		return false
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, model.ValidationErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request data",
+			Errors:  fieldErrors,
+		})
+		return false
+	}
+	return true
+}
+
+// exportError 返回导出失败的响应，请求数据不符合导出要求时返回400
+func exportError(c *gin.Context, err error) {
+	if errors.Is(err, export.ErrInvalidData) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: "failed to export file: " + err.Error(),
+	})
 }
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/job"
+)
+
+// JobHandler 异步导出任务处理器
+type JobHandler struct {
+	jobService    job.JobService
+	exportService export.ExportService
+}
+
+// NewJobHandler 创建异步导出任务处理器实例
+func NewJobHandler(jobService job.JobService, exportService export.ExportService) *JobHandler {
+	return &JobHandler{
+		jobService:    jobService,
+		exportService: exportService,
+	}
+}
+
+// CreateJob 提交异步导出任务，文件类型由请求的 data_type 指定
+// 请求数据在提交时校验，校验失败直接返回400，不进入队列；指定 callback_url 时任务完成后回调通知
+func (h *JobHandler) CreateJob(c *gin.Context) {
+	var req model.JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request parameters: " + err.Error(),
+		})
+		return
+	}
+
+	fileType := req.DataType
+	if _, ok := export.LookupFormat(fileType); !ok {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "unsupported file type",
+		})
+		return
+	}
+
+	if !prepareRequest(c, h.exportService, fileType, &req.ExportRequest) {
+		return
+	}
+
+	created, err := h.jobService.Submit(fileType, &req, jobsURL())
+	if err == job.ErrQueueFull {
+		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse{
+			Code:    http.StatusServiceUnavailable,
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, job.ErrInvalidCallbackURL) {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to submit job: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, model.SuccessResponse{
+		Code:    http.StatusAccepted,
+		Message: "success",
+		Data:    created,
+	})
+}
+
+// GetJob 查询任务状态和进度
+func (h *JobHandler) GetJob(c *gin.Context) {
+	found, err := h.jobService.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    found,
+	})
+}
+
+// GetJobResult 下载已完成任务的导出文件，任务未完成时返回409
+func (h *JobHandler) GetJobResult(c *gin.Context) {
+	id := c.Param("id")
+	path, err := h.jobService.ResultPath(id)
+	switch err {
+	case nil:
+	case job.ErrJobNotReady:
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: err.Error(),
+		})
+		return
+	default:
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	found, err := h.jobService.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	format, _ := export.LookupFormat(found.FileType)
+
+	c.Header("Content-Type", format.ContentType)
+	c.FileAttachment(path, "export"+format.Extension)
+}
+
+// jobsURL 任务接口的外部访问地址，由配置的 public_url 生成，未配置时为空
+// 不使用请求的Host和X-Forwarded-Proto，避免客户端伪造回调中的下载地址
+func jobsURL() string {
+	base := strings.TrimSuffix(config.Get().Job.PublicURL, "/")
+	if base == "" {
+		return ""
+	}
+	return base + "/api/v1/jobs"
+}
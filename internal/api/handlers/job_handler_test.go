@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/job"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeExport 导出服务，导出前等待 release 关闭；模板ID为 invalid 时校验失败
+type fakeExport struct {
+	export.ExportService
+	release chan struct{}
+}
+
+func (f *fakeExport) Validate(fileType string, req *model.ExportRequest) ([]model.FieldError, error) {
+	if req.TemplateID == "invalid" {
+		return []model.FieldError{{Field: "data.title", Message: "is required"}}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeExport) Export(fileType string, req *model.ExportRequest) ([]byte, error) {
+	<-f.release
+	return []byte("xlsx"), nil
+}
+
+// jobRouter 只注册任务接口的路由，任务服务使用 exportService 导出
+func jobRouter(t *testing.T, exportService export.ExportService, queueSize int) *gin.Engine {
+	t.Helper()
+	old := config.Get()
+	cfg := *old
+	cfg.Job.Workers = 1
+	cfg.Job.QueueSize = queueSize
+	cfg.Job.ResultDir = t.TempDir()
+	cfg.Job.ResultTTL = time.Hour
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewJobHandler(job.NewJobService(exportService), exportService)
+	router.POST("/api/v1/jobs", h.CreateJob)
+	router.GET("/api/v1/jobs/:id", h.GetJob)
+	router.GET("/api/v1/jobs/:id/result", h.GetJobResult)
+	return router
+}
+
+// serve 发送请求并返回响应
+func serve(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// submitJob 提交任务，返回任务ID
+func submitJob(t *testing.T, router *gin.Engine, templateID string) string {
+	t.Helper()
+	w := serve(router, http.MethodPost, "/api/v1/jobs", `{"template_id":"`+templateID+`","data_type":"excel","data":{}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs = %d %s, want 202", w.Code, w.Body)
+	}
+	var resp struct {
+		Data model.Job `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data.ID
+}
+
+// waitJob 轮询任务接口，等待任务进入 status 状态
+func waitJob(t *testing.T, router *gin.Engine, id string, status model.JobStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var resp struct {
+			Data model.Job `json:"data"`
+		}
+		w := serve(router, http.MethodGet, "/api/v1/jobs/"+id, "")
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, resp.Data.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobHandlerDownload(t *testing.T) {
+	release := make(chan struct{})
+	router := jobRouter(t, &fakeExport{release: release}, 10)
+
+	id := submitJob(t, router, "quote")
+	waitJob(t, router, id, model.JobRunning)
+	if w := serve(router, http.MethodGet, "/api/v1/jobs/"+id+"/result", ""); w.Code != http.StatusConflict {
+		t.Errorf("GET result while running = %d, want 409", w.Code)
+	}
+
+	close(release)
+	waitJob(t, router, id, model.JobSucceeded)
+	w := serve(router, http.MethodGet, "/api/v1/jobs/"+id+"/result", "")
+	if w.Code != http.StatusOK || w.Body.String() != "xlsx" {
+		t.Fatalf("GET result = %d %q, want 200 with the exported file", w.Code, w.Body)
+	}
+	format, _ := export.LookupFormat("excel")
+	if got := w.Header().Get("Content-Type"); got != format.ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, format.ContentType)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "export.xlsx") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	for _, target := range []string{"/api/v1/jobs/missing", "/api/v1/jobs/missing/result"} {
+		if w := serve(router, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, w.Code)
+		}
+	}
+}
+
+func TestJobHandlerRejects(t *testing.T) {
+	// 任务一直执行中，测试结束后不再写入结果目录
+	release := make(chan struct{})
+	router := jobRouter(t, &fakeExport{release: release}, 1)
+
+	if w := serve(router, http.MethodPost, "/api/v1/jobs", `{"template_id":"invalid","data_type":"excel","data":{}}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "data.title") {
+		t.Errorf("POST invalid data = %d %s, want 400 with the field errors", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodPost, "/api/v1/jobs", `{"template_id":"quote","data_type":"csv","data":{}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("POST unsupported type = %d, want 400", w.Code)
+	}
+
+	// 第一个任务执行中，第二个任务占满队列，第三个任务被拒绝
+	first := submitJob(t, router, "quote")
+	waitJob(t, router, first, model.JobRunning)
+	submitJob(t, router, "quote")
+	if w := serve(router, http.MethodPost, "/api/v1/jobs", `{"template_id":"quote","data_type":"excel","data":{}}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST to a full queue = %d %s, want 503", w.Code, w.Body)
+	}
+}
+
+func TestJobHandlerCallbackRequiresPublicURL(t *testing.T) {
+	release := make(chan struct{})
+	router := jobRouter(t, &fakeExport{release: release}, 10)
+	callbacks := make(chan model.JobCallbackPayload, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload model.JobCallbackPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		callbacks <- payload
+	}))
+	defer receiver.Close()
+	body := `{"template_id":"quote","data_type":"excel","data":{},"callback_url":"` + receiver.URL + `"}`
+
+	old := config.Get()
+	cfg := *old
+	cfg.Job.WebhookAllowPrivate = true
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+	if w := serve(router, http.MethodPost, "/api/v1/jobs", body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "job.public_url") {
+		t.Errorf("POST with callback_url and no public_url = %d %s, want 400", w.Code, w.Body)
+	}
+
+	withURL := cfg
+	withURL.Job.PublicURL = "https://export.example.com/"
+	config.Set(&withURL)
+
+	// 下载地址只取自 public_url，不受请求的Host和X-Forwarded-Proto影响
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "gopher")
+	req.Host = "attacker.example.org"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST with callback_url = %d %s, want 202", w.Code, w.Body)
+	}
+	close(release)
+	select {
+	case payload := <-callbacks:
+		if !strings.HasPrefix(payload.DownloadURL, "https://export.example.com/api/v1/jobs/") {
+			t.Errorf("download_url = %q, want it built from public_url", payload.DownloadURL)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+}
@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"office-export-server/internal/config"
 	"office-export-server/internal/model"
 	"office-export-server/internal/service/template"
 )
@@ -20,9 +28,21 @@ func NewTemplateHandler(templateService template.TemplateService) *TemplateHandl
 	}
 }
 
-// GetAllTemplates 获取所有模板信息
+// GetAllTemplates 获取所有模板信息，支持按 type 和 tag（可重复，须全部包含）筛选
 func (h *TemplateHandler) GetAllTemplates(c *gin.Context) {
-	templates, err := h.templateService.GetAllTemplates()
+	filter := model.TemplateFilter{
+		Type: c.Query("type"),
+		Tags: c.QueryArray("tag"),
+	}
+	if filter.Type != "" && filter.Type != "excel" && filter.Type != "word" && filter.Type != "pdf" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "unsupported file type: " + filter.Type,
+		})
+		return
+	}
+
+	templates, err := h.templateService.GetAllTemplates(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
 			Code:    http.StatusInternalServerError,
@@ -31,9 +51,209 @@ func (h *TemplateHandler) GetAllTemplates(c *gin.Context) {
 		return
 	}
 
+	// 缩略图以接口地址返回，前端可直接引用
+	for i, info := range templates {
+		if info.Thumbnail != "" {
+			templates[i].Thumbnail = fmt.Sprintf("%s/%s/thumbnail?type=%s", c.Request.URL.Path, url.PathEscape(info.ID), info.Type)
+		}
+	}
+
 	c.JSON(http.StatusOK, model.SuccessResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    templates,
 	})
 }
+
+// GetThumbnail 返回模板描述文件中声明的缩略图
+func (h *TemplateHandler) GetThumbnail(c *gin.Context) {
+	data, fileName, err := h.templateService.LoadThumbnail(c.Param("id"), c.Query("type"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// CreateTemplate 上传新模板（multipart/form-data），作为版本1发布
+// 表单字段：type、id、name、description；文件：file（模板文件，必填）、schema、sample、styles、meta
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	if !parseUploadForm(c) {
+		return
+	}
+	upload, ok := bindTemplateUpload(c, c.PostForm("id"))
+	if !ok {
+		return
+	}
+
+	version, err := h.templateService.CreateTemplate(upload)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.SuccessResponse{
+		Code:    http.StatusCreated,
+		Message: "success",
+		Data:    version,
+	})
+}
+
+// PublishVersion 发布模板的新版本（multipart/form-data），字段同 CreateTemplate，未上传的文件沿用上一版本
+func (h *TemplateHandler) PublishVersion(c *gin.Context) {
+	if !parseUploadForm(c) {
+		return
+	}
+	upload, ok := bindTemplateUpload(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	version, err := h.templateService.PublishVersion(upload)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    version,
+	})
+}
+
+// DeleteTemplate 删除模板及其全部版本，文件类型通过查询参数 type 指定
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	if err := h.templateService.DeleteTemplate(c.Param("id"), c.Query("type")); err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+	})
+}
+
+// GetVersions 获取模板的全部版本，文件类型通过查询参数 type 指定
+func (h *TemplateHandler) GetVersions(c *gin.Context) {
+	versions, err := h.templateService.ListVersions(c.Param("id"), c.Query("type"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    versions,
+	})
+}
+
+// parseUploadForm 解析上传的表单，请求超过 template.max_upload_size 时返回413
+func parseUploadForm(c *gin.Context) bool {
+	maxSize := config.Get().Template.MaxUploadSize
+	if c.Request.ContentLength > maxSize {
+		uploadTooLarge(c, maxSize)
+		return false
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	if err := c.Request.ParseMultipartForm(maxSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			uploadTooLarge(c, maxSize)
+			return false
+		}
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid upload: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// uploadTooLarge 返回413
+func uploadTooLarge(c *gin.Context, maxSize int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("upload exceeds %d bytes", maxSize),
+	})
+}
+
+// bindTemplateUpload 读取上传的模板表单，调用前须先通过 parseUploadForm 解析表单
+func bindTemplateUpload(c *gin.Context, templateID string) (*model.TemplateUpload, bool) {
+	upload := &model.TemplateUpload{
+		ID:          templateID,
+		Type:        c.PostForm("type"),
+		Name:        c.PostForm("name"),
+		Description: c.PostForm("description"),
+	}
+	files := map[string]*[]byte{
+		"file":   &upload.Template,
+		"schema": &upload.Schema,
+		"sample": &upload.Sample,
+		"styles": &upload.Styles,
+		"meta":   &upload.Meta,
+	}
+	for field, target := range files {
+		data, err := readFormFile(c, field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid upload: " + err.Error(),
+			})
+			return nil, false
+		}
+		*target = data
+	}
+	return upload, true
+}
+
+// readFormFile 读取表单中上传的文件，未上传时返回 nil
+func readFormFile(c *gin.Context, field string) ([]byte, error) {
+	header, err := c.FormFile(field)
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", field, err)
+	}
+	defer file.Close()
+
+	// 表单整体已受 template.max_upload_size 限制，这里按文件声明的大小读取
+	data, err := ioutil.ReadAll(io.LimitReader(file, header.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", field, err)
+	}
+	return data, nil
+}
+
+// templateError 按模板服务的错误类型返回对应的状态码
+func templateError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, template.ErrInvalidTemplate):
+		status = http.StatusBadRequest
+	case errors.Is(err, template.ErrTemplateNotFound), errors.Is(err, template.ErrVersionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, template.ErrTemplateExists):
+		status = http.StatusConflict
+	case errors.Is(err, template.ErrReadOnlyStorage):
+		status = http.StatusForbidden
+	}
+	c.JSON(status, model.ErrorResponse{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
@@ -1,15 +1,23 @@
 package api
 
 import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
 	"office-export-server/internal/api/handlers"
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
 	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/job"
+	"office-export-server/internal/service/preview"
 	"office-export-server/internal/service/template"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes 配置路由
-func SetupRoutes(router *gin.Engine) {
+// SetupRoutes 配置路由，templateService 由调用方创建，以便模板目录变化时重新加载
+func SetupRoutes(router *gin.Engine, templateService template.TemplateService) {
 	// 添加完整的CORS中间件
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -26,12 +34,14 @@ func SetupRoutes(router *gin.Engine) {
 	})
 
 	// 创建服务实例
-	templateService := template.NewTemplateService()
 	exportService := export.NewExportService(templateService)
+	previewService := preview.NewPreviewService(exportService, templateService)
+	jobService := job.NewJobService(exportService)
 
 	// 创建处理器实例
-	exportHandler := handlers.NewExportHandler(exportService)
+	exportHandler := handlers.NewExportHandler(exportService, previewService)
 	templateHandler := handlers.NewTemplateHandler(templateService)
+	jobHandler := handlers.NewJobHandler(jobService, exportService)
 
 	// API分组
 	api := router.Group("/api/v1")
@@ -39,13 +49,28 @@ func SetupRoutes(router *gin.Engine) {
 		// 导出相关路由
 		export := api.Group("/export")
 		{
+			export.POST("/batch", exportHandler.ExportBatch)
 			export.POST("/:type", exportHandler.ExportFile)
 		}
 
+		// 异步导出任务路由
+		jobs := api.Group("/jobs")
+		{
+			jobs.POST("", jobHandler.CreateJob)
+			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.GET("/:id/result", jobHandler.GetJobResult)
+		}
+
 		// 模板相关路由
 		template := api.Group("/templates")
 		{
 			template.GET("", templateHandler.GetAllTemplates)
+			template.POST("", adminAuth(), templateHandler.CreateTemplate)
+			template.PUT("/:id", adminAuth(), templateHandler.PublishVersion)
+			template.DELETE("/:id", adminAuth(), templateHandler.DeleteTemplate)
+			template.GET("/:id/versions", templateHandler.GetVersions)
+			template.GET("/:id/thumbnail", templateHandler.GetThumbnail)
+			template.GET("/:id/preview", exportHandler.PreviewTemplate)
 		}
 
 		// 健康检查
@@ -57,3 +82,29 @@ func SetupRoutes(router *gin.Engine) {
 		})
 	}
 }
+
+// adminAuth 模板管理接口的认证：请求头 Authorization: Bearer <token> 须与 template.admin_token 一致
+// 未配置令牌时拒绝全部管理请求；令牌在每次请求时读取，配置重新加载后立即生效
+func adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Get().Template.AdminToken
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse{
+				Code:    http.StatusForbidden,
+				Message: "template management is disabled: template.admin_token is not set",
+			})
+			return
+		}
+
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="templates"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Code:    http.StatusUnauthorized,
+				Message: "invalid or missing admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,55 +1,284 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config 服务配置结构体
+// 带有 reload:"restart" 标记的配置在服务启动时读取，重新加载配置后需重启服务才能生效
 type Config struct {
 	Server struct {
 		Port int    `yaml:"port"`
 		Host string `yaml:"host"`
-	} `yaml:"server"`
+	} `yaml:"server" reload:"restart"`
 	Template struct {
-		Path string `yaml:"path"`
+		Path    string   `yaml:"path"`    // 本地模板目录，storage 为 fs 时使用
+		Storage string   `yaml:"storage"` // 模板存储：fs（本地目录）、embed（程序内置的模板）、s3（S3兼容的对象存储）
+		S3      S3Config `yaml:"s3"`
+
+		MaxUploadSize int64  `yaml:"max_upload_size"`       // 上传、发布模板请求的大小上限（字节），默认 20MB
+		AdminToken    string `yaml:"admin_token" secret:""` // 上传、发布和删除模板所需的令牌，为空时禁止这些操作
 	} `yaml:"template"`
 	Log struct {
 		Level string `yaml:"level"`
-	} `yaml:"log"`
+	} `yaml:"log" reload:"restart"`
+	Image struct {
+		MaxSize      int64    `yaml:"max_size"`      // 通过URL下载的图片大小上限（字节）
+		AllowedHosts []string `yaml:"allowed_hosts"` // 允许下载图片的主机，为空时不限制，*.example.com 匹配其全部子域名
+		AllowPrivate bool     `yaml:"allow_private"` // 允许从回环、链路本地和内网地址下载图片
+	} `yaml:"image"`
+	Job struct {
+		Workers   int           `yaml:"workers" reload:"restart"`    // 并发执行的导出任务数
+		QueueSize int           `yaml:"queue_size" reload:"restart"` // 排队任务数上限，超出时拒绝提交
+		ResultDir string        `yaml:"result_dir" reload:"restart"` // 导出结果存放目录
+		ResultTTL time.Duration `yaml:"result_ttl" reload:"restart"` // 导出结果保留时长，如 1h
+		PublicURL string        `yaml:"public_url"`                  // 服务的外部访问地址，用于生成回调中的下载地址，为空时不接受带回调的任务
+
+		WebhookMaxAttempts  int           `yaml:"webhook_max_attempts" reload:"restart"` // 回调最多投递次数
+		WebhookBackoff      time.Duration `yaml:"webhook_backoff" reload:"restart"`      // 首次重试的等待时间，之后每次翻倍
+		WebhookTimeout      time.Duration `yaml:"webhook_timeout" reload:"restart"`      // 单次回调请求超时
+		WebhookAllowedHosts []string      `yaml:"webhook_allowed_hosts"`                 // 允许的回调主机，为空时不限制；*.example.com 匹配其全部子域名
+		WebhookAllowPrivate bool          `yaml:"webhook_allow_private"`                 // 允许回调回环、链路本地和内网地址
+	} `yaml:"job"`
+	Batch struct {
+		Workers  int `yaml:"workers"`   // 批量导出时并发生成的文件数
+		MaxItems int `yaml:"max_items"` // 单次批量导出的文件数上限
+	} `yaml:"batch"`
+	Preview struct {
+		Converter  string        `yaml:"converter"`  // LibreOffice可执行文件，用于把Excel、Word转换为PDF
+		Rasterizer string        `yaml:"rasterizer"` // pdftoppm可执行文件，用于把PDF首页渲染为PNG
+		DPI        int           `yaml:"dpi"`        // PNG预览图的分辨率
+		Timeout    time.Duration `yaml:"timeout"`    // 单次转换的超时时间
+	} `yaml:"preview"`
+	Reload struct {
+		Interval time.Duration `yaml:"interval" reload:"restart"` // 检查配置文件和模板目录变化的间隔，为负数时只在收到SIGHUP时重新加载
+	} `yaml:"reload"`
+}
+
+// S3Config S3兼容对象存储的配置
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`             // 服务地址，如 http://minio:9000，为空时使用AWS S3
+	Region    string `yaml:"region"`               // 区域，默认 us-east-1
+	Bucket    string `yaml:"bucket"`               // 存储桶
+	Prefix    string `yaml:"prefix"`               // 模板在存储桶中的目录，如 templates/
+	AccessKey string `yaml:"access_key" secret:""` // 为空时使用环境变量 AWS_ACCESS_KEY_ID
+	SecretKey string `yaml:"secret_key" secret:""` // 为空时使用环境变量 AWS_SECRET_ACCESS_KEY
+	PathStyle bool   `yaml:"path_style"`           // 使用 endpoint/bucket/key 形式的地址，MinIO等通常需要开启
 }
 
-// GlobalConfig 全局配置变量
-var GlobalConfig Config
+// current 当前生效的配置，重新加载时整体替换
+var current atomic.Pointer[Config]
+
+func init() {
+	cfg := &Config{}
+	setDefaults(cfg)
+	current.Store(cfg)
+}
+
+// Get 获取当前生效的配置，返回的配置不可修改
+func Get() *Config {
+	return current.Load()
+}
 
-// LoadConfig 从YAML文件加载配置
+// Set 替换当前生效的配置
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// LoadConfig 从YAML文件加载配置并立即生效，加载失败时保留当前配置
 func LoadConfig(filePath string) error {
-	data, err := ioutil.ReadFile(filePath)
+	cfg, err := ReadConfig(filePath)
 	if err != nil {
-		log.Printf("Failed to read config file: %v", err)
+		log.Printf("Failed to load config file: %v", err)
 		return err
 	}
+	Set(cfg)
+	return nil
+}
 
-	if err := yaml.Unmarshal(data, &GlobalConfig); err != nil {
-		log.Printf("Failed to parse config file: %v", err)
-		return err
+// ReadConfig 读取并校验YAML配置文件，不影响当前生效的配置
+func ReadConfig(filePath string) (*Config, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	// 设置默认值
-	if GlobalConfig.Server.Port == 0 {
-		GlobalConfig.Server.Port = 8080
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	setDefaults(cfg)
+	if err := validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+// setDefaults 设置默认值
+func setDefaults(cfg *Config) {
+	if cfg.Server.Port == 0 {
+		cfg.Server.Port = 8080
+	}
+	if cfg.Server.Host == "" {
+		cfg.Server.Host = "0.0.0.0"
+	}
+	if cfg.Template.Path == "" {
+		cfg.Template.Path = "./templates"
+	}
+	if cfg.Template.Storage == "" {
+		cfg.Template.Storage = "fs"
+	}
+	if cfg.Template.S3.Region == "" {
+		cfg.Template.S3.Region = "us-east-1"
+	}
+	if cfg.Template.MaxUploadSize == 0 {
+		cfg.Template.MaxUploadSize = 20 << 20
+	}
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "info"
+	}
+	if cfg.Image.MaxSize == 0 {
+		cfg.Image.MaxSize = 10 << 20
+	}
+	if cfg.Job.Workers == 0 {
+		cfg.Job.Workers = 4
+	}
+	if cfg.Job.QueueSize == 0 {
+		cfg.Job.QueueSize = 100
 	}
-	if GlobalConfig.Server.Host == "" {
-		GlobalConfig.Server.Host = "0.0.0.0"
+	if cfg.Job.ResultDir == "" {
+		cfg.Job.ResultDir = "./jobs"
 	}
-	if GlobalConfig.Template.Path == "" {
-		GlobalConfig.Template.Path = "./templates"
+	if cfg.Job.ResultTTL == 0 {
+		cfg.Job.ResultTTL = time.Hour
 	}
-	if GlobalConfig.Log.Level == "" {
-		GlobalConfig.Log.Level = "info"
+	if cfg.Job.WebhookMaxAttempts == 0 {
+		cfg.Job.WebhookMaxAttempts = 5
 	}
+	if cfg.Job.WebhookBackoff == 0 {
+		cfg.Job.WebhookBackoff = time.Second
+	}
+	if cfg.Job.WebhookTimeout == 0 {
+		cfg.Job.WebhookTimeout = 10 * time.Second
+	}
+	if cfg.Batch.Workers == 0 {
+		cfg.Batch.Workers = 4
+	}
+	if cfg.Batch.MaxItems == 0 {
+		cfg.Batch.MaxItems = 500
+	}
+	if cfg.Preview.Converter == "" {
+		cfg.Preview.Converter = "soffice"
+	}
+	if cfg.Preview.Rasterizer == "" {
+		cfg.Preview.Rasterizer = "pdftoppm"
+	}
+	if cfg.Preview.DPI == 0 {
+		cfg.Preview.DPI = 96
+	}
+	if cfg.Preview.Timeout == 0 {
+		cfg.Preview.Timeout = time.Minute
+	}
+	if cfg.Reload.Interval == 0 {
+		cfg.Reload.Interval = 2 * time.Second
+	}
+}
 
+// validate 检查配置取值，重新加载时无效的配置不会生效
+func validate(cfg *Config) error {
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		return fmt.Errorf("server.port must be between 1 and 65535")
+	}
+	switch cfg.Template.Storage {
+	case "fs":
+		info, err := os.Stat(cfg.Template.Path)
+		if err != nil {
+			return fmt.Errorf("template.path: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("template.path is not a directory: %s", cfg.Template.Path)
+		}
+	case "embed":
+	case "s3":
+		if cfg.Template.S3.Bucket == "" {
+			return fmt.Errorf("template.s3.bucket is required")
+		}
+	default:
+		return fmt.Errorf("template.storage must be fs, embed or s3")
+	}
+	if cfg.Job.Workers < 0 || cfg.Job.QueueSize < 0 || cfg.Batch.Workers < 0 || cfg.Batch.MaxItems < 0 {
+		return fmt.Errorf("job and batch limits must not be negative")
+	}
+	if cfg.Job.ResultTTL < 0 || cfg.Job.WebhookBackoff < 0 || cfg.Job.WebhookTimeout < 0 || cfg.Preview.Timeout < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if cfg.Image.MaxSize < 0 {
+		return fmt.Errorf("image.max_size must not be negative")
+	}
+	if cfg.Template.MaxUploadSize < 0 {
+		return fmt.Errorf("template.max_upload_size must not be negative")
+	}
+	if cfg.Preview.DPI < 0 {
+		return fmt.Errorf("preview.dpi must not be negative")
+	}
 	return nil
 }
+
+// Change 两份配置之间的一项差异
+type Change struct {
+	Key     string // 配置项，如 batch.workers
+	Old     interface{}
+	New     interface{}
+	Restart bool // 是否需要重启服务才能生效
+}
+
+// String 差异的文本描述，用于日志
+func (c Change) String() string {
+	text := fmt.Sprintf("%s: %v -> %v", c.Key, c.Old, c.New)
+	if c.Restart {
+		text += " (takes effect after restart)"
+	}
+	return text
+}
+
+// Diff 比较两份配置，返回发生变化的配置项
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValue(reflect.ValueOf(*old), reflect.ValueOf(*new), "", false, &changes)
+	return changes
+}
+
+// diffValue 逐个字段比较结构体，配置项名称取自yaml标签
+func diffValue(old, new reflect.Value, prefix string, restart bool, changes *[]Change) {
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fieldRestart := restart || field.Tag.Get("reload") == "restart"
+
+		if field.Type.Kind() == reflect.Struct {
+			diffValue(old.Field(i), new.Field(i), key, fieldRestart, changes)
+			continue
+		}
+		if reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+			continue
+		}
+		change := Change{Key: key, Old: old.Field(i).Interface(), New: new.Field(i).Interface(), Restart: fieldRestart}
+		// 密钥等敏感配置不写入日志
+		if _, ok := field.Tag.Lookup("secret"); ok {
+			change.Old, change.New = "******", "******"
+		}
+		*changes = append(*changes, change)
+	}
+}
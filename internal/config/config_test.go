@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 将 content 写入临时配置文件，返回文件路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadConfigInvalidNotApplied(t *testing.T) {
+	old := Get()
+	t.Cleanup(func() { Set(old) })
+	dir := t.TempDir()
+
+	if err := LoadConfig(writeConfig(t, "server:\n  port: 9090\ntemplate:\n  path: "+dir+"\n")); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	current := Get()
+	if current.Server.Port != 9090 || current.Template.Path != dir || current.Preview.Converter != "soffice" {
+		t.Fatalf("loaded config = %+v, want port 9090 with defaults", current)
+	}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"server:\n  port: 70000\n", "server.port"},
+		{"template:\n  path: " + filepath.Join(dir, "missing") + "\n", "template.path"},
+		{"template:\n  storage: s3\n", "template.s3.bucket is required"},
+		{"template:\n  storage: ftp\n", "template.storage"},
+		{"template:\n  path: " + dir + "\njob:\n  workers: -1\n", "must not be negative"},
+		{"server: [8080\n", "failed to parse config file"},
+	}
+	for _, tt := range tests {
+		err := LoadConfig(writeConfig(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadConfig(%q) error = %v, want %q", tt.content, err, tt.want)
+		}
+		if Get() != current {
+			t.Errorf("LoadConfig(%q) replaced the current config", tt.content)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := *Get()
+	cfg := old
+	cfg.Batch.Workers = old.Batch.Workers + 1
+	cfg.Reload.Interval = old.Reload.Interval * 2
+	cfg.Template.S3.Bucket = "templates"
+	cfg.Template.S3.SecretKey = "secret-key-value"
+	cfg.Template.AdminToken = "admin-token-value"
+
+	changes := make(map[string]Change)
+	for _, change := range Diff(&old, &cfg) {
+		changes[change.Key] = change
+		if text := change.String(); strings.Contains(text, "secret-key-value") || strings.Contains(text, "admin-token-value") {
+			t.Errorf("change %q contains a secret", text)
+		}
+	}
+	if len(changes) != 5 {
+		t.Errorf("Diff = %v, want 5 changes", changes)
+	}
+	if c := changes["template.s3.bucket"]; c.New != "templates" || c.Restart {
+		t.Errorf("template.s3.bucket change = %+v", c)
+	}
+	for _, key := range []string{"template.s3.secret_key", "template.admin_token"} {
+		if c, ok := changes[key]; !ok || c.Old != "******" || c.New != "******" {
+			t.Errorf("%s change = %+v, want masked values", key, c)
+		}
+	}
+	if c := changes["reload.interval"]; !c.Restart {
+		t.Errorf("reload.interval change = %+v, want restart required", c)
+	}
+	if c := changes["batch.workers"]; c.Restart {
+		t.Errorf("batch.workers change = %+v, want applied without restart", c)
+	}
+	if changes := Diff(&old, &old); len(changes) != 0 {
+		t.Errorf("Diff of equal configs = %v", changes)
+	}
+}
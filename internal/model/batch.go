@@ -0,0 +1,32 @@
+package model
+
+// BatchExportRequest 批量导出请求，每一项按各自的 data_type 导出，结果打包为一个ZIP
+type BatchExportRequest struct {
+	Items []BatchExportItem `json:"items" binding:"required,min=1,dive"`
+}
+
+// BatchExportItem 批量导出中的一项
+type BatchExportItem struct {
+	ExportRequest
+	Filename string `json:"filename,omitempty"` // ZIP中的文件名，为空时按序号和模板ID生成，扩展名按文件类型补全
+}
+
+// BatchManifest 批量导出清单，作为 manifest.json 写入ZIP末尾
+type BatchManifest struct {
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Items     []BatchManifestItem `json:"items"`
+}
+
+// BatchManifestItem 批量导出中一项的结果
+type BatchManifestItem struct {
+	Index      int          `json:"index"`
+	Filename   string       `json:"filename"`
+	FileType   string       `json:"file_type"`
+	TemplateID string       `json:"template_id"`
+	Success    bool         `json:"success"`
+	FileSize   int64        `json:"file_size,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"` // 数据校验失败时的字段错误
+}
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// JobStatus 异步导出任务状态
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // 排队中
+	JobRunning   JobStatus = "running"   // 导出中
+	JobSucceeded JobStatus = "succeeded" // 导出完成，可下载结果
+	JobFailed    JobStatus = "failed"    // 导出失败
+)
+
+// Job 异步导出任务
+type Job struct {
+	ID         string       `json:"id"`
+	Status     JobStatus    `json:"status"`
+	Progress   int          `json:"progress"` // 进度百分比 0-100
+	FileType   string       `json:"file_type"`
+	TemplateID string       `json:"template_id"`
+	Error      string       `json:"error,omitempty"`
+	FileSize   int64        `json:"file_size,omitempty"`
+	Checksum   string       `json:"checksum,omitempty"` // 结果文件的SHA-256（十六进制）
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"` // 结果文件过期时间，过期后任务和结果一并删除
+	Callback   *JobCallback `json:"callback,omitempty"`
+}
+
+// JobRequest 异步导出请求，在导出请求的基础上可指定任务完成后的回调地址
+type JobRequest struct {
+	ExportRequest
+	CallbackURL    string `json:"callback_url,omitempty" binding:"omitempty,url,startswith=http"`
+	CallbackSecret string `json:"callback_secret,omitempty"` // 回调签名密钥，为空时不签名
+}
+
+// CallbackStatus 回调投递状态
+type CallbackStatus string
+
+const (
+	CallbackPending   CallbackStatus = "pending"   // 等待任务完成或正在重试
+	CallbackDelivered CallbackStatus = "delivered" // 接收方已返回2xx
+	CallbackFailed    CallbackStatus = "failed"    // 重试次数用尽
+)
+
+// JobCallback 任务完成回调及投递记录
+type JobCallback struct {
+	URL        string             `json:"url"`
+	Status     CallbackStatus     `json:"status"`
+	Deliveries []CallbackDelivery `json:"deliveries,omitempty"`
+}
+
+// CallbackDelivery 一次回调投递记录
+type CallbackDelivery struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// JobCallbackPayload 回调请求体
+type JobCallbackPayload struct {
+	JobID       string    `json:"job_id"`
+	Status      JobStatus `json:"status"`
+	FileType    string    `json:"file_type"`
+	TemplateID  string    `json:"template_id"`
+	FileSize    int64     `json:"file_size,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+	DownloadURL string    `json:"download_url,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
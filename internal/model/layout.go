@@ -0,0 +1,84 @@
+package model
+
+// PDFLayout PDF版式定义，可通过请求的 data.layout 传入，或存放在 templates/pdf/<template_id>.yaml
+// 文本字段均支持 {{field}} 占位符，从请求数据中取值；*_style 字段引用命名样式（见 StyleDef），未设置的属性沿用默认效果
+type PDFLayout struct {
+	Orientation string        `json:"orientation" yaml:"orientation"` // P 纵向（默认），L 横向
+	PageSize    string        `json:"page_size" yaml:"page_size"`     // 默认 A4
+	Fonts       PDFFonts      `json:"fonts" yaml:"fonts"`
+	Header      PDFHeader     `json:"header" yaml:"header"`
+	Cover       *PDFCover     `json:"cover,omitempty" yaml:"cover"`
+	Title       string        `json:"title" yaml:"title"`
+	Info        *PDFInfoGrid  `json:"info,omitempty" yaml:"info"`
+	Table       *PDFTable     `json:"table,omitempty" yaml:"table"`
+	Totals      []PDFTotalRow `json:"totals,omitempty" yaml:"totals"`
+	Footer      PDFFooter     `json:"footer" yaml:"footer"`
+}
+
+// PDFFonts 字体文件（相对模板目录的路径）
+type PDFFonts struct {
+	Regular string `json:"regular" yaml:"regular"`
+	Bold    string `json:"bold" yaml:"bold"`
+}
+
+// PDFHeader 页面顶部的品牌和标语
+type PDFHeader struct {
+	Left  string `json:"left" yaml:"left"`
+	Right string `json:"right" yaml:"right"`
+}
+
+// PDFCover 主视觉图片，图片为空时绘制带文字的占位区域
+type PDFCover struct {
+	Image  string  `json:"image" yaml:"image"`
+	Text   string  `json:"text" yaml:"text"`
+	Height float64 `json:"height" yaml:"height"`
+}
+
+// PDFInfoGrid 键值信息表格
+type PDFInfoGrid struct {
+	Columns    int        `json:"columns" yaml:"columns"` // 每行显示的键值对数量
+	LabelWidth float64    `json:"label_width" yaml:"label_width"`
+	Fields     []PDFField `json:"fields" yaml:"fields"`
+	LabelStyle string     `json:"label_style,omitempty" yaml:"label_style"`
+	ValueStyle string     `json:"value_style,omitempty" yaml:"value_style"`
+}
+
+// PDFField 键值对
+type PDFField struct {
+	Label string `json:"label" yaml:"label"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// PDFTable 明细表格
+type PDFTable struct {
+	Title       string      `json:"title" yaml:"title"`
+	Items       string      `json:"items" yaml:"items"` // 明细数组在请求数据中的字段名
+	Columns     []PDFColumn `json:"columns" yaml:"columns"`
+	HeaderStyle string      `json:"header_style,omitempty" yaml:"header_style"`
+}
+
+// PDFColumn 明细表格列
+type PDFColumn struct {
+	Header string  `json:"header" yaml:"header"`
+	Field  string  `json:"field" yaml:"field"`
+	Width  float64 `json:"width" yaml:"width"`
+	Align  string  `json:"align" yaml:"align"`           // L、C、R
+	Sum    bool    `json:"sum" yaml:"sum"`               // 汇总该列，合计可通过 {{sum.<field>}} 引用
+	Style  string  `json:"style,omitempty" yaml:"style"` // 该列明细单元格的命名样式，设置背景色时不使用交替行背景
+}
+
+// PDFTotalRow 合计行
+type PDFTotalRow struct {
+	Label    string `json:"label" yaml:"label"`
+	Value    string `json:"value" yaml:"value"`
+	When     string `json:"when,omitempty" yaml:"when"` // 字段值成立时才显示该行，如 totals.tax_rate
+	Emphasis bool   `json:"emphasis" yaml:"emphasis"`
+	Style    string `json:"style,omitempty" yaml:"style"`
+}
+
+// PDFFooter 页脚，支持 {{@page}} 当前页码和 {{@pages}} 总页数
+type PDFFooter struct {
+	Left   string `json:"left" yaml:"left"`
+	Center string `json:"center" yaml:"center"`
+	Right  string `json:"right" yaml:"right"`
+}
@@ -4,15 +4,61 @@ package model
 type ExportRequest struct {
 	TemplateID string                 `json:"template_id" binding:"required"`
 	DataType   string                 `json:"data_type" binding:"required"`
-	Data       map[string]interface{} `json:"data" binding:"required"`
+	Data       map[string]interface{} `json:"data" binding:"required_unless=Preview true"`
+	Preview    bool                   `json:"preview,omitempty"` // 使用模板的示例数据预览，data 中的字段会覆盖示例数据
+	Stream     bool                   `json:"stream,omitempty"`  // Excel流式导出，适合数据行很多的表格
+	// TemplateVersion 使用模板的指定版本，为0时使用当前版本
+	TemplateVersion int `json:"template_version,omitempty"`
 }
 
-// SheetData Excel Sheet数据模型
+// SheetData Excel Sheet数据模型（表格模式）
 type SheetData struct {
-	Name    string        `json:"name"`
-	Headers [][]string    `json:"headers"`
+	Name    string          `json:"name"`
+	Headers [][]string      `json:"headers"`
 	Rows    [][]interface{} `json:"rows"`
-	Merges  []MergeRange  `json:"merges,omitempty"`
+	Merges  []MergeRange    `json:"merges,omitempty"`
+	Images  []ImageData     `json:"images,omitempty"`
+	// ApplyStyles 按名称引用命名样式的区域，所有模式的sheet均可使用
+	ApplyStyles *StyleRefs `json:"apply_styles,omitempty"`
+	// ConditionalFormats 和 Validations 在数据填充完成后应用，所有模式的sheet均可使用
+	ConditionalFormats []ConditionalFormat `json:"conditional_formats,omitempty"`
+	Validations        []DataValidation    `json:"validations,omitempty"`
+}
+
+// ConditionalFormat Excel条件格式规则
+// 范围中的 {{@last_row}} 替换为sheet填充后的最后一行，如 "H7:H{{@last_row}}"
+type ConditionalFormat struct {
+	Range      string      `json:"range"`
+	Type       string      `json:"type"`                // cell、formula、data_bar、color_scale、top、bottom、duplicate、unique
+	Criteria   string      `json:"criteria,omitempty"`  // cell 的比较条件：>、<、>=、<=、==、!=、between、not between
+	Value      interface{} `json:"value,omitempty"`     // cell 的比较值，top、bottom 的数量（默认10）
+	MinValue   interface{} `json:"min_value,omitempty"` // between、not between 的下限
+	MaxValue   interface{} `json:"max_value,omitempty"` // between、not between 的上限
+	Formula    string      `json:"formula,omitempty"`   // formula 的条件公式，如 "$H7<0"
+	Style      string      `json:"style,omitempty"`     // 满足条件时应用的命名样式，data_bar 和 color_scale 不使用
+	Color      string      `json:"color,omitempty"`     // data_bar 的条形颜色，默认 #638EC6
+	MinColor   string      `json:"min_color,omitempty"` // color_scale 最小值颜色，默认 #F8696B
+	MidColor   string      `json:"mid_color,omitempty"` // color_scale 中间值颜色，设置时为三色刻度
+	MaxColor   string      `json:"max_color,omitempty"` // color_scale 最大值颜色，默认 #63BE7B
+	Percent    bool        `json:"percent,omitempty"`   // top、bottom 按百分比
+	StopIfTrue bool        `json:"stop_if_true,omitempty"`
+}
+
+// DataValidation Excel数据验证规则，范围同样支持 {{@last_row}}
+type DataValidation struct {
+	Range      string      `json:"range"`
+	Type       string      `json:"type"`                  // list、whole、decimal、text_length、custom
+	Values     []string    `json:"values,omitempty"`      // list 的下拉选项
+	Source     string      `json:"source,omitempty"`      // list 引用的单元格区域，如 "=参数!$A$1:$A$10"，与 values 二选一
+	Operator   string      `json:"operator,omitempty"`    // between（默认）、not_between、equal、not_equal、greater_than、greater_than_or_equal、less_than、less_than_or_equal
+	Value      interface{} `json:"value,omitempty"`       // 单值比较的比较值
+	Min        interface{} `json:"min,omitempty"`         // between、not_between 的下限
+	Max        interface{} `json:"max,omitempty"`         // between、not_between 的上限
+	Formula    string      `json:"formula,omitempty"`     // custom 的校验公式
+	AllowBlank *bool       `json:"allow_blank,omitempty"` // 允许空值，默认 true
+	Prompt     string      `json:"prompt,omitempty"`      // 选中单元格时的提示
+	Error      string      `json:"error,omitempty"`       // 输入无效时的提示，默认为Excel的提示
+	ErrorStyle string      `json:"error_style,omitempty"` // stop（默认，禁止输入）、warning、information
 }
 
 // MergeRange 合并单元格范围
@@ -25,12 +71,12 @@ type MergeRange struct {
 
 // ImageData 图片数据模型
 type ImageData struct {
-	Path     string     `json:"path"`
-	Position Position   `json:"position"`
-	Size     ImageSize  `json:"size,omitempty"`
+	Path     string    `json:"path"`
+	Position Position  `json:"position"`
+	Size     ImageSize `json:"size,omitempty"`
 }
 
-// Position 位置坐标
+// Position 位置坐标，Excel表格模式中为锚定单元格的列号(x)和行号(y)，从0开始
 type Position struct {
 	X float64 `json:"x"`
 	Y float64 `json:"y"`
@@ -44,9 +90,9 @@ type ImageSize struct {
 
 // TableCell PDF表格单元格
 type TableCell struct {
-	Text     string `json:"text"`
-	ColSpan  int    `json:"col_span,omitempty"`
-	RowSpan  int    `json:"row_span,omitempty"`
+	Text    string `json:"text"`
+	ColSpan int    `json:"col_span,omitempty"`
+	RowSpan int    `json:"row_span,omitempty"`
 }
 
 // TableData PDF表格数据
@@ -54,3 +100,13 @@ type TableData struct {
 	Headers [][]TableCell `json:"headers"`
 	Rows    [][]TableCell `json:"rows"`
 }
+
+// Pricing 金额计算配置（data.pricing），费率单位为百分比
+type Pricing struct {
+	Items          string  `json:"items,omitempty"`          // 明细数组字段名
+	PriceField     string  `json:"price_field,omitempty"`    // 单价字段名，默认依次尝试 price、单价、预算价
+	QuantityField  string  `json:"quantity_field,omitempty"` // 数量字段名，默认依次尝试 quantity、数量、工程量
+	DiscountRate   float64 `json:"discount_rate,omitempty"`
+	ServiceFeeRate float64 `json:"service_fee_rate,omitempty"`
+	TaxRate        float64 `json:"tax_rate,omitempty"`
+}
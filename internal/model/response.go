@@ -14,6 +14,14 @@ type TemplateInfo struct {
 	Description string `json:"description"`
 	Type        string `json:"type"`
 	Path        string `json:"path"`
+	Version     int    `json:"version,omitempty"` // 当前版本号，未通过接口上传的模板为0
+
+	// 以下字段来自模板描述文件 <template_id>.meta.yaml
+	Owner          string                 `json:"owner,omitempty"`
+	Tags           []string               `json:"tags,omitempty"`
+	RequiredFields []string               `json:"required_fields,omitempty"`
+	Sample         map[string]interface{} `json:"sample,omitempty"`
+	Thumbnail      string                 `json:"thumbnail,omitempty"` // 缩略图地址
 }
 
 // ErrorResponse 错误响应
@@ -28,3 +36,16 @@ type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// FieldError 字段校验错误，Field 为数据中的字段路径，如 data.items[0].数量
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse 请求数据校验失败响应，列出全部字段错误
+type ValidationErrorResponse struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
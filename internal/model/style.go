@@ -0,0 +1,43 @@
+package model
+
+// StyleDef 命名样式定义，Excel单元格和PDF表格单元格共用同一套样式词汇
+// 可定义在 templates/styles.yaml（所有模板共用）、模板同目录的 <template_id>.styles.yaml，或通过请求的 data.styles 传入，后者按名称覆盖前者
+type StyleDef struct {
+	Font         *StyleFont   `json:"font,omitempty" yaml:"font"`
+	Fill         string       `json:"fill,omitempty" yaml:"fill"` // 背景色，如 #D0CECE
+	Border       *StyleBorder `json:"border,omitempty" yaml:"border"`
+	Align        *StyleAlign  `json:"align,omitempty" yaml:"align"`
+	NumberFormat string       `json:"number_format,omitempty" yaml:"number_format"` // 数字格式，如 #,##0.00（仅Excel）
+}
+
+// StyleFont 字体
+type StyleFont struct {
+	Family    string  `json:"family,omitempty" yaml:"family"` // 字体名称，如 微软雅黑（仅Excel，PDF使用版式注册的字体）
+	Size      float64 `json:"size,omitempty" yaml:"size"`
+	Bold      bool    `json:"bold,omitempty" yaml:"bold"`
+	Italic    bool    `json:"italic,omitempty" yaml:"italic"`       // 仅Excel
+	Underline bool    `json:"underline,omitempty" yaml:"underline"` // 仅Excel
+	Color     string  `json:"color,omitempty" yaml:"color"`
+}
+
+// StyleBorder 边框
+type StyleBorder struct {
+	Style string   `json:"style,omitempty" yaml:"style"` // thin（默认）、medium、thick、dashed、dotted、double
+	Color string   `json:"color,omitempty" yaml:"color"` // 默认 #000000
+	Sides []string `json:"sides,omitempty" yaml:"sides"` // left、top、right、bottom，默认四边
+}
+
+// StyleAlign 对齐方式
+type StyleAlign struct {
+	Horizontal string `json:"horizontal,omitempty" yaml:"horizontal"` // left、center、right
+	Vertical   string `json:"vertical,omitempty" yaml:"vertical"`     // top、center、bottom
+	Wrap       bool   `json:"wrap,omitempty" yaml:"wrap"`             // 自动换行
+}
+
+// StyleRefs Excel sheet中按名称引用命名样式的区域，键为区域、值为样式名
+// 优先级：cells > rows > columns，后者覆盖前者
+type StyleRefs struct {
+	Columns map[string]string `json:"columns,omitempty"` // 列或列范围，如 "G"、"G:H"
+	Rows    map[string]string `json:"rows,omitempty"`    // 行号或行范围，如 "5"、"6:8"
+	Cells   map[string]string `json:"cells,omitempty"`   // 单元格或单元格范围，如 "A1"、"A2:D3"
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// TemplateUpload 上传的模板版本，发布新版本时未上传的文件沿用上一版本
+type TemplateUpload struct {
+	ID          string
+	Type        string // excel、word、pdf
+	Name        string
+	Description string
+	Template    []byte // 模板文件：.xlsx、.docx 或PDF版式 .yaml
+	Schema      []byte // 数据JSON Schema
+	Sample      []byte // 示例数据
+	Styles      []byte // 命名样式，YAML或JSON格式
+	Meta        []byte // 模板描述，YAML格式
+}
+
+// TemplateVersion 模板的一个版本，版本发布后不再修改
+type TemplateVersion struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Version     int       `json:"version"`
+	Name        string    `json:"name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Files       []string  `json:"files"`    // 版本包含的文件，如 quote.xlsx、quote.schema.json
+	Checksum    string    `json:"checksum"` // 模板文件的SHA-256（十六进制）
+	CreatedAt   time.Time `json:"created_at"`
+	Current     bool      `json:"current"` // 是否为当前使用的版本
+}
+
+// TemplateMeta 模板描述文件（<template_id>.meta.yaml），供前端展示和挑选模板
+type TemplateMeta struct {
+	Name           string                 `json:"name,omitempty" yaml:"name"`
+	Description    string                 `json:"description,omitempty" yaml:"description"`
+	Owner          string                 `json:"owner,omitempty" yaml:"owner"`
+	Tags           []string               `json:"tags,omitempty" yaml:"tags"`
+	RequiredFields []string               `json:"required_fields,omitempty" yaml:"required_fields"` // 导出时必须提供的数据字段
+	Sample         map[string]interface{} `json:"sample,omitempty" yaml:"sample"`                   // 示例数据，模板没有 .sample.json 时用于预览
+	Thumbnail      string                 `json:"thumbnail,omitempty" yaml:"thumbnail"`             // 缩略图，相对模板所在目录的路径
+}
+
+// TemplateFilter 模板列表的筛选条件，字段为空时不筛选
+type TemplateFilter struct {
+	Type string   // excel、word、pdf
+	Tags []string // 模板须包含全部标签
+}
@@ -0,0 +1,114 @@
+// Package netutil 访问请求方提供的URL（图片、回调地址）时的限制，防止服务被用来访问内网地址（SSRF）
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenURL URL的协议、主机或解析出的地址不允许访问
+var ErrForbiddenURL = errors.New("url not allowed")
+
+// Policy 允许访问的URL范围
+type Policy struct {
+	AllowPrivate bool     // 允许访问回环、链路本地和内网地址
+	AllowedHosts []string // 允许访问的主机，为空时不限制；*.example.com 匹配其全部子域名
+}
+
+// CheckURL 检查URL：只允许 http 和 https，主机须在允许列表中，IP地址形式的主机不能是内网地址
+// 主机名解析出的地址在建立连接时由 NewClient 返回的客户端检查
+func (p Policy) CheckURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrForbiddenURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be http or https", ErrForbiddenURL)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("%w: missing host", ErrForbiddenURL)
+	}
+	if !p.hostAllowed(host) {
+		return nil, fmt.Errorf("%w: host %s is not in the allowed hosts", ErrForbiddenURL, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !p.AllowPrivate && IsPrivateIP(ip) {
+		return nil, fmt.Errorf("%w: %s is a private address", ErrForbiddenURL, host)
+	}
+	return u, nil
+}
+
+// hostAllowed 判断主机是否在允许列表中
+func (p Policy) hostAllowed(host string) bool {
+	if len(p.AllowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivateIP 判断是否为回环、链路本地（含云服务器元数据地址 169.254.169.254）、内网或未指定地址
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || carrierNAT.Contains(ip)
+}
+
+// carrierNAT 运营商级NAT地址段 100.64.0.0/10
+var carrierNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewClient 创建按 policy 限制访问范围的HTTP客户端，policy 在每次请求时读取，配置重新加载后立即生效
+// 每次连接（包括重定向后的连接）都检查URL和实际连接的IP地址，主机名解析到内网地址时同样拒绝；
+// 为保证检查的是目标地址，客户端不使用 HTTP_PROXY 等代理设置
+func NewClient(timeout time.Duration, policy func() Policy) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if policy().AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || IsPrivateIP(ip) {
+				return fmt.Errorf("%w: %s is a private address", ErrForbiddenURL, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			_, err := policy().CheckURL(req.URL.String())
+			return err
+		},
+	}
+}
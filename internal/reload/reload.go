@@ -0,0 +1,151 @@
+package reload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/service/template"
+)
+
+// Watcher 监视配置文件和模板目录，文件变化或收到SIGHUP时重新加载配置和模板索引
+// 没有使用文件系统通知，而是按 reload.interval 定期比较文件的大小和修改时间；
+// 内置模板不会变化，S3中的模板不定期扫描，修改后发送SIGHUP重新加载
+type Watcher struct {
+	configFile      string
+	templateService template.TemplateService
+
+	mu            sync.Mutex // 串行执行重新加载
+	configStamp   string
+	templateStamp string
+}
+
+// NewWatcher 创建监视器，记录配置文件和模板目录的当前状态
+func NewWatcher(configFile string, templateService template.TemplateService) *Watcher {
+	return &Watcher{
+		configFile:      configFile,
+		templateService: templateService,
+		configStamp:     fileStamp(configFile),
+		templateStamp:   templateStamp(config.Get()),
+	}
+}
+
+// Start 开始监视：定期检查文件变化，并在收到SIGHUP时重新加载
+func (w *Watcher) Start() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Printf("Received SIGHUP, reloading config and templates")
+			w.reload(true, true)
+		}
+	}()
+
+	interval := config.Get().Reload.Interval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			w.check()
+		}
+	}()
+}
+
+// check 比较文件状态，只重新加载发生变化的部分
+func (w *Watcher) check() {
+	w.mu.Lock()
+	configChanged := fileStamp(w.configFile) != w.configStamp
+	templatesChanged := templateStamp(config.Get()) != w.templateStamp
+	w.mu.Unlock()
+
+	if configChanged || templatesChanged {
+		w.reload(configChanged, templatesChanged)
+	}
+}
+
+// reload 重新加载配置和模板索引；配置无效时保留当前配置，服务继续使用原有配置运行
+func (w *Watcher) reload(reloadConfig, reloadTemplates bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// 先记录文件状态再读取，读取期间发生的修改会在下次检查时重新加载
+	if reloadConfig {
+		w.configStamp = fileStamp(w.configFile)
+		if err := w.reloadConfig(); err != nil {
+			log.Printf("Rejected config %s, keeping current settings: %v", w.configFile, err)
+		} else if templateStamp(config.Get()) != w.templateStamp {
+			// 模板目录可能已经切换
+			reloadTemplates = true
+		}
+	}
+
+	if reloadTemplates {
+		w.templateStamp = templateStamp(config.Get())
+		changes, err := w.templateService.Reload()
+		if err != nil {
+			log.Printf("Failed to reload templates, keeping current template index: %v", err)
+			return
+		}
+		for _, change := range changes {
+			log.Printf("Reloaded %s", change)
+		}
+	}
+}
+
+// reloadConfig 读取并校验配置文件，通过后整体替换当前配置
+func (w *Watcher) reloadConfig() error {
+	cfg, err := config.ReadConfig(w.configFile)
+	if err != nil {
+		return err
+	}
+
+	changes := config.Diff(config.Get(), cfg)
+	config.Set(cfg)
+	for _, change := range changes {
+		log.Printf("Config changed: %s", change)
+	}
+	return nil
+}
+
+// fileStamp 文件的大小和修改时间，文件不存在时为空
+func fileStamp(filePath string) string {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// templateStamp 模板存储的状态：本地目录为其中全部文件的摘要，其他存储为模板所在的位置，
+// 不包含访问密钥
+func templateStamp(cfg *config.Config) string {
+	if cfg.Template.Storage == "fs" {
+		return dirStamp(cfg.Template.Path)
+	}
+	s3 := cfg.Template.S3
+	return fmt.Sprintf("%s:%s/%s/%s", cfg.Template.Storage, s3.Endpoint, s3.Bucket, s3.Prefix)
+}
+
+// dirStamp 目录下全部文件的路径、大小和修改时间的摘要
+func dirStamp(dir string) string {
+	h := sha256.New()
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
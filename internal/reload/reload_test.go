@@ -0,0 +1,79 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/service/template"
+)
+
+// fakeTemplates 模板服务，记录重新加载的次数
+type fakeTemplates struct {
+	template.TemplateService
+	reloads int
+}
+
+func (f *fakeTemplates) Reload() ([]string, error) {
+	f.reloads++
+	return nil, nil
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	old := config.Get()
+	t.Cleanup(func() { config.Set(old) })
+	dir := t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("template:\n  path: " + dir + "\nbatch:\n  workers: 2\n")
+	if err := config.LoadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	templates := &fakeTemplates{}
+	w := NewWatcher(configFile, templates)
+
+	write("template:\n  path: " + dir + "\nbatch:\n  workers: -1\n")
+	current := config.Get()
+	w.reload(true, false)
+	if config.Get() != current {
+		t.Errorf("invalid config applied: batch.workers = %d", config.Get().Batch.Workers)
+	}
+
+	write("template:\n  path: " + dir + "\nbatch:\n  workers: 4\n")
+	w.reload(true, false)
+	if workers := config.Get().Batch.Workers; workers != 4 {
+		t.Errorf("batch.workers = %d after reloading a valid config, want 4", workers)
+	}
+	if templates.reloads != 0 {
+		t.Errorf("templates reloaded %d times, want none while the template directory is unchanged", templates.reloads)
+	}
+}
+
+func TestTemplateStampExcludesSecrets(t *testing.T) {
+	cfg := *config.Get()
+	cfg.Template.Storage = "s3"
+	cfg.Template.S3 = config.S3Config{Endpoint: "http://minio:9000", Bucket: "office", Prefix: "templates/", AccessKey: "access-key-value", SecretKey: "secret-key-value"}
+	stamp := templateStamp(&cfg)
+	if strings.Contains(stamp, "access-key-value") || strings.Contains(stamp, "secret-key-value") {
+		t.Errorf("templateStamp = %q, contains credentials", stamp)
+	}
+
+	// 只有模板所在的位置变化时才重新加载模板
+	rotated := cfg
+	rotated.Template.S3.SecretKey = "rotated-secret"
+	if templateStamp(&rotated) != stamp {
+		t.Error("templateStamp changed after rotating the secret key")
+	}
+	moved := cfg
+	moved.Template.S3.Prefix = "office/"
+	if templateStamp(&moved) == stamp {
+		t.Error("templateStamp unchanged after changing the prefix")
+	}
+}
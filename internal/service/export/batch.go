@@ -0,0 +1,178 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+)
+
+// batchManifestName 批量导出清单在ZIP中的文件名
+const batchManifestName = "manifest.json"
+
+// batchResult 批量导出中一项的生成结果
+type batchResult struct {
+	index       int
+	data        []byte
+	err         error
+	fieldErrors []model.FieldError
+}
+
+// ExportBatch 批量导出：以有限的并发逐项生成文件，生成完成的文件依次写入ZIP，最后写入 manifest.json
+// 单项失败只记录在清单中，不影响其他项；返回的错误仅表示ZIP写入失败
+func (s *exportService) ExportBatch(items []model.BatchExportItem, w io.Writer) (*model.BatchManifest, error) {
+	manifest := &model.BatchManifest{
+		Total: len(items),
+		Items: make([]model.BatchManifestItem, len(items)),
+	}
+	used := make(map[string]bool)
+	for i := range items {
+		format, _ := LookupFormat(items[i].DataType)
+		manifest.Items[i] = model.BatchManifestItem{
+			Index:      i,
+			Filename:   batchFilename(i, &items[i], format.Extension, used),
+			FileType:   items[i].DataType,
+			TemplateID: items[i].TemplateID,
+		}
+	}
+
+	workers := config.Get().Batch.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	results := make(chan batchResult)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results <- s.exportBatchItem(i, &items[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range items {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(results)
+	}()
+
+	zw := zip.NewWriter(w)
+	var writeErr error
+	for result := range results {
+		item := &manifest.Items[result.index]
+		if result.err != nil {
+			item.Error = result.err.Error()
+			item.Errors = result.fieldErrors
+			manifest.Failed++
+			continue
+		}
+		item.Success = true
+		item.FileSize = int64(len(result.data))
+		manifest.Succeeded++
+
+		// 写入失败后继续接收剩余结果，保证工作协程全部退出
+		if writeErr == nil {
+			writeErr = writeZipFile(zw, item.Filename, result.data)
+		}
+	}
+	if writeErr != nil {
+		return manifest, writeErr
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := writeZipFile(zw, batchManifestName, raw); err != nil {
+		return manifest, err
+	}
+	if err := zw.Close(); err != nil {
+		return manifest, fmt.Errorf("failed to close zip: %v", err)
+	}
+	return manifest, nil
+}
+
+// exportBatchItem 生成批量导出中的一项：加载预览数据、校验并导出，导出过程中的panic记为该项失败
+func (s *exportService) exportBatchItem(index int, item *model.BatchExportItem) (result batchResult) {
+	result.index = index
+	defer func() {
+		if r := recover(); r != nil {
+			result.err = fmt.Errorf("export panicked: %v", r)
+		}
+	}()
+
+	fileType := item.DataType
+	if _, ok := LookupFormat(fileType); !ok {
+		result.err = fmt.Errorf("unsupported file type: %s", fileType)
+		return
+	}
+	req := &item.ExportRequest
+	if req.Preview {
+		if err := s.ApplyPreview(fileType, req); err != nil {
+			result.err = fmt.Errorf("failed to load preview data: %v", err)
+			return
+		}
+	}
+	fieldErrors, err := s.Validate(fileType, req)
+	if err != nil {
+		result.err = fmt.Errorf("failed to validate request data: %v", err)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		result.err = fmt.Errorf("invalid request data")
+		result.fieldErrors = fieldErrors
+		return
+	}
+
+	result.data, result.err = s.Export(fileType, req)
+	return
+}
+
+// batchFilename 生成ZIP中的文件名：去掉目录部分，补全扩展名，重名时追加序号
+func batchFilename(index int, item *model.BatchExportItem, extension string, used map[string]bool) string {
+	name := path.Base(path.Clean("/" + strings.ReplaceAll(item.Filename, "\\", "/")))
+	if name == "/" || name == "." {
+		name = ""
+	}
+	if name == "" {
+		templateID := item.TemplateID
+		if templateID == "" {
+			templateID = "default"
+		}
+		name = fmt.Sprintf("%03d-%s", index+1, templateID)
+	}
+	if extension != "" && !strings.EqualFold(path.Ext(name), extension) {
+		name += extension
+	}
+
+	base := strings.TrimSuffix(name, path.Ext(name))
+	ext := path.Ext(name)
+	for n := 2; used[strings.ToLower(name)] || strings.EqualFold(name, batchManifestName); n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// writeZipFile 向ZIP写入一个文件
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry %s: %v", name, err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("failed to write zip entry %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportBatch(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/plain.xlsx":         placeholderWorkbook(t, map[string]interface{}{"A1": "{{title}}"}),
+		"excel/titled.xlsx":        placeholderWorkbook(t, map[string]interface{}{"A1": "{{title}}"}),
+		"excel/titled.schema.json": []byte(`{"type": "object", "required": ["title"]}`),
+	})
+	s := NewExportService(template.NewTemplateService())
+
+	// item 导出模板 templateID 的一个sheet，标题为 title
+	item := func(templateID, filename, title string) model.BatchExportItem {
+		sheet := map[string]interface{}{"name": "报价"}
+		if title != "" {
+			sheet["title"] = title
+		}
+		return model.BatchExportItem{
+			ExportRequest: model.ExportRequest{TemplateID: templateID, DataType: "excel", Data: map[string]interface{}{"sheets": []interface{}{sheet}}},
+			Filename:      filename,
+		}
+	}
+	items := []model.BatchExportItem{
+		item("plain", "../reports/报价", "一号"),
+		item("plain", "报价.XLSX", "二号"),
+		item("missing", "", "三号"),
+		item("titled", "", ""),
+		item("plain", "", "五号"),
+	}
+
+	var buf bytes.Buffer
+	manifest, err := s.ExportBatch(items, &buf)
+	if err != nil {
+		t.Fatalf("ExportBatch: %v", err)
+	}
+	if manifest.Total != 5 || manifest.Succeeded != 3 || manifest.Failed != 2 {
+		t.Errorf("manifest total/succeeded/failed = %d/%d/%d, want 5/3/2", manifest.Total, manifest.Succeeded, manifest.Failed)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = data
+	}
+	if len(entries) != 4 {
+		t.Errorf("zip entries = %d, want 3 files and manifest.json", len(entries))
+	}
+	for name, title := range map[string]string{"报价.xlsx": "一号", "报价-2.XLSX": "二号", "005-plain.xlsx": "五号"} {
+		f, err := excelize.OpenReader(bytes.NewReader(entries[name]))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, _ := f.GetCellValue("报价", "A1"); got != title {
+			t.Errorf("%s: A1 = %q, want %q", name, got, title)
+		}
+		f.Close()
+	}
+
+	var written model.BatchManifest
+	if err := json.Unmarshal(entries["manifest.json"], &written); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if !reflect.DeepEqual(&written, manifest) {
+		t.Errorf("manifest.json = %+v, want %+v", written, manifest)
+	}
+	failed := written.Items[2]
+	if failed.Success || failed.Filename != "003-missing.xlsx" || failed.Error == "" {
+		t.Errorf("failed item = %+v, want the export error", failed)
+	}
+	invalid := written.Items[3]
+	if invalid.Success || invalid.Error != "invalid request data" || len(invalid.Errors) != 1 || invalid.Errors[0].Field != "data.sheets[0].title" {
+		t.Errorf("invalid item = %+v, want the field errors", invalid)
+	}
+	if ok := written.Items[0]; !ok.Success || ok.FileSize != int64(len(entries["报价.xlsx"])) {
+		t.Errorf("first item = %+v, want success with the file size", ok)
+	}
+}
+
+func TestBatchFilename(t *testing.T) {
+	used := make(map[string]bool)
+	tests := []struct {
+		filename   string
+		templateID string
+		extension  string
+		want       string
+	}{
+		{"../../etc/passwd", "quote", ".xlsx", "passwd.xlsx"},
+		{`C:\reports\报价.docx`, "quote", ".docx", "报价.docx"},
+		{"报价.DOCX", "quote", ".docx", "报价-2.DOCX"},
+		{"报价", "quote", ".docx", "报价-3.docx"},
+		{"/", "", ".pdf", "005-default.pdf"},
+		{"", "order", ".xlsx", "006-order.xlsx"},
+		{"manifest.json", "quote", "", "manifest-2.json"},
+	}
+	for i, tt := range tests {
+		item := &model.BatchExportItem{ExportRequest: model.ExportRequest{TemplateID: tt.templateID}, Filename: tt.filename}
+		if got := batchFilename(i, item, tt.extension, used); got != tt.want {
+			t.Errorf("batchFilename(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// docxPackage docx文件包（zip）的内存表示
+type docxPackage struct {
+	names []string
+	parts map[string][]byte
+}
+
+// openDocxPackage 解析docx文件包
+func openDocxPackage(data []byte) (*docxPackage, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %v", err)
+	}
+
+	pkg := &docxPackage{parts: make(map[string][]byte)}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open docx part %s: %v", file.Name, err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read docx part %s: %v", file.Name, err)
+		}
+		pkg.names = append(pkg.names, file.Name)
+		pkg.parts[file.Name] = content
+	}
+
+	if _, ok := pkg.parts["word/document.xml"]; !ok {
+		return nil, fmt.Errorf("invalid docx file: word/document.xml not found")
+	}
+	return pkg, nil
+}
+
+// setPart 写入或新增部件
+func (p *docxPackage) setPart(name string, content []byte) {
+	if _, ok := p.parts[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.parts[name] = content
+}
+
+// bytes 按原始顺序重新打包为docx
+func (p *docxPackage) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range p.names {
+		w, err := writer.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create docx part %s: %v", name, err)
+		}
+		if _, err := w.Write(p.parts[name]); err != nil {
+			return nil, fmt.Errorf("failed to write docx part %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to save docx document: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// xmlSpan XML片段中元素的位置范围 [start, end)
+type xmlSpan struct {
+	start int
+	end   int
+}
+
+// findElements 查找指定标签的最外层元素范围，支持嵌套和自闭合标签
+func findElements(s, tag string) []xmlSpan {
+	openTag := "<" + tag
+	closeTag := "</" + tag + ">"
+
+	var spans []xmlSpan
+	depth, start := 0, 0
+	for i := 0; i < len(s); {
+		j := strings.IndexByte(s[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j
+
+		switch {
+		case strings.HasPrefix(s[i:], closeTag):
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					spans = append(spans, xmlSpan{start, i + len(closeTag)})
+				}
+			}
+			i += len(closeTag)
+		case strings.HasPrefix(s[i:], openTag) && isTagBoundary(s, i+len(openTag)):
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				return spans
+			}
+			if depth == 0 {
+				start = i
+			}
+			if s[i+end-1] == '/' {
+				if depth == 0 {
+					spans = append(spans, xmlSpan{start, i + end + 1})
+				}
+			} else {
+				depth++
+			}
+			i += end + 1
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// isTagBoundary 判断标签名是否在该位置结束（避免 <w:t 匹配到 <w:tbl）
+func isTagBoundary(s string, pos int) bool {
+	if pos >= len(s) {
+		return false
+	}
+	switch s[pos] {
+	case ' ', '>', '/', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
+
+// replaceElements 对每个最外层元素执行替换
+func replaceElements(s, tag string, fn func(element string) (string, error)) (string, error) {
+	spans := findElements(s, tag)
+	if len(spans) == 0 {
+		return s, nil
+	}
+
+	var buf strings.Builder
+	last := 0
+	for _, span := range spans {
+		replaced, err := fn(s[span.start:span.end])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(s[last:span.start])
+		buf.WriteString(replaced)
+		last = span.end
+	}
+	buf.WriteString(s[last:])
+	return buf.String(), nil
+}
+
+// textNodeRe 匹配 <w:t> 文本节点（不匹配 <w:tab/>、<w:tbl> 等）
+var textNodeRe = regexp.MustCompile(`(?s)<w:t(\s[^>]*[^/])?>(.*?)</w:t>`)
+
+// xmlUnescaper 还原XML转义字符
+var xmlUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&amp;", "&")
+
+// xmlEscaper 转义XML特殊字符
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// elementText 获取元素内全部文本节点拼接后的文本
+func elementText(s string) string {
+	var buf strings.Builder
+	for _, m := range textNodeRe.FindAllStringSubmatch(s, -1) {
+		buf.WriteString(xmlUnescaper.Replace(m[2]))
+	}
+	return buf.String()
+}
+
+// mergeSplitPlaceholders 将被Word拆分到多个文本节点中的占位符合并到第一个节点
+func mergeSplitPlaceholders(paragraph string) string {
+	locs := textNodeRe.FindAllStringSubmatchIndex(paragraph, -1)
+	if len(locs) < 2 {
+		return paragraph
+	}
+
+	// 拼接全部文本，并记录每个字节所属的文本节点
+	var joined strings.Builder
+	var owner []int
+	for i, loc := range locs {
+		joined.WriteString(paragraph[loc[4]:loc[5]])
+		for b := loc[4]; b < loc[5]; b++ {
+			owner = append(owner, i)
+		}
+	}
+	text := joined.String()
+
+	changed := false
+	for _, m := range placeholderRe.FindAllStringIndex(text, -1) {
+		if owner[m[0]] == owner[m[1]-1] {
+			continue
+		}
+		changed = true
+		for b := m[0]; b < m[1]; b++ {
+			owner[b] = owner[m[0]]
+		}
+	}
+	if !changed {
+		return paragraph
+	}
+
+	texts := make([]strings.Builder, len(locs))
+	for b := 0; b < len(text); b++ {
+		texts[owner[b]].WriteByte(text[b])
+	}
+
+	var buf strings.Builder
+	last := 0
+	for i, loc := range locs {
+		buf.WriteString(paragraph[last:loc[0]])
+		buf.WriteString(`<w:t xml:space="preserve">`)
+		buf.WriteString(texts[i].String())
+		buf.WriteString("</w:t>")
+		last = loc[1]
+	}
+	buf.WriteString(paragraph[last:])
+	return buf.String()
+}
+
+// relsPartName 获取部件对应的关系文件名，如 word/document.xml -> word/_rels/document.xml.rels
+func relsPartName(part string) string {
+	dir, file := path.Split(part)
+	return dir + "_rels/" + file + ".rels"
+}
+
+// addImage 将图片写入 word/media 并为指定部件添加关系，返回关系ID和图片文件名
+func (p *docxPackage) addImage(part string, data []byte, ext string) (string, string) {
+	n := 1
+	for {
+		if _, ok := p.parts[fmt.Sprintf("word/media/export_image%d.%s", n, ext)]; !ok {
+			break
+		}
+		n++
+	}
+	fileName := fmt.Sprintf("export_image%d.%s", n, ext)
+	p.setPart("word/media/"+fileName, data)
+	p.ensureContentType(ext, "image/"+ext)
+
+	relsName := relsPartName(part)
+	rels := string(p.parts[relsName])
+	if rels == "" {
+		rels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"></Relationships>`
+	}
+
+	relID := fmt.Sprintf("rIdExportImage%d", n)
+	relationship := fmt.Sprintf(`<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>`, relID, fileName)
+	rels = strings.Replace(rels, "</Relationships>", relationship+"</Relationships>", 1)
+	p.setPart(relsName, []byte(rels))
+
+	return relID, fileName
+}
+
+// ensureContentType 确保 [Content_Types].xml 中声明了扩展名对应的类型
+func (p *docxPackage) ensureContentType(ext, contentType string) {
+	const name = "[Content_Types].xml"
+	types := string(p.parts[name])
+	if strings.Contains(types, `Extension="`+ext+`"`) {
+		return
+	}
+	declaration := fmt.Sprintf(`<Default Extension="%s" ContentType="%s"/>`, ext, contentType)
+	p.setPart(name, []byte(strings.Replace(types, "</Types>", declaration+"</Types>", 1)))
+}
+
+// emuPerPixel 每像素对应的EMU（按96 DPI计算）
+const emuPerPixel = 9525
+
+// inlineImageXML 生成嵌入式图片的 <w:drawing> 元素，命名空间就地声明以兼容页眉页脚部件
+func inlineImageXML(relID, name string, id int, width, height float64) string {
+	cx := int64(width * emuPerPixel)
+	cy := int64(height * emuPerPixel)
+	return fmt.Sprintf(`<w:drawing><wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="%s"/>`+
+		`<wp:cNvGraphicFramePr><a:graphicFrameLocks xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" noChangeAspect="1"/></wp:cNvGraphicFramePr>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:nvPicPr><pic:cNvPr id="0" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing>`,
+		cx, cy, id, name, name, relID, cx, cy)
+}
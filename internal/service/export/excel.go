@@ -1,10 +1,11 @@
 package export
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"os"
 	"strings"
 
 	"office-export-server/internal/model"
@@ -27,36 +28,77 @@ func NewExcelService(templateService template.TemplateService) *ExcelService {
 
 // ExportExcel 导出Excel文件
 func (s *ExcelService) ExportExcel(req *model.ExportRequest) ([]byte, error) {
-	// 获取模板ID（直接从请求根级别获取，而不是从Data字段）
-	templateID := req.TemplateID
-	if templateID == "" {
-		templateID = "default"
+	f, err := s.buildWorkbook(req)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	// 获取模板路径
-	templatePath, err := s.templateService.GetTemplatePath(templateID, "excel")
+	// 保存为二进制数据
+	buf, err := f.WriteToBuffer()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get template path: %v", err)
+		return nil, fmt.Errorf("failed to write excel to buffer: %v", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	// 打开模板文件
-	f, err := excelize.OpenFile(templatePath)
+// ExportExcelStream 流式导出Excel文件：数据行通过StreamWriter写入临时文件，由调用方直接写入响应
+func (s *ExcelService) ExportExcelStream(req *model.ExportRequest) (StreamedFile, error) {
+	streamReq := *req
+	streamReq.Stream = true
+	f, err := s.buildWorkbook(&streamReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open template file: %v", err)
+		return nil, err
+	}
+	return &streamedWorkbook{f: f}, nil
+}
+
+// buildWorkbook 按请求生成工作簿，req.Stream 为 true 时表格模式和占位符模板的sheet使用流式写入
+func (s *ExcelService) buildWorkbook(req *model.ExportRequest) (_ *excelize.File, err error) {
+	// 获取模板ID（直接从请求根级别获取，而不是从Data字段）
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
 	}
-	defer f.Close()
 
 	// 统一处理sheets数组，前端必须传递sheets:[]结构
 	var sheets []interface{}
 	var ok bool
 	if sheets, ok = req.Data["sheets"].([]interface{}); !ok || len(sheets) == 0 {
-		return nil, fmt.Errorf("前端必须传递sheets:[]数组结构，且数组不能为空")
+		return nil, fmt.Errorf("%w: 前端必须传递sheets:[]数组结构，且数组不能为空", ErrInvalidData)
 	}
 
+	// 打开模板文件；全部为表格模式的sheet时不需要模板
+	var file *excelize.File
+	if allTableSheets(sheets) {
+		file = excelize.NewFile()
+	} else {
+		templateData, err := s.templateService.LoadTemplate(templateID, "excel")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %v", err)
+		}
+		file, err = excelize.OpenReader(bytes.NewReader(templateData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open template file: %v", err)
+		}
+	}
+	f := newWorkbook(file)
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+
 	// 遍历sheets数组，为每个sheet创建新的sheet页
-	defaultSheet := f.GetSheetName(0)
+	// 模板sheet先改为临时名称，避免与请求中的sheet重名，导出完成后删除
+	defaultSheet := templateSheetName
+	if err := f.SetSheetName(f.GetSheetName(0), defaultSheet); err != nil {
+		return nil, fmt.Errorf("failed to rename template sheet: %v", err)
+	}
 	// 用于记录已使用的sheet名称，确保名称唯一
 	sheetNameMap := make(map[string]int)
+	// 各模板可用的命名样式，同一模板的sheet共用
+	styleSheets := make(map[string]styleSheet)
 	for i, sheetData := range sheets {
 		sheetMap, ok := sheetData.(map[string]interface{})
 		if !ok {
@@ -89,11 +131,54 @@ func (s *ExcelService) ExportExcel(req *model.ExportRequest) ([]byte, error) {
 		}
 
 		// 创建新的sheet页
-		_, err := f.NewSheet(sheetName)
+		index, err := f.NewSheet(sheetName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create new sheet: %v", err)
 		}
 
+		// 切换为sheet模板的命名样式，请求的 data.styles 对所有sheet生效
+		if _, ok := styleSheets[sheetTemplateID]; !ok {
+			styles, err := loadStyleSheet(s.templateService, "excel", sheetTemplateID, req.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load styles for sheet %s: %w", sheetName, err)
+			}
+			styleSheets[sheetTemplateID] = styles
+		}
+		f.named = styleSheets[sheetTemplateID]
+
+		// 表格模式不使用模板，直接按 headers、rows、merges、images 生成
+		if isTableSheet(sheetMap) {
+			fill := s.fillTableSheet
+			if req.Stream {
+				fill = s.streamTableSheet
+			}
+			if err := fill(f, sheetName, sheetMap); err != nil {
+				return nil, fmt.Errorf("failed to fill table sheet %s: %w", sheetName, err)
+			}
+			if !req.Stream {
+				if err := s.finishSheet(f, sheetName, sheetMap); err != nil {
+					return nil, fmt.Errorf("failed to finish sheet %s: %w", sheetName, err)
+				}
+			}
+			continue
+		}
+
+		// 占位符模板以模板sheet的副本为起点，只能使用当前打开的模板文件
+		if !hasTemplateFiller(sheetTemplateID) {
+			if sheetTemplateID != templateID {
+				return nil, fmt.Errorf("sheet %s: placeholder template %s must be the request template %s", sheetName, sheetTemplateID, templateID)
+			}
+			if err := f.CopySheet(0, index); err != nil {
+				return nil, fmt.Errorf("failed to copy template sheet: %v", err)
+			}
+			if req.Stream {
+				if err := s.streamPlaceholderTemplate(f, sheetName, sheetMap); err != nil {
+					return nil, fmt.Errorf("failed to fill template data for sheet %s: %w", sheetName, err)
+				}
+				continue
+			}
+		}
+
 		// 填充当前sheet的数据
 		// 创建临时请求对象，包含当前sheet的数据
 		tempReq := &model.ExportRequest{
@@ -104,7 +189,10 @@ func (s *ExcelService) ExportExcel(req *model.ExportRequest) ([]byte, error) {
 
 		// 填充数据到当前sheet
 		if err := s.fillTemplateData(f, sheetName, sheetTemplateID, tempReq); err != nil {
-			return nil, fmt.Errorf("failed to fill template data for sheet %s: %v", sheetName, err)
+			return nil, fmt.Errorf("failed to fill template data for sheet %s: %w", sheetName, err)
+		}
+		if err := s.finishSheet(f, sheetName, sheetMap); err != nil {
+			return nil, fmt.Errorf("failed to finish sheet %s: %w", sheetName, err)
 		}
 	}
 
@@ -115,59 +203,182 @@ func (s *ExcelService) ExportExcel(req *model.ExportRequest) ([]byte, error) {
 		}
 	}
 
-	// 保存为二进制数据
-	buf, err := f.WriteToBuffer()
+	// 导出的公式没有缓存结果，要求Excel打开文件时重新计算
+	fullCalcOnLoad := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &fullCalcOnLoad}); err != nil {
+		return nil, fmt.Errorf("failed to set calculation properties: %v", err)
+	}
+
+	return f.File, nil
+}
+
+// finishSheet 数据填充完成后应用sheet声明的命名样式（apply_styles）、条件格式和数据验证
+// 流式写入的sheet在创建StreamWriter之前应用，不经过这里
+func (s *ExcelService) finishSheet(f *workbook, sheetName string, sheetMap map[string]interface{}) error {
+	styleRules, err := parseStyleRules(f, sheetMap)
 	if err != nil {
-		return nil, fmt.Errorf("failed to write excel to buffer: %v", err)
+		return err
 	}
-	return buf.Bytes(), nil
+	if err := styleRules.apply(f, sheetName); err != nil {
+		return err
+	}
+
+	rules, err := parseSheetRules(sheetMap)
+	if err != nil || rules.empty() {
+		return err
+	}
+	// 填充过程中sheet的维度信息不会更新，按实际读取的行数确定最后一行
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to read sheet rows: %v", err)
+	}
+	return rules.apply(f, sheetName, len(rows))
+}
+
+// isTableSheet 判断sheet是否为表格模式：携带 headers 或 rows 时按数据直接生成表格
+func isTableSheet(sheetMap map[string]interface{}) bool {
+	_, hasHeaders := sheetMap["headers"]
+	_, hasRows := sheetMap["rows"]
+	return hasHeaders || hasRows
+}
+
+// allTableSheets 判断是否全部sheet均为表格模式
+func allTableSheets(sheets []interface{}) bool {
+	for _, sheetData := range sheets {
+		sheetMap, ok := sheetData.(map[string]interface{})
+		if ok && !isTableSheet(sheetMap) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSheetData 将表格模式的sheet数据转换为 model.SheetData
+// headers 和 rows 直接按类型转换，不经过JSON编解码，避免大表格在内存中再复制一份；
+// apply_styles、条件格式和数据验证由 parseStyleRules、parseSheetRules 解析
+func parseSheetData(sheetMap map[string]interface{}) (*model.SheetData, error) {
+	sheet := &model.SheetData{}
+	sheet.Name, _ = sheetMap["name"].(string)
+
+	headers, _ := sheetMap["headers"].([]interface{})
+	for _, headerRow := range headers {
+		headerCells, ok := headerRow.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid header row format", ErrInvalidData)
+		}
+		cells := make([]string, len(headerCells))
+		for i, cellData := range headerCells {
+			cells[i], _ = cellData.(string)
+		}
+		sheet.Headers = append(sheet.Headers, cells)
+	}
+
+	rows, _ := sheetMap["rows"].([]interface{})
+	sheet.Rows = make([][]interface{}, 0, len(rows))
+	for _, rowData := range rows {
+		cells, ok := rowData.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid row data format", ErrInvalidData)
+		}
+		sheet.Rows = append(sheet.Rows, cells)
+	}
+
+	// 合并单元格和图片数量少，按JSON解码
+	for _, field := range []struct {
+		key    string
+		target interface{}
+	}{{"merges", &sheet.Merges}, {"images", &sheet.Images}} {
+		raw, ok := sheetMap[field.key]
+		if !ok || raw == nil {
+			continue
+		}
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s: %v", ErrInvalidData, field.key, err)
+		}
+		if err := json.Unmarshal(encoded, field.target); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s: %v", ErrInvalidData, field.key, err)
+		}
+	}
+	return sheet, nil
+}
+
+// fillTableSheet 表格模式：依次写入表头、数据行、合并单元格和图片
+func (s *ExcelService) fillTableSheet(f *workbook, sheetName string, sheetMap map[string]interface{}) error {
+	sheet, err := parseSheetData(sheetMap)
+	if err != nil {
+		return err
+	}
+	headerRows := len(sheet.Headers)
+
+	if err := s.processHeaders(f, sheetName, sheet.Headers); err != nil {
+		return err
+	}
+	if err := s.processRows(f, sheetName, sheet.Rows, headerRows); err != nil {
+		return err
+	}
+	if err := s.processFormulas(f, sheetName, sheetMap, headerRows+1, headerRows+len(sheet.Rows)); err != nil {
+		return err
+	}
+	if err := s.processMerges(f, sheetName, sheet.Merges); err != nil {
+		return err
+	}
+	return s.processImages(f, sheetName, sheet.Images)
+}
+
+// templateSheetName 导出过程中模板sheet的临时名称
+const templateSheetName = "__template__"
+
+// templateFillers 使用Go代码填充的内置模板，其他模板按单元格中的占位符填充
+var templateFillers = map[string]func(s *ExcelService, f *workbook, sheetName string, req *model.ExportRequest) error{
+	"default": (*ExcelService).fillDefaultTemplateData,
+	"budget":  (*ExcelService).fillBudgetTemplateData,
+	"simple":  (*ExcelService).fillSimpleTemplateData,
+	"quote":   (*ExcelService).fillQuoteTemplateData,
+	"cover":   (*ExcelService).fillCoverTemplateData,
+}
+
+// hasTemplateFiller 判断模板是否由Go代码填充
+func hasTemplateFiller(templateID string) bool {
+	_, ok := templateFillers[templateID]
+	return ok
 }
 
 // fillTemplateData 根据模板类型填充数据
-func (s *ExcelService) fillTemplateData(f *excelize.File, sheetName, templateID string, req *model.ExportRequest) error {
+func (s *ExcelService) fillTemplateData(f *workbook, sheetName, templateID string, req *model.ExportRequest) error {
 	// 根据模板ID选择不同的数据填充逻辑
-	switch templateID {
-	case "budget":
-		return s.fillBudgetTemplateData(f, sheetName, req)
-	case "simple":
-		return s.fillSimpleTemplateData(f, sheetName, req)
-	case "quote":
-		return s.fillQuoteTemplateData(f, sheetName, req)
-	case "cover":
-		return s.fillCoverTemplateData(f, sheetName, req)
-	default:
-		return s.fillDefaultTemplateData(f, sheetName, req)
+	if filler, ok := templateFillers[templateID]; ok {
+		return filler(s, f, sheetName, req)
 	}
+	return s.fillPlaceholderTemplate(f, sheetName, req)
 }
 
 // fillDefaultTemplateData 填充默认模板数据
-func (s *ExcelService) fillDefaultTemplateData(f *excelize.File, sheetName string, req *model.ExportRequest) error {
+func (s *ExcelService) fillDefaultTemplateData(f *workbook, sheetName string, req *model.ExportRequest) error {
 	// 处理预算汇总表模板
 	return s.processBudgetTemplate(f, sheetName, req)
 }
 
 // fillBudgetTemplateData 填充预算汇总表模板数据
-func (s *ExcelService) fillBudgetTemplateData(f *excelize.File, sheetName string, req *model.ExportRequest) error {
+func (s *ExcelService) fillBudgetTemplateData(f *workbook, sheetName string, req *model.ExportRequest) error {
 	// 与默认模板相同，仅作为示例
 	return s.fillDefaultTemplateData(f, sheetName, req)
 }
 
 // fillSimpleTemplateData 填充简单模板数据
-func (s *ExcelService) fillSimpleTemplateData(f *excelize.File, sheetName string, req *model.ExportRequest) error {
+func (s *ExcelService) fillSimpleTemplateData(f *workbook, sheetName string, req *model.ExportRequest) error {
+	// 标题、表头和数据样式（templates/styles.yaml）
+	styles, err := f.namedStyles("simple.title", "simple.header", "simple.data")
+	if err != nil {
+		return err
+	}
+	titleStyle, headerStyle, dataStyle := styles[0], styles[1], styles[2]
+
 	// 设置列宽
 	f.SetColWidth(sheetName, "A", "D", 20)
 
 	// 添加标题
 	f.SetCellValue(sheetName, "A1", "简单报表")
-	titleStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold: true,
-			Size: 16,
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-		},
-	})
 	f.MergeCell(sheetName, "A1", "D1")
 	f.SetCellStyle(sheetName, "A1", "D1", titleStyle)
 
@@ -178,136 +389,71 @@ func (s *ExcelService) fillSimpleTemplateData(f *excelize.File, sheetName string
 		f.SetCellValue(sheetName, cell, header)
 	}
 
-	// 表头样式
-	headerStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold: true,
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-			WrapText:   true,
-		},
-	})
 	f.SetCellStyle(sheetName, "A2", "D2", headerStyle)
 
-	// 数据样式
-	dataStyle, _ := f.NewStyle(&excelize.Style{
-		Alignment: &excelize.Alignment{
-			Vertical: "center",
-			WrapText: true,
-		},
-	})
-
-	// 添加数据行
+	// 添加数据行，缺少明细时报错，预览请使用模板的示例数据（preview: true）
 	items, ok := req.Data["items"].([]interface{})
-	if ok {
-		for i, item := range items {
-			row := i + 3
-			itemMap, _ := item.(map[string]interface{})
-			f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), i+1)
-			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), itemMap["品牌"])
-			f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), itemMap["工程量"])
-			f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), itemMap["预算价"])
-			// 设置数据样式
-			f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), dataStyle)
+	if !ok {
+		return fmt.Errorf("%w: items is required and must be an array", ErrInvalidData)
+	}
+	fields := []string{"品牌", "工程量", "预算价"}
+	for i, item := range items {
+		row := i + 3
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: items[%d] must be an object", ErrInvalidData, i)
+		}
+		// 缺少的字段留空，记录日志便于排查
+		var missing []string
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), i+1)
+		for col, field := range fields {
+			value, ok := itemMap[field]
+			if !ok {
+				missing = append(missing, field)
+			}
+			f.SetCellValue(sheetName, fmt.Sprintf("%c%d", 'B'+col, row), value)
+		}
+		if len(missing) > 0 {
+			log.Printf("简单报表第%d项缺少字段：%s", i+1, strings.Join(missing, "、"))
 		}
+		// 设置数据样式
+		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), dataStyle)
 	}
 
 	return nil
 }
 
 // fillQuoteTemplateData 填充报价单模板数据
-func (s *ExcelService) fillQuoteTemplateData(f *excelize.File, sheetName string, req *model.ExportRequest) error {
-	// 数据行样式 - 添加自动换行
-	dataStyle, _ := f.NewStyle(&excelize.Style{
-		Alignment: &excelize.Alignment{
-			Vertical: "center",
-			WrapText: true, // 自动换行
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	// 金额样式
-	amountStyle, _ := f.NewStyle(&excelize.Style{
-		Alignment: &excelize.Alignment{
-			Horizontal: "right",
-			Vertical:   "center",
-			WrapText:   true, // 自动换行
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
+func (s *ExcelService) fillQuoteTemplateData(f *workbook, sheetName string, req *model.ExportRequest) error {
+	// 数据行和金额样式（templates/styles.yaml）
+	styles, err := f.namedStyles("quote.data", "quote.amount")
+	if err != nil {
+		return err
+	}
+	dataStyle, amountStyle := styles[0], styles[1]
 
-	// 获取数据
+	// 获取数据，缺少明细时报错，预览请使用模板的示例数据（preview: true）
 	items, ok := req.Data["items"].([]interface{})
 	if !ok {
-		// 如果没有数据，使用默认数据
-		items = []interface{}{
-			map[string]interface{}{
-				"品名":   "大班台",
-				"规格":   "2400*2000*750",
-				"材质说明": "环保要求：甲醛释放量≤5mg/100g。\n2、基材：E0级\n3、木皮表面",
-				"颜色":   "黑色",
-				"数量":   2,
-				"单价":   10141.00,
-				"总价":   20282.00,
-				"备注":   "",
-			},
-			map[string]interface{}{
-				"品名":   "文件柜",
-				"规格":   "2400*450*2000",
-				"材质说明": "环保要求：甲醛释放量≤5mg/100g。\n2、基材：E0级\n3、木皮表面",
-				"颜色":   "黑色",
-				"数量":   3,
-				"单价":   10716.00,
-				"总价":   32148.00,
-				"备注":   "",
-			},
-			map[string]interface{}{
-				"品名":   "会客桌",
-				"规格":   "2000*800*750",
-				"材质说明": "环保要求：甲醛释放量≤5mg/100g。\n2、基材：E0级\n3、木皮表面",
-				"颜色":   "黑色",
-				"数量":   6,
-				"单价":   4500.00,
-				"总价":   27000.00,
-				"备注":   "",
-			},
-			map[string]interface{}{
-				"品名":   "会客椅",
-				"规格":   "常规",
-				"材质说明": "环保要求：甲醛释放量≤5mg/100g。\n2、基材：E0级\n3、木皮表面",
-				"颜色":   "黑色",
-				"数量":   1,
-				"单价":   400.00,
-				"总价":   400.00,
-				"备注":   "",
-			},
-			map[string]interface{}{
-				"品名":   "中式隔断",
-				"规格":   "定制2800*2100",
-				"材质说明": "采用行列式手法，风格、功能设计",
-				"颜色":   "不锈钢包边",
-				"数量":   6,
-				"单价":   1200.00,
-				"总价":   7200.00,
-				"备注":   "",
-			},
-		}
+		return fmt.Errorf("%w: items is required and must be an array", ErrInvalidData)
+	}
+
+	// 按 单价×数量 计算每行总价和合计
+	pricing, err := parsePricing(req.Data, "items")
+	if err != nil {
+		return err
+	}
+	totals, err := computeTotals(items, pricing)
+	if err != nil {
+		return err
+	}
+	formulas, err := parseColumnFormulas(req.Data)
+	if err != nil {
+		return err
 	}
 
-	// 填充数据行
-	total := 0.0
+	// 填充数据行，总价写为 数量×单价 的公式，客户修改数量或单价后自动重算
+	firstRow, lastRow := 7, len(items)+6
 	for i, item := range items {
 		row := i + 7
 		itemMap, _ := item.(map[string]interface{})
@@ -319,35 +465,51 @@ func (s *ExcelService) fillQuoteTemplateData(f *excelize.File, sheetName string,
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), itemMap["颜色"])
 		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), itemMap["数量"])
 		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), itemMap["单价"])
-		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), itemMap["总价"])
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), moneyFloat(totals.Lines[i]))
 		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), itemMap["备注"])
-
-		// 累加总价
-		if totalPrice, ok := itemMap["总价"].(float64); ok {
-			total += totalPrice
+		if formula := lineFormula(item, pricing, "单价", "数量", "G", "F"); formula != "" {
+			rows := formulaRows{row: row, first: firstRow, last: lastRow}
+			if err := rows.setFormula(f, sheetName, fmt.Sprintf("H%d", row), formula); err != nil {
+				return err
+			}
 		}
 
 		// 设置样式
 		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("I%d", row), dataStyle)
 		f.SetCellStyle(sheetName, fmt.Sprintf("G%d", row), fmt.Sprintf("H%d", row), amountStyle)
 	}
+	// 请求声明的列公式覆盖默认公式
+	if err := formulas.apply(f, sheetName, firstRow, lastRow); err != nil {
+		return err
+	}
 
-	// 合计行
+	// 合计行，小计为明细总价之和
 	totalRow := len(items) + 7
-	f.SetCellValue(sheetName, fmt.Sprintf("A%d", totalRow), "合计（金额大写）：")
+	totalUpper, err := rmbUpper(totals.Total)
+	if err != nil {
+		return err
+	}
+	f.SetCellValue(sheetName, fmt.Sprintf("A%d", totalRow), "合计（金额大写）："+totalUpper)
 	f.SetCellValue(sheetName, fmt.Sprintf("H%d", totalRow), "小计：")
-	f.SetCellValue(sheetName, fmt.Sprintf("I%d", totalRow), total)
-
-	// 优惠价行
-	discountRow := totalRow + 1
-	// 默认优惠价为原价的70%
-	discountPrice := total * 0.7
-	f.SetCellValue(sheetName, fmt.Sprintf("H%d", discountRow), "优惠价：")
-	f.SetCellValue(sheetName, fmt.Sprintf("I%d", discountRow), discountPrice)
-
-	// 设置合计行和优惠价行样式
+	totalsRows := formulaRows{row: totalRow, first: firstRow, last: lastRow}
+	if err := totalsRows.setFormula(f, sheetName, fmt.Sprintf("I%d", totalRow), "SUM(H{first_row}:H{last_row})"); err != nil {
+		return err
+	}
 	f.SetCellStyle(sheetName, fmt.Sprintf("G%d", totalRow), fmt.Sprintf("I%d", totalRow), amountStyle)
-	f.SetCellStyle(sheetName, fmt.Sprintf("G%d", discountRow), fmt.Sprintf("I%d", discountRow), amountStyle)
+
+	// 优惠、服务费、税费行（请求配置了费率时显示），最后为总计，均为基于小计的公式
+	adjustments := totals.adjustments()
+	if len(adjustments) > 0 {
+		adjustments = append(adjustments, totalsLine{"总计", totals.Total, 0})
+	}
+	for i, line := range adjustments {
+		row := totalRow + 1 + i
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), line.label+"：")
+		if err := f.SetCellFormula(sheetName, fmt.Sprintf("I%d", row), adjustmentFormula("I", totalRow, row, line)); err != nil {
+			return err
+		}
+		f.SetCellStyle(sheetName, fmt.Sprintf("G%d", row), fmt.Sprintf("I%d", row), amountStyle)
+	}
 
 	return nil
 }
@@ -360,49 +522,24 @@ func addLineBreak(s string) string {
 	// 移除最后一个多余的换行符
 	return strings.TrimSuffix(string(res), "\n")
 }
-func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string, req *model.ExportRequest) error {
+func (s *ExcelService) fillCoverTemplateData(f *workbook, sheetName string, req *model.ExportRequest) error {
 
-	contentStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   true,
-			Size:   12,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "left",
-			Vertical:   "center",
-		},
-	})
-	verticalTitleStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   true,
-			Size:   26,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-			WrapText:   true,
-		},
-	})
-	inputUnderlineStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   false,
-			Size:   12,
-		},
-		Border: []excelize.Border{
-			{Type: "bottom", Color: "#000000", Style: 1}, // 仅下边框
-		},
-		Alignment: &excelize.Alignment{
-			Vertical: "center",
-		},
-	})
+	// 文字、竖排标题和输入框样式，富文本字段名和字段值的字体（templates/styles.yaml）
+	styles, err := f.namedStyles("cover.content", "cover.vertical_title", "cover.input")
+	if err != nil {
+		return err
+	}
+	contentStyle, verticalTitleStyle, inputUnderlineStyle := styles[0], styles[1], styles[2]
+	labelFont, err := f.namedFont("cover.field_label")
+	if err != nil {
+		return err
+	}
+	valueFont, err := f.namedFont("cover.field_value")
+	if err != nil {
+		return err
+	}
 
 	coverLogo, ok := req.Data["coverLogoUrl"].(string)
-	fmt.Print(coverLogo)
 	if ok {
 		// 使用工具函数插入图片
 		picOptions := &excelize.GraphicOptions{
@@ -410,7 +547,7 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 			ScaleY: 0.3, // 垂直缩放
 		}
 		if err := s.addPictureFromURL(f, sheetName, "A1", coverLogo, picOptions); err != nil {
-			fmt.Printf("插入图片失败：%v\n", err)
+			log.Printf("插入图片失败：%v", err)
 			// 图片插入失败不影响整体导出，继续执行
 		}
 	}
@@ -433,47 +570,30 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectNameRrichText := []excelize.RichTextRun{
 		{
 			Text: "项目名称：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 		{
 			Text: projectName,
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B12", projectNameRrichText)
-	err := f.MergeCell(sheetName, "B12", "I12") // 输入框区域
+	err = f.MergeCell(sheetName, "B12", "I12") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B12:I12: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B12", "I12", inputUnderlineStyle)
 
 	projectAddressRrichText := []excelize.RichTextRun{
 		{
 			Text: "项目地址：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B13", projectAddressRrichText)
 	err = f.MergeCell(sheetName, "B13", "I13") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B13:I13: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B13", "I13", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 13, 30)
@@ -481,19 +601,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectContentRrichText := []excelize.RichTextRun{
 		{
 			Text: "方案内容：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B14", projectContentRrichText)
 	err = f.MergeCell(sheetName, "B14", "I14") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B14:I14: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B14", "I14", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 14, 30)
@@ -501,19 +615,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectRatifyRrichText := []excelize.RichTextRun{
 		{
 			Text: "批准：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B15", projectRatifyRrichText)
 	err = f.MergeCell(sheetName, "B15", "D15") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B15:D15: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B15", "D15", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 15, 30)
@@ -521,19 +629,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectCheckrichText := []excelize.RichTextRun{
 		{
 			Text: "审核",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "E15", projectCheckrichText)
 	err = f.MergeCell(sheetName, "E15", "F15") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells E15:F15: %v", err)
 	}
 	f.SetCellStyle(sheetName, "E15", "F15", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 15, 30)
@@ -541,19 +643,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectDesignerRichText := []excelize.RichTextRun{
 		{
 			Text: "设计师",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "G15", projectDesignerRichText)
 	err = f.MergeCell(sheetName, "G15", "I15") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells G15:I15: %v", err)
 	}
 	f.SetCellStyle(sheetName, "G15", "I15", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 15, 30)
@@ -561,19 +657,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectDateRrichText := []excelize.RichTextRun{
 		{
 			Text: "日期：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B16", projectDateRrichText)
 	err = f.MergeCell(sheetName, "B16", "I16") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B16:I16: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B16", "I16", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 16, 30)
@@ -581,19 +671,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectContactRrichText := []excelize.RichTextRun{
 		{
 			Text: "联系人：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "B17", projectContactRrichText)
 	err = f.MergeCell(sheetName, "B17", "D17") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells B17:D17: %v", err)
 	}
 	f.SetCellStyle(sheetName, "B17", "D17", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 17, 30)
@@ -601,19 +685,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectPhoneichText := []excelize.RichTextRun{
 		{
 			Text: "电话",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "E17", projectPhoneichText)
 	err = f.MergeCell(sheetName, "E17", "F17") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells E17:F17: %v", err)
 	}
 	f.SetCellStyle(sheetName, "E17", "F17", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 17, 30)
@@ -621,19 +699,13 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 	projectWxChenCodeRichText := []excelize.RichTextRun{
 		{
 			Text: "微信号",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "G17", projectWxChenCodeRichText)
 	err = f.MergeCell(sheetName, "G17", "I17") // 输入框区域
 	if err != nil {
-		fmt.Printf("合并单元格失败：%v\n", err)
-		return err
+		return fmt.Errorf("failed to merge cells G17:I17: %v", err)
 	}
 	f.SetCellStyle(sheetName, "G17", "I17", inputUnderlineStyle)
 	f.SetRowHeight(sheetName, 17, 30)
@@ -641,51 +713,22 @@ func (s *ExcelService) fillCoverTemplateData(f *excelize.File, sheetName string,
 }
 
 // processHeaders 处理表头
-func (s *ExcelService) processHeaders(f *excelize.File, sheetName string, headers []interface{}) error {
-	for rowIdx, headerRow := range headers {
-		headerCells, ok := headerRow.([]interface{})
-		if !ok {
-			return fmt.Errorf("invalid header row format")
-		}
+func (s *ExcelService) processHeaders(f *workbook, sheetName string, headers [][]string) error {
+	// 表头样式
+	style, err := f.NewStyle(tableHeaderStyle())
+	if err != nil {
+		return err
+	}
 
-		for colIdx, cellData := range headerCells {
+	for rowIdx, headerCells := range headers {
+		for colIdx, cellValue := range headerCells {
 			colStr, err := excelize.ColumnNumberToName(colIdx + 1)
 			if err != nil {
 				return err
 			}
 
-			cellValue, ok := cellData.(string)
-			if !ok {
-				cellValue = ""
-			}
-
 			cell := fmt.Sprintf("%s%d", colStr, rowIdx+1)
 			f.SetCellValue(sheetName, cell, cellValue)
-
-			// 设置表头样式
-			style, err := f.NewStyle(&excelize.Style{
-				Font: &excelize.Font{
-					Bold: true,
-				},
-				Fill: excelize.Fill{
-					Type:    "pattern",
-					Pattern: 1,
-					Color:   []string{"#E0EBF5"},
-				},
-				Alignment: &excelize.Alignment{
-					Horizontal: "center",
-					Vertical:   "center",
-				},
-				Border: []excelize.Border{
-					{Type: "left", Color: "#000000", Style: 1},
-					{Type: "top", Color: "#000000", Style: 1},
-					{Type: "right", Color: "#000000", Style: 1},
-					{Type: "bottom", Color: "#000000", Style: 1},
-				},
-			})
-			if err != nil {
-				return err
-			}
 			f.SetCellStyle(sheetName, cell, cell, style)
 		}
 	}
@@ -694,13 +737,14 @@ func (s *ExcelService) processHeaders(f *excelize.File, sheetName string, header
 }
 
 // processRows 处理数据行
-func (s *ExcelService) processRows(f *excelize.File, sheetName string, rows []interface{}, headerRows int) error {
-	for rowIdx, rowData := range rows {
-		cells, ok := rowData.([]interface{})
-		if !ok {
-			return fmt.Errorf("invalid row data format")
-		}
+func (s *ExcelService) processRows(f *workbook, sheetName string, rows [][]interface{}, headerRows int) error {
+	// 单元格样式
+	style, err := f.NewStyle(tableCellStyle())
+	if err != nil {
+		return err
+	}
 
+	for rowIdx, cells := range rows {
 		for colIdx, cellData := range cells {
 			colStr, err := excelize.ColumnNumberToName(colIdx + 1)
 			if err != nil {
@@ -709,23 +753,6 @@ func (s *ExcelService) processRows(f *excelize.File, sheetName string, rows []in
 
 			cell := fmt.Sprintf("%s%d", colStr, rowIdx+headerRows+1)
 			f.SetCellValue(sheetName, cell, cellData)
-
-			// 设置单元格样式
-			style, err := f.NewStyle(&excelize.Style{
-				Alignment: &excelize.Alignment{
-					Vertical: "center",
-					WrapText: true,
-				},
-				Border: []excelize.Border{
-					{Type: "left", Color: "#000000", Style: 1},
-					{Type: "top", Color: "#000000", Style: 1},
-					{Type: "right", Color: "#000000", Style: 1},
-					{Type: "bottom", Color: "#000000", Style: 1},
-				},
-			})
-			if err != nil {
-				return err
-			}
 			f.SetCellStyle(sheetName, cell, cell, style)
 		}
 	}
@@ -733,32 +760,85 @@ func (s *ExcelService) processRows(f *excelize.File, sheetName string, rows []in
 	return nil
 }
 
-// processMerges 处理合并单元格
-func (s *ExcelService) processMerges(f *excelize.File, sheetName string, merges []interface{}) error {
-	for _, mergeItem := range merges {
-		mergeMap, ok := mergeItem.(map[string]interface{})
-		if !ok {
-			continue
+// processFormulas 将sheet声明的列公式写入每个数据行，公式列超出数据行宽度时同样使用数据行样式
+func (s *ExcelService) processFormulas(f *workbook, sheetName string, sheetMap map[string]interface{}, first, last int) error {
+	formulas, err := parseColumnFormulas(sheetMap)
+	if err != nil || len(formulas) == 0 || last < first {
+		return err
+	}
+	if err := formulas.apply(f, sheetName, first, last); err != nil {
+		return err
+	}
+	style, err := f.NewStyle(tableCellStyle())
+	if err != nil {
+		return err
+	}
+	for _, col := range formulas.columns() {
+		top, _ := excelize.CoordinatesToCellName(col, first)
+		bottom, _ := excelize.CoordinatesToCellName(col, last)
+		if err := f.SetCellStyle(sheetName, top, bottom, style); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		startRow, _ := mergeMap["start_row"].(float64)
-		startCol, _ := mergeMap["start_col"].(float64)
-		endRow, _ := mergeMap["end_row"].(float64)
-		endCol, _ := mergeMap["end_col"].(float64)
+// tableHeaderStyle 表格模式的表头样式：加粗、浅蓝底色、居中、细边框
+func tableHeaderStyle() *excelize.Style {
+	return &excelize.Style{
+		Font: &excelize.Font{
+			Bold: true,
+		},
+		Fill: excelize.Fill{
+			Type:    "pattern",
+			Pattern: 1,
+			Color:   []string{"#E0EBF5"},
+		},
+		Alignment: &excelize.Alignment{
+			Horizontal: "center",
+			Vertical:   "center",
+		},
+		Border: []excelize.Border{
+			{Type: "left", Color: "#000000", Style: 1},
+			{Type: "top", Color: "#000000", Style: 1},
+			{Type: "right", Color: "#000000", Style: 1},
+			{Type: "bottom", Color: "#000000", Style: 1},
+		},
+	}
+}
 
-		startColStr, err := excelize.ColumnNumberToName(int(startCol) + 1)
+// tableCellStyle 表格模式的数据行样式：垂直居中、自动换行、细边框
+func tableCellStyle() *excelize.Style {
+	return &excelize.Style{
+		Alignment: &excelize.Alignment{
+			Vertical: "center",
+			WrapText: true,
+		},
+		Border: []excelize.Border{
+			{Type: "left", Color: "#000000", Style: 1},
+			{Type: "top", Color: "#000000", Style: 1},
+			{Type: "right", Color: "#000000", Style: 1},
+			{Type: "bottom", Color: "#000000", Style: 1},
+		},
+	}
+}
+
+// processMerges 处理合并单元格，行号和列号从0开始
+func (s *ExcelService) processMerges(f *workbook, sheetName string, merges []model.MergeRange) error {
+	for _, merge := range merges {
+		startColStr, err := excelize.ColumnNumberToName(merge.StartCol + 1)
 		if err != nil {
 			return err
 		}
 
-		endColStr, err := excelize.ColumnNumberToName(int(endCol) + 1)
+		endColStr, err := excelize.ColumnNumberToName(merge.EndCol + 1)
 		if err != nil {
 			return err
 		}
 
 		// 使用MergeCell方法，需要指定起始和结束单元格
-		startCell := fmt.Sprintf("%s%d", startColStr, int(startRow)+1)
-		endCell := fmt.Sprintf("%s%d", endColStr, int(endRow)+1)
+		startCell := fmt.Sprintf("%s%d", startColStr, merge.StartRow+1)
+		endCell := fmt.Sprintf("%s%d", endColStr, merge.EndRow+1)
 		if err := f.MergeCell(sheetName, startCell, endCell); err != nil {
 			return fmt.Errorf("failed to merge cells: %v", err)
 		}
@@ -768,7 +848,7 @@ func (s *ExcelService) processMerges(f *excelize.File, sheetName string, merges
 }
 
 // processBudgetTemplate 处理预算汇总表模板
-func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string, req *model.ExportRequest) error {
+func (s *ExcelService) processBudgetTemplate(f *workbook, sheetName string, req *model.ExportRequest) error {
 	// 设置默认列宽
 	columnWidths := map[string]float64{
 		"A": 8,
@@ -790,142 +870,21 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 	f.SetRowHeight(sheetName, 3, 42)
 	f.SetRowHeight(sheetName, 4, 25) // 表头行固定行高
 	f.SetRowHeight(sheetName, 5, 42)
-	// 标题样式
-	titleStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   true,
-			Size:   20,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	projectStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   false,
-			Size:   14,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "left",
-			Vertical:   "center",
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-	customerStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   false,
-			Size:   12,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "left",
-			Vertical:   "center",
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	// 表头样式
-	headerStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   true,
-			Size:   12,
-			Color:  "#000000",
-		},
-		Fill: excelize.Fill{
-			Type:    "pattern",
-			Pattern: 1,
-			Color:   []string{"#d0cece"},
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-			WrapText:   true,
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	// 数据行样式
-	dataStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   false,
-			Size:   12,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Vertical: "center",
-			WrapText: true,
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	// 金额样式
-	amountStyle, _ := f.NewStyle(&excelize.Style{
-		Alignment: &excelize.Alignment{
-			Horizontal: "right",
-			Vertical:   "center",
-			WrapText:   true,
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
-
-	mergesStyle, _ := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Family: "微软雅黑",
-			Bold:   true,
-			Size:   14,
-			Color:  "#000000",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "#000000", Style: 1},
-			{Type: "top", Color: "#000000", Style: 1},
-			{Type: "right", Color: "#000000", Style: 1},
-			{Type: "bottom", Color: "#000000", Style: 1},
-		},
-	})
+	// 标题、项目信息、表头、数据、金额和标签样式，富文本字段名和字段值的字体（templates/styles.yaml）
+	styles, err := f.namedStyles("budget.title", "budget.project", "budget.customer", "budget.header", "budget.data", "budget.amount", "budget.label")
+	if err != nil {
+		return err
+	}
+	titleStyle, projectStyle, customerStyle, headerStyle := styles[0], styles[1], styles[2], styles[3]
+	dataStyle, amountStyle, mergesStyle := styles[4], styles[5], styles[6]
+	labelFont, err := f.namedFont("budget.field_label")
+	if err != nil {
+		return err
+	}
+	valueFont, err := f.namedFont("budget.field_value")
+	if err != nil {
+		return err
+	}
 
 	// 合并单元格
 	merges := []string{
@@ -955,7 +914,7 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 			ScaleY: 0.1, // 垂直缩放
 		}
 		if err := s.addPictureFromURL(f, sheetName, "A1", logoUrl, picOptions); err != nil {
-			fmt.Printf("插入图片失败：%v\n", err)
+			log.Printf("插入图片失败：%v", err)
 			// 图片插入失败不影响整体导出，继续执行
 		}
 	}
@@ -968,21 +927,11 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 	projectName := []excelize.RichTextRun{
 		{
 			Text: "项目名称：",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   true,
-				Size:   14,
-				Color:  "#000000",
-			},
+			Font: labelFont,
 		},
 		{
 			Text: "项目名称",
-			Font: &excelize.Font{
-				Family: "微软雅黑",
-				Bold:   false,
-				Size:   12,
-				Color:  "#000000",
-			},
+			Font: valueFont,
 		},
 	}
 	f.SetCellRichText(sheetName, "A2", projectName)
@@ -1000,7 +949,7 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 			OffsetY: 6,
 		}
 		if err := s.addPictureFromURL(f, sheetName, "G2", floorPlanUrl, picOptions); err != nil {
-			fmt.Printf("插入图片失败：%v\n", err)
+			log.Printf("插入图片失败：%v", err)
 		}
 	}
 
@@ -1039,8 +988,7 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 			},
 		})
 		if err != nil {
-			fmt.Printf("创建复选框「%s」失败：%v\n", item.text, err)
-			return err
+			return fmt.Errorf("failed to add checkbox %s: %v", item.text, err)
 		}
 	}
 
@@ -1061,36 +1009,29 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 	// 设置表头样式
 	f.SetCellStyle(sheetName, "A5", "H5", headerStyle)
 
-	// 获取数据
+	// 获取数据，缺少明细时报错，预览请使用模板的示例数据（preview: true）
 	items, ok := req.Data["items"].([]interface{})
 	if !ok {
-		// 如果没有数据，使用示例数据
-		items = []interface{}{
-			map[string]interface{}{
-				"序号":     1,
-				"品牌":     "小米",
-				"区域":     "全屋智能主控系统",
-				"系统说明":   "1、AI智能语音、自定义设备各种场景（回家、离家、会客、就餐、休闲、阅读等模式），完美实现智能化体验。 2、智能品类包括：智能灯光、智能遮阳、智能空调，智能安防等； 3、最大优势及亮点 \"无缝接入米家APP、AI智能语音控制、轻成本、轻设计、轻方案、轻对接、轻落地、轻维护\"； 4、可以根据所需的智能开关与空调语音小助手进行DIY定制。",
-				"单位":     "项",
-				"工程量":    1,
-				"预算价":    1928.00,
-				"单项预算合价": 1928.00,
-			},
-			map[string]interface{}{
-				"序号":     2,
-				"品牌":     "FSXRT",
-				"区域":     "智能灯光",
-				"系统说明":   "1、自定义色温：智能双色温（2700~6500K的灯具，可以根据需求DIY自定义设置色温参数； 2、控制方式：单灯控制、回路控制、互控、集成控制、远程控制等； 3、自定义氛围场景：娱乐、聚会、休闲、会客等灯光场景。",
-				"单位":     "项",
-				"工程量":    1,
-				"预算价":    6759.00,
-				"单项预算合价": 6759.00,
-			},
-		}
+		return fmt.Errorf("%w: items is required and must be an array", ErrInvalidData)
 	}
 
-	// 填写数据行
+	// 按 预算价×工程量 计算单项合价和总价
+	pricing, err := parsePricing(req.Data, "items")
+	if err != nil {
+		return err
+	}
+	totals, err := computeTotals(items, pricing)
+	if err != nil {
+		return err
+	}
+	formulas, err := parseColumnFormulas(req.Data)
+	if err != nil {
+		return err
+	}
+
+	// 填写数据行，单项合价写为 工程量×预算价 的公式，客户修改后自动重算
 	startRow := 6
+	lastRow := startRow + len(items) - 1
 	for i, item := range items {
 		row := startRow + i
 		itemMap, _ := item.(map[string]interface{})
@@ -1103,21 +1044,34 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), itemMap["单位"])
 		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), itemMap["工程量"])
 		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), itemMap["预算价"])
-		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), itemMap["单项预算合价"])
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), moneyFloat(totals.Lines[i]))
+		if formula := lineFormula(item, pricing, "预算价", "工程量", "G", "F"); formula != "" {
+			rows := formulaRows{row: row, first: startRow, last: lastRow}
+			if err := rows.setFormula(f, sheetName, fmt.Sprintf("H%d", row), formula); err != nil {
+				return err
+			}
+		}
 
 		// 设置样式
 		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), dataStyle)
 		f.SetCellStyle(sheetName, fmt.Sprintf("E%d", row), fmt.Sprintf("F%d", row), dataStyle)
 		f.SetCellStyle(sheetName, fmt.Sprintf("G%d", row), fmt.Sprintf("H%d", row), amountStyle)
 	}
+	// 请求声明的列公式覆盖默认公式
+	if err := formulas.apply(f, sheetName, startRow, lastRow); err != nil {
+		return err
+	}
 
 	// 添加总计行
 	totalRow := startRow + len(items)
 	f.SetCellValue(sheetName, fmt.Sprintf("A%d", totalRow), totalRow)
 	f.SetCellValue(sheetName, fmt.Sprintf("B%d", totalRow), "/")
 	f.SetCellValue(sheetName, fmt.Sprintf("C%d", totalRow), "项目合计总价(不含增值税)")
-	// 使用SetCellFormula方法设置公式，而不是excelize.Formula函数
-	f.SetCellFormula(sheetName, fmt.Sprintf("H%d", totalRow), fmt.Sprintf("SUM(H%d:H%d)", startRow, totalRow-1))
+	// 使用SetCellFormula方法设置公式，而不是excelize.Formula函数；没有明细时写入0
+	totalRows := formulaRows{row: totalRow, first: startRow, last: lastRow}
+	if err := totalRows.setFormula(f, sheetName, fmt.Sprintf("H%d", totalRow), "SUM(H{first_row}:H{last_row})"); err != nil {
+		return err
+	}
 
 	// 合并总计行
 	// 修复MergeCell调用，使用正确的3参数格式
@@ -1128,12 +1082,33 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 	f.SetCellStyle(sheetName, fmt.Sprintf("A%d", totalRow), fmt.Sprintf("G%d", totalRow), headerStyle)
 	f.SetCellStyle(sheetName, fmt.Sprintf("H%d", totalRow), fmt.Sprintf("H%d", totalRow), amountStyle)
 
+	// 优惠、服务费、税费行（请求配置了费率时显示），最后为项目总价，均为基于合计总价的公式
+	adjustments := totals.adjustments()
+	if len(adjustments) > 0 {
+		adjustments = append(adjustments, totalsLine{"项目总价", totals.Total, 0})
+	}
+	for i, line := range adjustments {
+		row := totalRow + 1 + i
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), row)
+		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), "/")
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), line.label)
+		if err := f.SetCellFormula(sheetName, fmt.Sprintf("H%d", row), adjustmentFormula("H", totalRow, row, line)); err != nil {
+			return err
+		}
+		f.MergeCell(sheetName, fmt.Sprintf("D%d", row), fmt.Sprintf("G%d", row))
+		f.SetCellStyle(sheetName, fmt.Sprintf("A%d", row), fmt.Sprintf("G%d", row), headerStyle)
+		f.SetCellStyle(sheetName, fmt.Sprintf("H%d", row), fmt.Sprintf("H%d", row), amountStyle)
+	}
+
 	// 添加大写金额行
-	capitalRow := totalRow + 1
-	f.SetCellValue(sheetName, fmt.Sprintf("A%d", capitalRow), capitalRow)
-	f.SetCellValue(sheetName, fmt.Sprintf("B%d", capitalRow), "/")
-	f.SetCellValue(sheetName, fmt.Sprintf("C%d", capitalRow), "总价大写(元)")
-	f.SetCellValue(sheetName, fmt.Sprintf("B%d", capitalRow), "贰万贰仟肆佰伍拾玖元贰角整")
+	capitalRow := totalRow + len(adjustments) + 1
+	totalUpper, err := rmbUpper(totals.Total)
+	if err != nil {
+		return err
+	}
+	// A:C 合并后只显示A列，标签写在A列
+	f.SetCellValue(sheetName, fmt.Sprintf("A%d", capitalRow), "总价大写(元)")
+	f.SetCellValue(sheetName, fmt.Sprintf("D%d", capitalRow), totalUpper)
 	f.MergeCell(sheetName, fmt.Sprintf("A%d", capitalRow), fmt.Sprintf("C%d", capitalRow))
 	f.MergeCell(sheetName, fmt.Sprintf("D%d", capitalRow), fmt.Sprintf("H%d", capitalRow))
 	f.SetCellStyle(sheetName, fmt.Sprintf("A%d", capitalRow), fmt.Sprintf("H%d", capitalRow), dataStyle)
@@ -1147,70 +1122,21 @@ func (s *ExcelService) processBudgetTemplate(f *excelize.File, sheetName string,
 	return nil
 }
 
-// addPictureFromURL 从URL添加图片到Excel
-func (s *ExcelService) addPictureFromURL(f *excelize.File, sheetName, cell, imageURL string, options *excelize.GraphicOptions) error {
-	// 从URL下载图片
-	resp, err := http.Get(imageURL)
+// addPictureFromURL 从URL添加图片到Excel，下载限制与其他图片相同，见 downloadImage
+func (s *ExcelService) addPictureFromURL(f *workbook, sheetName, cell, imageURL string, options *excelize.GraphicOptions) error {
+	imageBytes, err := downloadImage(imageURL)
 	if err != nil {
-		return fmt.Errorf("下载图片失败：%v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取图片内容
-	imageBytes, readErr := ioutil.ReadAll(resp.Body)
-	if readErr != nil {
-		return fmt.Errorf("读取图片失败：%v", readErr)
-	}
-
-	// 从URL或Content-Type获取图片扩展名
-	ext := "jpg" // 默认扩展名
-	if strings.Contains(imageURL, ".") {
-		// 从URL获取扩展名
-		parts := strings.Split(imageURL, ".")
-		ext = strings.ToLower(parts[len(parts)-1])
-		// 处理URL中的查询参数，如 .png?param=value
-		if strings.Contains(ext, "?") {
-			ext = strings.Split(ext, "?")[0]
-		}
-	} else {
-		// 从Content-Type获取扩展名
-		contentType := resp.Header.Get("Content-Type")
-		switch contentType {
-		case "image/jpeg":
-			ext = "jpg"
-		case "image/png":
-			ext = "png"
-		case "image/gif":
-			ext = "gif"
-		case "image/bmp":
-			ext = "bmp"
-		case "image/webp":
-			ext = "webp"
-		}
-	}
-
-	// 创建带有正确扩展名的临时文件
-	tempFile, tempErr := ioutil.TempFile("", fmt.Sprintf("excel-image-*.%s", ext))
-	if tempErr != nil {
-		return fmt.Errorf("创建临时文件失败：%v", tempErr)
+		return err
 	}
-	defer func() {
-		tempFile.Close()
-		os.Remove(tempFile.Name())
-	}()
 
-	// 写入图片内容
-	_, writeErr := tempFile.Write(imageBytes)
-	if writeErr != nil {
-		return fmt.Errorf("写入临时文件失败：%v", writeErr)
+	// 按图片内容确定扩展名
+	ext, ok := imageExtensions[http.DetectContentType(imageBytes)]
+	if !ok {
+		return fmt.Errorf("unsupported image type: %s", http.DetectContentType(imageBytes))
 	}
 
-	// 刷新并关闭临时文件，确保内容写入磁盘
-	tempFile.Sync()
-	tempFile.Close()
-
 	// 插入图片
-	picErr := f.AddPicture(sheetName, cell, tempFile.Name(), options)
+	picErr := f.AddPictureFromBytes(sheetName, cell, &excelize.Picture{Extension: "." + ext, File: imageBytes, Format: options})
 	if picErr != nil {
 		return fmt.Errorf("插入图片失败：%v", picErr)
 	}
@@ -1218,21 +1144,42 @@ func (s *ExcelService) addPictureFromURL(f *excelize.File, sheetName, cell, imag
 	return nil
 }
 
-// processImages 处理图片
-func (s *ExcelService) processImages(f *excelize.File, sheetName string, images []interface{}) error {
-	for _, imgItem := range images {
-		imgMap, ok := imgItem.(map[string]interface{})
-		if !ok {
+// maxExcelImageWidth 未指定尺寸时图片的最大宽度（像素）
+const maxExcelImageWidth = 800
+
+// processImages 处理图片，position 的 x、y 为锚定单元格的列号和行号（从0开始，与合并单元格一致），size 为像素尺寸
+func (s *ExcelService) processImages(f *workbook, sheetName string, images []model.ImageData) error {
+	for i := range images {
+		imgData := &images[i]
+		if imgData.Path == "" {
 			continue
 		}
 
-		path, _ := imgMap["path"].(string)
-		if path == "" {
+		cell, err := excelize.CoordinatesToCellName(int(imgData.Position.X)+1, int(imgData.Position.Y)+1)
+		if err != nil {
+			return fmt.Errorf("invalid image position: %v", err)
+		}
+
+		img, err := loadImage(imgData.Path, s.templateService)
+		if err != nil {
+			// 图片加载失败不影响整体导出，继续执行
+			log.Printf("插入图片失败：%v", err)
 			continue
 		}
 
-		// 暂时跳过图片处理，后续根据Excelize v2 API更新
-		continue
+		options := &excelize.GraphicOptions{ScaleX: 1, ScaleY: 1}
+		if img.width > 0 && img.height > 0 {
+			width, height := fitImageSize(img, imgData.Size, maxExcelImageWidth)
+			options.ScaleX = width / float64(img.width)
+			options.ScaleY = height / float64(img.height)
+		}
+		if err := f.AddPictureFromBytes(sheetName, cell, &excelize.Picture{
+			Extension: "." + img.ext,
+			File:      img.data,
+			Format:    options,
+		}); err != nil {
+			return fmt.Errorf("插入图片失败：%v", err)
+		}
 	}
 
 	return nil
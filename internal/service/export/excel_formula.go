@@ -0,0 +1,172 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"office-export-server/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// formulaCellRe 占位符模板中的公式单元格，如 {{=SUM(H{first_row}:H{last_row})}}
+var formulaCellRe = regexp.MustCompile(`^\s*\{\{=(.+)\}\}\s*$`)
+
+// formulaRowRefRe 公式中引用明细行范围的占位符
+var formulaRowRefRe = regexp.MustCompile(`\{(first_row|last_row)\}`)
+
+// formulaRows 公式中可引用的行号：{row} 为公式所在行，{first_row}、{last_row} 为明细的第一行和最后一行
+type formulaRows struct {
+	row   int
+	first int
+	last  int // 没有明细行时小于 first
+}
+
+// expand 替换公式中的行号占位符，去掉开头的 =
+func (r formulaRows) expand(formula string) string {
+	formula = strings.TrimPrefix(strings.TrimSpace(formula), "=")
+	return strings.NewReplacer(
+		"{row}", strconv.Itoa(r.row),
+		"{first_row}", strconv.Itoa(r.first),
+		"{last_row}", strconv.Itoa(r.last),
+	).Replace(formula)
+}
+
+// formulaValue 公式单元格的值：没有明细行时引用明细范围的公式写入0，避免范围指向表头或公式所在行
+func (r formulaRows) formulaValue(formula string) interface{} {
+	if r.last < r.first && formulaRowRefRe.MatchString(formula) {
+		return 0
+	}
+	return excelize.Cell{Formula: r.expand(formula)}
+}
+
+// setFormula 将公式写入单元格，保留单元格原有的样式
+func (r formulaRows) setFormula(f *workbook, sheetName, cell, formula string) error {
+	value := r.formulaValue(formula)
+	if c, ok := value.(excelize.Cell); ok {
+		// 先清除模板单元格原有的值，否则公式的缓存结果为原文本在共享字符串表中的序号
+		if err := f.SetCellValue(sheetName, cell, nil); err != nil {
+			return fmt.Errorf("failed to set formula of cell %s: %v", cell, err)
+		}
+		if err := f.SetCellFormula(sheetName, cell, c.Formula); err != nil {
+			return fmt.Errorf("failed to set formula of cell %s: %v", cell, err)
+		}
+		return nil
+	}
+	return f.SetCellValue(sheetName, cell, value)
+}
+
+// columnFormulas sheet的 formulas 声明：列号到公式，应用于每个明细行
+type columnFormulas map[int]string
+
+// parseColumnFormulas 解析sheet的 formulas，如 {"H": "F{row}*G{row}"}
+func parseColumnFormulas(sheetMap map[string]interface{}) (columnFormulas, error) {
+	raw, ok := sheetMap["formulas"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	var declared map[string]string
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid formulas: %v", ErrInvalidData, err)
+	}
+	if err := json.Unmarshal(encoded, &declared); err != nil {
+		return nil, fmt.Errorf("%w: invalid formulas: %v", ErrInvalidData, err)
+	}
+
+	formulas := make(columnFormulas, len(declared))
+	for name, formula := range declared {
+		col, err := excelize.ColumnNameToNumber(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid formulas column %s: %v", ErrInvalidData, name, err)
+		}
+		if strings.TrimSpace(formula) == "" {
+			return nil, fmt.Errorf("%w: formula of column %s is empty", ErrInvalidData, name)
+		}
+		formulas[col] = formula
+	}
+	return formulas, nil
+}
+
+// columns 按列号排序的公式列
+func (c columnFormulas) columns() []int {
+	cols := make([]int, 0, len(c))
+	for col := range c {
+		cols = append(cols, col)
+	}
+	sort.Ints(cols)
+	return cols
+}
+
+// apply 将公式写入 first 到 last 的每个明细行
+func (c columnFormulas) apply(f *workbook, sheetName string, first, last int) error {
+	for row := first; row <= last; row++ {
+		rows := formulaRows{row: row, first: first, last: last}
+		for _, col := range c.columns() {
+			cell, err := excelize.CoordinatesToCellName(col, row)
+			if err != nil {
+				return err
+			}
+			if err := rows.setFormula(f, sheetName, cell, c[col]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// streamValues 流式写入时将公式放入一行的值中，values 长度不足时补齐
+func (c columnFormulas) streamValues(values []interface{}, rows formulaRows) []interface{} {
+	for _, col := range c.columns() {
+		for len(values) < col {
+			values = append(values, nil)
+		}
+		values[col-1] = rows.formulaValue(c[col])
+	}
+	return values
+}
+
+// lineFormula 报价单、预算表明细行的金额公式（数量列×单价列，按分四舍五入）
+// 单价和数量取自写入这两列的字段且为数值时才使用公式，否则写入计算后的金额
+func lineFormula(item interface{}, pricing model.Pricing, priceField, quantityField, priceCol, quantityCol string) string {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, field := range []struct {
+		configured, expected string
+		aliases              []string
+	}{
+		{pricing.PriceField, priceField, priceFieldAliases},
+		{pricing.QuantityField, quantityField, quantityFieldAliases},
+	} {
+		_, name, err := itemDecimal(itemMap, field.configured, field.aliases)
+		if err != nil || name != field.expected || !isNumber(itemMap[name]) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("ROUND(%s{row}*%s{row},2)", quantityCol, priceCol)
+}
+
+// isNumber 判断数据值是否为数值类型
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case float64, float32, int, int64, json.Number:
+		return true
+	}
+	return false
+}
+
+// adjustmentFormula 汇总行的公式：以小计行到上一行之和为基数按费率计算，费率为0时为合计
+// 与 computeTotals 的顺序一致：优惠按小计、服务费按优惠后金额、税费按优惠后金额加服务费计算
+func adjustmentFormula(col string, subtotalRow, row int, line totalsLine) string {
+	base := fmt.Sprintf("SUM(%s%d:%s%d)", col, subtotalRow, col, row-1)
+	if line.rate == 0 {
+		return base
+	}
+	return fmt.Sprintf("ROUND(%s*%s/100,2)", base, strconv.FormatFloat(line.rate, 'f', -1, 64))
+}
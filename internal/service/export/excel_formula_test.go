@@ -0,0 +1,126 @@
+package export
+
+import (
+	"errors"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// useRepoTemplates 使用仓库中的内置模板目录，测试结束后恢复配置
+func useRepoTemplates(t *testing.T) {
+	t.Helper()
+	old := config.Get()
+	cfg := *old
+	cfg.Template.Storage = "fs"
+	cfg.Template.Path = "../../../templates"
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+}
+
+// checkFormulas 检查单元格的公式，公式为空时检查单元格不是公式
+func checkFormulas(t *testing.T, f *excelize.File, sheet string, want map[string]string) {
+	t.Helper()
+	for cell, formula := range want {
+		if got, err := f.GetCellFormula(sheet, cell); err != nil || got != formula {
+			t.Errorf("%s!%s formula = %q, %v; want %q", sheet, cell, got, err, formula)
+		}
+	}
+}
+
+func TestQuoteFormulas(t *testing.T) {
+	useRepoTemplates(t)
+	xs := NewExcelService(template.NewTemplateService())
+	req := &model.ExportRequest{TemplateID: "quote", Data: map[string]interface{}{
+		"sheets": []interface{}{map[string]interface{}{
+			"name": "报价单",
+			"items": []interface{}{
+				map[string]interface{}{"品名": "大班台", "数量": 2, "单价": 10141.0},
+				map[string]interface{}{"品名": "文件柜", "数量": 3, "单价": 10716.0},
+				map[string]interface{}{"品名": "会客桌", "数量": 6, "单价": 4500.0},
+				map[string]interface{}{"品名": "会客椅", "数量": 1, "单价": "¥1,299.00"},
+			},
+			"pricing": map[string]interface{}{"discount_rate": 10, "tax_rate": 6},
+		}},
+	}}
+	f := readWorkbook(t, xs, req)
+
+	// 明细为第7到10行，文本单价的明细写入计算后的金额；小计在第11行，之后为优惠、税费和总计
+	checkFormulas(t, f, "报价单", map[string]string{
+		"H7":  "ROUND(F7*G7,2)",
+		"H8":  "ROUND(F8*G8,2)",
+		"H9":  "ROUND(F9*G9,2)",
+		"H10": "",
+		"I11": "SUM(H7:H10)",
+		"I12": "ROUND(SUM(I11:I11)*-10/100,2)",
+		"I13": "ROUND(SUM(I11:I12)*6/100,2)",
+		"I14": "SUM(I11:I13)",
+	})
+	if value, _ := f.GetCellValue("报价单", "H10"); value != "1299" {
+		t.Errorf("H10 = %q, want the computed amount 1299", value)
+	}
+	for cell, label := range map[string]string{"H12": "优惠(10%)：", "H13": "税费(6%)：", "H14": "总计："} {
+		if value, _ := f.GetCellValue("报价单", cell); value != label {
+			t.Errorf("%s = %q, want %q", cell, value, label)
+		}
+	}
+}
+
+func TestPlaceholderFormulaRows(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/priced.xlsx": placeholderWorkbook(t, map[string]interface{}{
+			"A2": "{{#items}}{{品名}}",
+			"B2": "{{数量}}",
+			"C2": "{{单价}}",
+			"D2": "{{=ROUND(B{row}*C{row},2)}}{{/items}}",
+			"C3": "小计",
+			"D3": "{{=SUM(D{first_row}:D{last_row})}}",
+		}),
+	})
+	items := []interface{}{
+		map[string]interface{}{"品名": "灯具", "数量": 2, "单价": 99.5},
+		map[string]interface{}{"品名": "开关", "数量": 10, "单价": 12},
+		map[string]interface{}{"品名": "线材", "数量": 3, "单价": 45},
+	}
+	formulas := map[string]interface{}{"E": "D{row}/SUM(D{first_row}:D{last_row})"}
+
+	for _, stream := range []bool{false, true} {
+		f := exportSheet(t, "priced", map[string]interface{}{"name": "明细", "items": items, "formulas": formulas}, stream)
+		checkFormulas(t, f, "明细", map[string]string{
+			"D2": "ROUND(B2*C2,2)",
+			"D3": "ROUND(B3*C3,2)",
+			"D4": "ROUND(B4*C4,2)",
+			"E2": "D2/SUM(D2:D4)",
+			"E4": "D4/SUM(D2:D4)",
+			"D5": "SUM(D2:D4)",
+			"E5": "",
+		})
+
+		// 没有明细行时引用明细范围的公式写入0
+		f = exportSheet(t, "priced", map[string]interface{}{"name": "明细", "items": []interface{}{}, "formulas": formulas}, stream)
+		checkFormulas(t, f, "明细", map[string]string{"D2": ""})
+		if value, _ := f.GetCellValue("明细", "D2"); value != "0" {
+			t.Errorf("stream=%v: D2 without items = %q, want 0", stream, value)
+		}
+	}
+}
+
+func TestParseColumnFormulasInvalid(t *testing.T) {
+	for _, raw := range []interface{}{
+		"H{row}",
+		map[string]interface{}{"1A": "F{row}*G{row}"},
+		map[string]interface{}{"H": " "},
+	} {
+		if _, err := parseColumnFormulas(map[string]interface{}{"formulas": raw}); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("parseColumnFormulas(%v) error = %v, want ErrInvalidData", raw, err)
+		}
+	}
+	formulas, err := parseColumnFormulas(map[string]interface{}{"formulas": map[string]interface{}{"J": "=H{row}", "h": "F{row}"}})
+	if err != nil || formulas[8] != "F{row}" || formulas[10] != "=H{row}" {
+		t.Errorf("parseColumnFormulas = %v, %v", formulas, err)
+	}
+}
@@ -0,0 +1,309 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"office-export-server/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// lastRowPlaceholder 条件格式和数据验证的范围中表示sheet最后一行的占位符
+const lastRowPlaceholder = "{{@last_row}}"
+
+// sheetRules sheet声明的条件格式和数据验证
+type sheetRules struct {
+	ConditionalFormats []model.ConditionalFormat `json:"conditional_formats"`
+	Validations        []model.DataValidation    `json:"validations"`
+}
+
+// parseSheetRules 解析sheet的 conditional_formats 和 validations
+func parseSheetRules(sheetMap map[string]interface{}) (*sheetRules, error) {
+	rules := &sheetRules{}
+	_, hasFormats := sheetMap["conditional_formats"]
+	_, hasValidations := sheetMap["validations"]
+	if !hasFormats && !hasValidations {
+		return rules, nil
+	}
+	encoded, err := json.Marshal(map[string]interface{}{
+		"conditional_formats": sheetMap["conditional_formats"],
+		"validations":         sheetMap["validations"],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid sheet rules: %v", ErrInvalidData, err)
+	}
+	if err := json.Unmarshal(encoded, rules); err != nil {
+		return nil, fmt.Errorf("%w: invalid sheet rules: %v", ErrInvalidData, err)
+	}
+	return rules, nil
+}
+
+// empty 判断sheet是否未声明条件格式和数据验证
+func (r *sheetRules) empty() bool {
+	return len(r.ConditionalFormats) == 0 && len(r.Validations) == 0
+}
+
+// apply 将条件格式和数据验证添加到sheet，lastRow 为sheet填充后的最后一行
+// 流式写入时需在创建StreamWriter之前调用
+func (r *sheetRules) apply(f *workbook, sheetName string, lastRow int) error {
+	for i, rule := range r.ConditionalFormats {
+		if err := addConditionalFormat(f, sheetName, rule, lastRow); err != nil {
+			return fmt.Errorf("%w: conditional_formats[%d]: %v", ErrInvalidData, i, err)
+		}
+	}
+	for i, rule := range r.Validations {
+		if err := addDataValidation(f, sheetName, rule, lastRow); err != nil {
+			return fmt.Errorf("%w: validations[%d]: %v", ErrInvalidData, i, err)
+		}
+	}
+	return nil
+}
+
+// ruleRange 替换范围中的 {{@last_row}}，sheet为空时按第1行处理
+func ruleRange(area string, lastRow int) (string, error) {
+	if lastRow < 1 {
+		lastRow = 1
+	}
+	area = strings.ReplaceAll(strings.TrimSpace(area), lastRowPlaceholder, strconv.Itoa(lastRow))
+	if area == "" {
+		return "", fmt.Errorf("range is required")
+	}
+	// 多个范围以空格分隔，如 "E7:E20 G7:G20"
+	for _, ref := range strings.Fields(area) {
+		for _, part := range strings.Split(ref, ":") {
+			if _, _, err := excelize.CellNameToCoordinates(part); err != nil {
+				return "", fmt.Errorf("invalid range %s: %v", area, err)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(area), " "), nil
+}
+
+// ruleValue 将条件值转换为Excel公式中的文本，数字保持原样，其他文本需自带引号
+func ruleValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// conditionalFormatTypes 支持的条件格式类型，color_scale 按是否设置中间色转换为二色或三色刻度
+var conditionalFormatTypes = map[string]bool{
+	"cell":        true,
+	"formula":     true,
+	"data_bar":    true,
+	"color_scale": true,
+	"top":         true,
+	"bottom":      true,
+	"duplicate":   true,
+	"unique":      true,
+}
+
+// addConditionalFormat 添加一条条件格式
+func addConditionalFormat(f *workbook, sheetName string, rule model.ConditionalFormat, lastRow int) error {
+	if !conditionalFormatTypes[rule.Type] {
+		return fmt.Errorf("unsupported conditional format type: %s", rule.Type)
+	}
+	area, err := ruleRange(rule.Range, lastRow)
+	if err != nil {
+		return err
+	}
+	for _, color := range []string{rule.Color, rule.MinColor, rule.MidColor, rule.MaxColor} {
+		if color != "" && !colorRe.MatchString(color) {
+			return fmt.Errorf("invalid color %q, expected #RRGGBB", color)
+		}
+	}
+
+	opts := excelize.ConditionalFormatOptions{
+		Type:       rule.Type,
+		Criteria:   "=",
+		StopIfTrue: rule.StopIfTrue,
+	}
+	switch rule.Type {
+	case "cell":
+		if rule.Criteria == "" {
+			return fmt.Errorf("criteria is required for cell rule")
+		}
+		opts.Criteria = rule.Criteria
+		opts.Value = ruleValue(rule.Value)
+		opts.MinValue = ruleValue(rule.MinValue)
+		opts.MaxValue = ruleValue(rule.MaxValue)
+	case "formula":
+		if rule.Formula == "" {
+			return fmt.Errorf("formula is required for formula rule")
+		}
+		opts.Criteria = strings.TrimPrefix(rule.Formula, "=")
+	case "top", "bottom":
+		opts.Value = ruleValue(rule.Value)
+		if opts.Value == "" {
+			opts.Value = "10"
+		}
+		opts.Percent = rule.Percent
+	case "data_bar":
+		opts.MinType, opts.MaxType = "min", "max"
+		opts.BarColor = "#638EC6"
+		if rule.Color != "" {
+			opts.BarColor = normalizeColor(rule.Color)
+		}
+	case "color_scale":
+		opts.Type = "2_color_scale"
+		opts.MinType, opts.MaxType = "min", "max"
+		opts.MinColor, opts.MaxColor = "#F8696B", "#63BE7B"
+		if rule.MinColor != "" {
+			opts.MinColor = normalizeColor(rule.MinColor)
+		}
+		if rule.MaxColor != "" {
+			opts.MaxColor = normalizeColor(rule.MaxColor)
+		}
+		if rule.MidColor != "" {
+			opts.Type = "3_color_scale"
+			opts.MidType, opts.MidValue = "percentile", "50"
+			opts.MidColor = normalizeColor(rule.MidColor)
+		}
+	}
+
+	// 数据条和色阶直接着色，其他类型满足条件时应用命名样式
+	if rule.Type != "data_bar" && rule.Type != "color_scale" {
+		if rule.Style == "" {
+			return fmt.Errorf("style is required for %s rule", rule.Type)
+		}
+		def, err := f.named.lookup(rule.Style)
+		if err != nil {
+			return err
+		}
+		format, err := f.NewConditionalStyle(excelStyle(def))
+		if err != nil {
+			return fmt.Errorf("failed to create conditional style %s: %v", rule.Style, err)
+		}
+		opts.Format = &format
+	}
+
+	if err := f.SetConditionalFormat(sheetName, area, []excelize.ConditionalFormatOptions{opts}); err != nil {
+		return fmt.Errorf("failed to set conditional format on %s: %v", area, err)
+	}
+	return nil
+}
+
+// validationTypes 数据验证类型对应的excelize类型
+var validationTypes = map[string]excelize.DataValidationType{
+	"list":        excelize.DataValidationTypeList,
+	"whole":       excelize.DataValidationTypeWhole,
+	"decimal":     excelize.DataValidationTypeDecimal,
+	"text_length": excelize.DataValidationTypeTextLength,
+	"custom":      excelize.DataValidationTypeCustom,
+}
+
+// validationOperators 数据验证比较方式对应的Excel名称
+var validationOperators = map[string]string{
+	"between":               "between",
+	"not_between":           "notBetween",
+	"equal":                 "equal",
+	"not_equal":             "notEqual",
+	"greater_than":          "greaterThan",
+	"greater_than_or_equal": "greaterThanOrEqual",
+	"less_than":             "lessThan",
+	"less_than_or_equal":    "lessThanOrEqual",
+}
+
+// validationErrorStyles 输入无效时的提示方式
+var validationErrorStyles = map[string]excelize.DataValidationErrorStyle{
+	"stop":        excelize.DataValidationErrorStyleStop,
+	"warning":     excelize.DataValidationErrorStyleWarning,
+	"information": excelize.DataValidationErrorStyleInformation,
+}
+
+// validationFormulaEscaper 转义写入XML的校验公式
+var validationFormulaEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// addDataValidation 添加一条数据验证
+func addDataValidation(f *workbook, sheetName string, rule model.DataValidation, lastRow int) error {
+	validationType, ok := validationTypes[rule.Type]
+	if !ok {
+		return fmt.Errorf("unsupported validation type: %s", rule.Type)
+	}
+	area, err := ruleRange(rule.Range, lastRow)
+	if err != nil {
+		return err
+	}
+
+	allowBlank := rule.AllowBlank == nil || *rule.AllowBlank
+	dv := excelize.NewDataValidation(allowBlank)
+	dv.Sqref = area
+
+	switch rule.Type {
+	case "list":
+		switch {
+		case rule.Source != "":
+			dv.SetSqrefDropList(validationFormulaEscaper.Replace(strings.TrimPrefix(rule.Source, "=")))
+		case len(rule.Values) > 0:
+			if err := dv.SetDropList(rule.Values); err != nil {
+				return fmt.Errorf("invalid list values: %v", err)
+			}
+		default:
+			return fmt.Errorf("values or source is required for list validation")
+		}
+	case "custom":
+		if rule.Formula == "" {
+			return fmt.Errorf("formula is required for custom validation")
+		}
+		dv.Type = "custom"
+		dv.Formula1 = validationFormulaEscaper.Replace(strings.TrimPrefix(rule.Formula, "="))
+	default:
+		operator := rule.Operator
+		if operator == "" {
+			operator = "between"
+		}
+		name, ok := validationOperators[operator]
+		if !ok {
+			return fmt.Errorf("unsupported validation operator: %s", operator)
+		}
+		if err := dv.SetRange(0, 0, validationType, excelize.DataValidationOperatorBetween); err != nil {
+			return err
+		}
+		dv.Operator = name
+		if operator == "between" || operator == "not_between" {
+			dv.Formula1, dv.Formula2 = ruleValue(rule.Min), ruleValue(rule.Max)
+			if dv.Formula1 == "" || dv.Formula2 == "" {
+				return fmt.Errorf("min and max are required for %s", operator)
+			}
+		} else {
+			dv.Formula1, dv.Formula2 = ruleValue(rule.Value), ""
+			if dv.Formula1 == "" {
+				return fmt.Errorf("value is required for %s", operator)
+			}
+		}
+		dv.Formula1 = validationFormulaEscaper.Replace(dv.Formula1)
+		dv.Formula2 = validationFormulaEscaper.Replace(dv.Formula2)
+	}
+
+	if rule.Prompt != "" {
+		dv.SetInput("", rule.Prompt)
+	}
+	errorStyle := rule.ErrorStyle
+	if errorStyle == "" {
+		errorStyle = "stop"
+	}
+	style, ok := validationErrorStyles[errorStyle]
+	if !ok {
+		return fmt.Errorf("unsupported error style: %s", errorStyle)
+	}
+	// 始终显示错误提示，否则Excel不会拦截无效输入
+	dv.SetError(style, "", rule.Error)
+	if rule.Error == "" {
+		dv.Error, dv.ErrorTitle = nil, nil
+	}
+
+	if err := f.AddDataValidation(sheetName, dv); err != nil {
+		return fmt.Errorf("failed to add data validation on %s: %v", area, err)
+	}
+	return nil
+}
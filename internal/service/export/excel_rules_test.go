@@ -0,0 +1,137 @@
+package export
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+)
+
+func TestRuleRange(t *testing.T) {
+	tests := []struct {
+		area    string
+		lastRow int
+		want    string
+	}{
+		{"A2:A{{@last_row}}", 10, "A2:A10"},
+		{" E7:E{{@last_row}}   G7:G{{@last_row}} ", 20, "E7:E20 G7:G20"},
+		{"B{{@last_row}}", 0, "B1"},
+		{"$C$2:$C$5", 3, "$C$2:$C$5"},
+	}
+	for _, tt := range tests {
+		if got, err := ruleRange(tt.area, tt.lastRow); err != nil || got != tt.want {
+			t.Errorf("ruleRange(%q, %d) = %q, %v; want %q", tt.area, tt.lastRow, got, err, tt.want)
+		}
+	}
+
+	for _, area := range []string{"", "  ", "A0:B2", "A2:{{@last_row}}", "XYZ", "A1:B2:C", "A2:A{{@last}}"} {
+		if got, err := ruleRange(area, 5); err == nil {
+			t.Errorf("ruleRange(%q) = %q, want an error", area, got)
+		}
+	}
+}
+
+// rulesSheet 表格模式的sheet：一行表头和 rows 行数据，附带条件格式和数据验证
+func rulesSheet(rows int, formats, validations []interface{}) map[string]interface{} {
+	data := make([]interface{}, rows)
+	for i := range data {
+		data[i] = []interface{}{"灯具", float64(i * 60), "套"}
+	}
+	return map[string]interface{}{
+		"name":                "明细",
+		"headers":             []interface{}{[]interface{}{"品名", "金额", "单位"}},
+		"rows":                data,
+		"conditional_formats": formats,
+		"validations":         validations,
+	}
+}
+
+func TestSheetRulesReadBack(t *testing.T) {
+	useTemplateDir(t, nil)
+	formats := []interface{}{
+		map[string]interface{}{"range": "B2:B{{@last_row}}", "type": "cell", "criteria": ">", "value": 100, "style": "warn"},
+		map[string]interface{}{"range": "B1:B{{@last_row}}", "type": "data_bar", "color": "#FF0000"},
+		map[string]interface{}{"range": "A2:C{{@last_row}}", "type": "formula", "formula": "=$B2<0", "style": "warn"},
+	}
+	validations := []interface{}{
+		map[string]interface{}{"range": "C2:C{{@last_row}}", "type": "list", "values": []interface{}{"套", "个", "米"}},
+		map[string]interface{}{"range": "B2:B{{@last_row}}", "type": "decimal", "min": 0, "max": 1000, "prompt": "金额", "error": "金额超出范围"},
+		map[string]interface{}{"range": "A2:A{{@last_row}}", "type": "text_length", "operator": "less_than_or_equal", "value": 20, "error_style": "warning"},
+	}
+
+	for _, stream := range []bool{false, true} {
+		xs := NewExcelService(template.NewTemplateService())
+		req := &model.ExportRequest{Stream: stream, Data: map[string]interface{}{
+			"styles": map[string]interface{}{"warn": map[string]interface{}{"fill": "#FFC7CE", "font": map[string]interface{}{"color": "#9C0006"}}},
+			"sheets": []interface{}{rulesSheet(5, formats, validations)},
+		}}
+		f := readWorkbook(t, xs, req)
+
+		conditional, err := f.GetConditionalFormats("明细")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amounts := conditional["B2:B6"]; len(amounts) != 1 || amounts[0].Type != "cell" || amounts[0].Criteria != "greater than" || amounts[0].Value != "100" || amounts[0].Format == nil {
+			t.Errorf("stream=%v: B2:B6 conditional formats = %+v, want a cell rule with a style", stream, amounts)
+		}
+		if bars := conditional["B1:B6"]; len(bars) != 1 || bars[0].Type != "data_bar" || !strings.EqualFold(bars[0].BarColor, "#FF0000") {
+			t.Errorf("stream=%v: B1:B6 conditional formats = %+v, want a red data bar", stream, bars)
+		}
+		if rows := conditional["A2:C6"]; len(rows) != 1 || rows[0].Type != "formula" || rows[0].Criteria != "$B2<0" {
+			t.Errorf("stream=%v: A2:C6 conditional formats = %+v, want formula $B2<0", stream, rows)
+		}
+
+		dvs, err := f.GetDataValidations("明细")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bySqref := make(map[string]string)
+		for _, dv := range dvs {
+			bySqref[dv.Sqref] = dv.Type + " " + dv.Operator + " " + dv.Formula1 + " " + dv.Formula2
+			if dv.Sqref == "B2:B6" && (dv.Prompt == nil || *dv.Prompt != "金额" || dv.Error == nil || *dv.Error != "金额超出范围") {
+				t.Errorf("stream=%v: B2:B6 prompt/error = %v/%v", stream, dv.Prompt, dv.Error)
+			}
+			if dv.Sqref == "A2:A6" && (dv.ErrorStyle == nil || *dv.ErrorStyle != "warning") {
+				t.Errorf("stream=%v: A2:A6 error style = %v, want warning", stream, dv.ErrorStyle)
+			}
+		}
+		want := map[string]string{
+			"C2:C6": `list  "套,个,米" `,
+			"B2:B6": "decimal between 0 1000",
+			"A2:A6": "textLength lessThanOrEqual 20 ",
+		}
+		for sqref, w := range want {
+			if got := bySqref[sqref]; got != w {
+				t.Errorf("stream=%v: validation %s = %q, want %q", stream, sqref, got, w)
+			}
+		}
+	}
+}
+
+func TestSheetRulesInvalid(t *testing.T) {
+	useTemplateDir(t, nil)
+	xs := NewExcelService(template.NewTemplateService())
+	tests := []struct {
+		formats     []interface{}
+		validations []interface{}
+		want        string
+	}{
+		{[]interface{}{map[string]interface{}{"range": "A2:A5", "type": "icon_set"}}, nil, "conditional_formats[0]: unsupported conditional format type"},
+		{[]interface{}{
+			map[string]interface{}{"range": "A2:A5", "type": "data_bar"},
+			map[string]interface{}{"range": "A2:A5", "type": "cell", "criteria": ">", "value": 1, "style": "missing"},
+		}, nil, "conditional_formats[1]: style not defined: missing"},
+		{[]interface{}{map[string]interface{}{"range": "A0:A5", "type": "data_bar"}}, nil, "conditional_formats[0]: invalid range"},
+		{nil, []interface{}{map[string]interface{}{"range": "A2:A5", "type": "list"}}, "validations[0]: values or source is required"},
+		{nil, []interface{}{map[string]interface{}{"range": "A2:A5", "type": "whole", "operator": "equal"}}, "validations[0]: value is required"},
+	}
+	for _, tt := range tests {
+		req := &model.ExportRequest{Data: map[string]interface{}{"sheets": []interface{}{rulesSheet(3, tt.formats, tt.validations)}}}
+		_, err := xs.ExportExcel(req)
+		if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExportExcel error = %v, want %q", err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 流式模式：使用 excelize 的 StreamWriter 逐行写入sheet，行数据超过内存缓冲后写入临时文件，
+// 内存占用不随行数增长。表格模式和占位符模板的sheet支持流式写入，内置模板仍按普通方式填充
+
+// StreamedFile 流式导出的文件，WriteTo 将文件写入输出，使用完毕后需调用 Close 删除临时文件
+type StreamedFile interface {
+	io.WriterTo
+	Close() error
+}
+
+// streamedWorkbook 流式导出的Excel工作簿
+type streamedWorkbook struct {
+	f *excelize.File
+}
+
+// WriteTo 将工作簿边压缩边写入输出；excelize 默认先把整个文件压缩到内存缓冲区，这里把ZIP直接写到输出
+func (w *streamedWorkbook) WriteTo(out io.Writer) (int64, error) {
+	counter := &countingWriter{w: out}
+	w.f.SetZipWriter(func(io.Writer) excelize.ZipWriter {
+		return zip.NewWriter(counter)
+	})
+	if _, err := w.f.WriteToBuffer(); err != nil {
+		return counter.n, err
+	}
+	return counter.n, nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write 写入数据并累计字节数
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Close 关闭工作簿并删除流式写入产生的临时文件
+func (w *streamedWorkbook) Close() error {
+	return w.f.Close()
+}
+
+// streamTableSheet 流式写入表格模式的sheet，图片需在创建StreamWriter之前添加
+func (s *ExcelService) streamTableSheet(f *workbook, sheetName string, sheetMap map[string]interface{}) error {
+	sheet, err := parseSheetData(sheetMap)
+	if err != nil {
+		return err
+	}
+	headers, rows := sheet.Headers, sheet.Rows
+
+	if err := s.processImages(f, sheetName, sheet.Images); err != nil {
+		return err
+	}
+
+	headerStyle, err := f.NewStyle(tableHeaderStyle())
+	if err != nil {
+		return err
+	}
+	cellStyle, err := f.NewStyle(tableCellStyle())
+	if err != nil {
+		return err
+	}
+	rules, err := parseStyleRules(f, sheetMap)
+	if err != nil {
+		return err
+	}
+	formulas, err := parseColumnFormulas(sheetMap)
+	if err != nil {
+		return err
+	}
+	sheetRules, err := parseSheetRules(sheetMap)
+	if err != nil {
+		return err
+	}
+	if err := sheetRules.apply(f, sheetName, len(headers)+len(rows)); err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to create stream writer: %v", err)
+	}
+	if err := rules.streamColumns(sw); err != nil {
+		return err
+	}
+
+	rowNum := 1
+	for _, headerCells := range headers {
+		values := make([]interface{}, len(headerCells))
+		for c, cellValue := range headerCells {
+			values[c] = rules.styledCell(cellValue, headerStyle, c+1, rowNum)
+		}
+		if err := streamRow(sw, rowNum, values, rules.rowOpts(rowNum, 0)); err != nil {
+			return err
+		}
+		rowNum++
+	}
+
+	firstRow, lastRow := len(headers)+1, len(headers)+len(rows)
+	for _, cells := range rows {
+		values := formulas.streamValues(append([]interface{}(nil), cells...), formulaRows{row: rowNum, first: firstRow, last: lastRow})
+		for c, cellData := range values {
+			values[c] = rules.styledCell(cellData, cellStyle, c+1, rowNum)
+		}
+		if err := streamRow(sw, rowNum, values, rules.rowOpts(rowNum, 0)); err != nil {
+			return err
+		}
+		rowNum++
+	}
+
+	for _, merge := range sheet.Merges {
+		if err := streamMerge(sw, merge.StartCol+1, merge.StartRow+1, merge.EndCol+1, merge.EndRow+1); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush stream writer: %v", err)
+	}
+	return nil
+}
+
+// templateRowLayout 模板中一行的样式和行高
+type templateRowLayout struct {
+	styles []int
+	height float64 // 为0时使用默认行高
+}
+
+// streamPlaceholderTemplate 流式填充占位符模板：逐行写入模板内容，循环行按数组元素展开，
+// 保留模板的单元格样式、行高、列宽和合并单元格；循环行之后的合并单元格随之下移
+func (s *ExcelService) streamPlaceholderTemplate(f *workbook, sheetName string, data map[string]interface{}) error {
+	// StreamWriter会覆盖sheet原有的单元格，先读取模板的内容、样式和合并单元格
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("failed to read template sheet: %v", err)
+	}
+	scope, totals, err := newTemplateScope(data, rows)
+	if err != nil {
+		return err
+	}
+	markers := templateRowMarkers(rows)
+	maxCol, maxRow, err := sheetDimension(f.File, sheetName)
+	if err != nil {
+		return err
+	}
+	if len(rows) > maxRow {
+		maxRow = len(rows)
+	}
+	for _, row := range rows {
+		if len(row) > maxCol {
+			maxCol = len(row)
+		}
+	}
+
+	defaultHeight := 0.0
+	if props, err := f.GetSheetProps(sheetName); err == nil && props.DefaultRowHeight != nil {
+		defaultHeight = *props.DefaultRowHeight
+	}
+	layouts := make([]templateRowLayout, maxRow)
+	statics := make([][]interface{}, maxRow)
+	for r := 0; r < maxRow; r++ {
+		layout := templateRowLayout{styles: make([]int, maxCol)}
+		for c := 0; c < maxCol; c++ {
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			if layout.styles[c], err = f.GetCellStyle(sheetName, cell); err != nil {
+				return fmt.Errorf("failed to read style of cell %s: %v", cell, err)
+			}
+		}
+		if height, err := f.GetRowHeight(sheetName, r+1); err == nil && height != defaultHeight {
+			layout.height = height
+		}
+		layouts[r] = layout
+
+		// 非循环行中不含占位符的单元格按原值（数字、公式）写回
+		if _, ok := markers[r]; ok || r >= len(rows) {
+			continue
+		}
+		statics[r] = make([]interface{}, len(rows[r]))
+		for c, text := range rows[r] {
+			if strings.Contains(text, "{{") {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+			if statics[r][c], err = templateStaticValue(f.File, sheetName, cell, text); err != nil {
+				return err
+			}
+		}
+	}
+	merges, err := f.GetMergeCells(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to read merged cells: %v", err)
+	}
+	rules, err := parseStyleRules(f, data)
+	if err != nil {
+		return err
+	}
+
+	formulas, err := parseColumnFormulas(data)
+	if err != nil {
+		return err
+	}
+
+	// starts、counts 记录每个模板行在输出中的起始行号和行数，用于公式的行号和调整合并单元格
+	starts, counts := templateRowSpans(maxRow, markers, scope)
+	itemRows := templateItemRows(markers, starts, counts, totals.pricing.Items)
+
+	// 条件格式和数据验证需在创建StreamWriter之前添加，按循环行展开后的行数计算最后一行
+	lastRow := 0
+	if maxRow > 0 {
+		lastRow = starts[maxRow-1] + counts[maxRow-1] - 1
+	}
+	sheetRules, err := parseSheetRules(data)
+	if err != nil {
+		return err
+	}
+	if err := sheetRules.apply(f, sheetName, lastRow); err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to create stream writer: %v", err)
+	}
+	if err := rules.streamColumns(sw); err != nil {
+		return err
+	}
+
+	rowNum := 1
+	for r := 0; r < maxRow; r++ {
+		var row []string
+		if r < len(rows) {
+			row = rows[r]
+		}
+
+		name, isLoop := markers[r]
+		if !isLoop {
+			refs := formulaRows{row: rowNum, first: itemRows.first, last: itemRows.last}
+			values := make([]interface{}, maxCol)
+			for c := range values {
+				var value interface{}
+				if c < len(row) {
+					if m := formulaCellRe.FindStringSubmatch(row[c]); m != nil {
+						value = refs.formulaValue(m[1])
+					} else if strings.Contains(row[c], "{{") {
+						value = templateCellValue(row[c], scope)
+					} else {
+						value = statics[r][c]
+					}
+				}
+				values[c] = rules.styledCell(value, layouts[r].styles[c], c+1, rowNum)
+			}
+			if err := streamRow(sw, rowNum, values, rules.rowOpts(rowNum, layouts[r].height)); err != nil {
+				return err
+			}
+			rowNum++
+			continue
+		}
+
+		loopRow := stripRowMarker(row, name)
+		value, _ := scope.lookup(name)
+		list := toItems(value)
+		for i, item := range list {
+			itemScope := templateItemScope(scope, totals, name, item, i)
+			refs := formulaRows{row: rowNum, first: itemRows.first, last: itemRows.last}
+			values := make([]interface{}, maxCol)
+			for c := range values {
+				if c < len(loopRow) && loopRow[c] != "" {
+					if m := formulaCellRe.FindStringSubmatch(loopRow[c]); m != nil {
+						values[c] = refs.formulaValue(m[1])
+					} else {
+						values[c] = templateCellValue(loopRow[c], itemScope)
+					}
+				}
+			}
+			if name == totals.pricing.Items {
+				values = formulas.streamValues(values, refs)
+			}
+			for c := range values {
+				style := 0
+				if c < maxCol {
+					style = layouts[r].styles[c]
+				}
+				values[c] = rules.styledCell(values[c], style, c+1, rowNum)
+			}
+			if err := streamRow(sw, rowNum, values, rules.rowOpts(rowNum, layouts[r].height)); err != nil {
+				return err
+			}
+			rowNum++
+		}
+	}
+
+	for _, merge := range merges {
+		startCol, startRow, err := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		if err != nil {
+			return err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err != nil {
+			return err
+		}
+		if startRow > maxRow || endRow > maxRow {
+			continue
+		}
+		first, last := startRow-1, endRow-1
+		// 循环行内的合并单元格按元素逐行复制；跨越循环行的合并单元格无法对应，忽略
+		if _, ok := markers[first]; ok && first == last {
+			for i := 0; i < counts[first]; i++ {
+				if err := streamMerge(sw, startCol, starts[first]+i, endCol, starts[first]+i); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		spansLoop := false
+		for r := first; r <= last; r++ {
+			if _, ok := markers[r]; ok {
+				spansLoop = true
+				break
+			}
+		}
+		if spansLoop {
+			continue
+		}
+		if err := streamMerge(sw, startCol, starts[first], endCol, starts[last]); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush stream writer: %v", err)
+	}
+	return nil
+}
+
+// sheetDimension 获取sheet已使用区域的列数和行数
+func sheetDimension(f *excelize.File, sheetName string) (int, int, error) {
+	dimension, err := f.GetSheetDimension(sheetName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read sheet dimension: %v", err)
+	}
+	if dimension == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(dimension, ":")
+	col, row, err := excelize.CellNameToCoordinates(parts[len(parts)-1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid sheet dimension %s: %v", dimension, err)
+	}
+	return col, row, nil
+}
+
+// templateStaticValue 读取模板单元格的原值：公式保留公式，数字和布尔值保留类型
+func templateStaticValue(f *excelize.File, sheetName, cell, raw string) (interface{}, error) {
+	formula, err := f.GetCellFormula(sheetName, cell)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read formula of cell %s: %v", cell, err)
+	}
+	if formula != "" {
+		return excelize.Cell{Formula: formula}, nil
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	cellType, err := f.GetCellType(sheetName, cell)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read type of cell %s: %v", cell, err)
+	}
+	switch cellType {
+	case excelize.CellTypeBool:
+		return raw == "1", nil
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			return number, nil
+		}
+	}
+	return raw, nil
+}
+
+// styledCell 为单元格的值附加样式，apply_styles 声明的样式优先于 styleID
+func (r *sheetStyleRules) styledCell(value interface{}, styleID, col, row int) excelize.Cell {
+	if id, ok := r.cellStyle(col, row); ok {
+		styleID = id
+	}
+	if cell, ok := value.(excelize.Cell); ok {
+		cell.StyleID = styleID
+		return cell
+	}
+	return excelize.Cell{StyleID: styleID, Value: value}
+}
+
+// rowOpts 流式写入一行的行高和行样式，height 为0时使用默认行高
+func (r *sheetStyleRules) rowOpts(row int, height float64) excelize.RowOpts {
+	return excelize.RowOpts{Height: height, StyleID: r.rowStyle(row)}
+}
+
+// streamRow 写入一行，opts 的行高为0时使用默认行高
+func streamRow(sw *excelize.StreamWriter, rowNum int, values []interface{}, opts excelize.RowOpts) error {
+	cell, err := excelize.CoordinatesToCellName(1, rowNum)
+	if err != nil {
+		return err
+	}
+	if err := sw.SetRow(cell, values, opts); err != nil {
+		return fmt.Errorf("failed to write row %d: %v", rowNum, err)
+	}
+	return nil
+}
+
+// streamMerge 合并单元格，行列号从1开始
+func streamMerge(sw *excelize.StreamWriter, startCol, startRow, endCol, endRow int) error {
+	startCell, err := excelize.CoordinatesToCellName(startCol, startRow)
+	if err != nil {
+		return err
+	}
+	endCell, err := excelize.CoordinatesToCellName(endCol, endRow)
+	if err != nil {
+		return err
+	}
+	if err := sw.MergeCell(startCell, endCell); err != nil {
+		return fmt.Errorf("failed to merge cells: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetCells 读取sheet中 A1 到 lastCell 范围内每个单元格的值和公式
+func sheetCells(t *testing.T, f *excelize.File, sheet string, lastCol, lastRow int) map[string]string {
+	t.Helper()
+	cells := make(map[string]string)
+	for row := 1; row <= lastRow; row++ {
+		for col := 1; col <= lastCol; col++ {
+			cell, _ := excelize.CoordinatesToCellName(col, row)
+			value, err := f.GetCellValue(sheet, cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formula, err := f.GetCellFormula(sheet, cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cells[cell] = fmt.Sprintf("%q =%q", value, formula)
+		}
+	}
+	return cells
+}
+
+func TestStreamMatchesPlaceholderTemplate(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/priced.xlsx": placeholderWorkbook(t, map[string]interface{}{
+			"A1": "{{title}}",
+			"A3": "{{#items}}{{@index}}",
+			"B3": "{{品名}}",
+			"C3": "{{数量}}",
+			"D3": "{{单价}}",
+			"E3": "{{=ROUND(C{row}*D{row},2)}}{{/items}}",
+			"D4": "小计",
+			"E4": "{{=SUM(E{first_row}:E{last_row})}}",
+			"F4": "{{totals.total|rmb_upper}}",
+			"A5": "备注",
+		}),
+	})
+	items := []interface{}{
+		map[string]interface{}{"品名": "灯具", "数量": 2, "单价": 99.5},
+		map[string]interface{}{"品名": "开关", "数量": 10, "单价": "¥12.00"},
+		map[string]interface{}{"品名": "线材", "数量": 3, "单价": 45},
+	}
+
+	for _, tt := range []struct {
+		name  string
+		items []interface{}
+	}{
+		{"明细", items},
+		{"空明细", []interface{}{}},
+	} {
+		sheet := map[string]interface{}{
+			"name":     tt.name,
+			"title":    "报价单",
+			"items":    tt.items,
+			"formulas": map[string]interface{}{"G": "E{row}/SUM(E{first_row}:E{last_row})"},
+		}
+		want := sheetCells(t, exportSheet(t, "priced", sheet, false), tt.name, 8, 8)
+		got := sheetCells(t, exportSheet(t, "priced", sheet, true), tt.name, 8, 8)
+		for cell, w := range want {
+			if got[cell] != w {
+				t.Errorf("%s!%s: stream = %s, want %s", tt.name, cell, got[cell], w)
+			}
+		}
+	}
+
+	// 确认比较的内容确实经过了展开和计算
+	f := exportSheet(t, "priced", map[string]interface{}{"name": "明细", "items": items}, true)
+	if formula, _ := f.GetCellFormula("明细", "E6"); formula != "SUM(E3:E5)" {
+		t.Errorf("E6 formula = %q, want SUM(E3:E5)", formula)
+	}
+	if value, _ := f.GetCellValue("明细", "B4"); value != "开关" {
+		t.Errorf("B4 = %q, want 开关", value)
+	}
+}
@@ -0,0 +1,312 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"office-export-server/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// workbook 导出中的Excel工作簿，同一工作簿内相同定义的样式只创建一次，供所有sheet和填充逻辑共享
+type workbook struct {
+	*excelize.File
+	styles map[string]int
+	named  styleSheet // 当前填充的sheet可用的命名样式，随sheet的模板切换
+}
+
+// newWorkbook 包装excelize工作簿
+func newWorkbook(f *excelize.File) *workbook {
+	return &workbook{
+		File:   f,
+		styles: make(map[string]int),
+	}
+}
+
+// NewStyle 按样式定义获取样式ID，定义相同的样式复用已创建的ID
+func (w *workbook) NewStyle(style *excelize.Style) (int, error) {
+	key, err := json.Marshal(style)
+	if err != nil {
+		return 0, fmt.Errorf("invalid style: %v", err)
+	}
+	if id, ok := w.styles[string(key)]; ok {
+		return id, nil
+	}
+
+	id, err := w.File.NewStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	w.styles[string(key)] = id
+	return id, nil
+}
+
+// namedStyles 按名称获取当前sheet可用的命名样式ID，顺序与名称一致
+func (w *workbook) namedStyles(names ...string) ([]int, error) {
+	ids := make([]int, len(names))
+	for i, name := range names {
+		def, err := w.named.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if ids[i], err = w.NewStyle(excelStyle(def)); err != nil {
+			return nil, fmt.Errorf("failed to create style %s: %v", name, err)
+		}
+	}
+	return ids, nil
+}
+
+// namedFont 按名称获取命名样式的字体，用于富文本
+func (w *workbook) namedFont(name string) (*excelize.Font, error) {
+	def, err := w.named.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return excelFont(def.Font), nil
+}
+
+// excelStyle 将命名样式转换为excelize样式
+func excelStyle(def model.StyleDef) *excelize.Style {
+	style := &excelize.Style{Font: excelFont(def.Font)}
+	if def.Fill != "" {
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{normalizeColor(def.Fill)}}
+	}
+	if border := def.Border; border != nil {
+		sides := border.Sides
+		if len(sides) == 0 {
+			sides = borderSides
+		}
+		lineStyle := borderStyles["thin"]
+		if border.Style != "" {
+			lineStyle = borderStyles[border.Style]
+		}
+		color := "#000000"
+		if border.Color != "" {
+			color = normalizeColor(border.Color)
+		}
+		for _, side := range sides {
+			style.Border = append(style.Border, excelize.Border{Type: side, Color: color, Style: lineStyle})
+		}
+	}
+	if align := def.Align; align != nil {
+		style.Alignment = &excelize.Alignment{
+			Horizontal: align.Horizontal,
+			Vertical:   align.Vertical,
+			WrapText:   align.Wrap,
+		}
+	}
+	if def.NumberFormat != "" {
+		numFmt := def.NumberFormat
+		style.CustomNumFmt = &numFmt
+	}
+	return style
+}
+
+// excelFont 将命名样式的字体转换为excelize字体
+func excelFont(font *model.StyleFont) *excelize.Font {
+	if font == nil {
+		return nil
+	}
+	result := &excelize.Font{
+		Family: font.Family,
+		Size:   font.Size,
+		Bold:   font.Bold,
+		Italic: font.Italic,
+	}
+	if font.Underline {
+		result.Underline = "single"
+	}
+	if font.Color != "" {
+		result.Color = normalizeColor(font.Color)
+	}
+	return result
+}
+
+// styleRule 引用命名样式的区域，行列号从1开始，整列或整行的规则另一维度为0
+type styleRule struct {
+	startCol, startRow int
+	endCol, endRow     int
+	styleID            int
+}
+
+// contains 判断单元格是否在区域内
+func (r styleRule) contains(col, row int) bool {
+	return (r.startCol == 0 || col >= r.startCol && col <= r.endCol) &&
+		(r.startRow == 0 || row >= r.startRow && row <= r.endRow)
+}
+
+// sheetStyleRules sheet中 apply_styles 声明的样式区域，按优先级分组
+type sheetStyleRules struct {
+	columns, rows, cells []styleRule
+}
+
+// parseStyleRules 解析sheet的 apply_styles，样式名从当前sheet可用的命名样式中查找
+func parseStyleRules(f *workbook, sheetMap map[string]interface{}) (*sheetStyleRules, error) {
+	rules := &sheetStyleRules{}
+	raw, ok := sheetMap["apply_styles"]
+	if !ok {
+		return rules, nil
+	}
+	var refs model.StyleRefs
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid apply_styles: %v", ErrInvalidData, err)
+	}
+	if err := json.Unmarshal(encoded, &refs); err != nil {
+		return nil, fmt.Errorf("%w: invalid apply_styles: %v", ErrInvalidData, err)
+	}
+
+	groups := []struct {
+		refs  map[string]string
+		parse func(string) (styleRule, error)
+		out   *[]styleRule
+	}{
+		{refs.Columns, parseColumnRange, &rules.columns},
+		{refs.Rows, parseRowRange, &rules.rows},
+		{refs.Cells, parseCellRange, &rules.cells},
+	}
+	for _, group := range groups {
+		// 按区域排序，同组区域重叠时结果固定
+		areas := make([]string, 0, len(group.refs))
+		for area := range group.refs {
+			areas = append(areas, area)
+		}
+		sort.Strings(areas)
+		for _, area := range areas {
+			rule, err := group.parse(area)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid apply_styles area %q: %v", ErrInvalidData, area, err)
+			}
+			ids, err := f.namedStyles(group.refs[area])
+			if err != nil {
+				return nil, err
+			}
+			rule.styleID = ids[0]
+			*group.out = append(*group.out, rule)
+		}
+	}
+	return rules, nil
+}
+
+// apply 将样式应用到已填充的sheet，依次应用列、行和单元格，优先级高的后应用
+func (r *sheetStyleRules) apply(f *workbook, sheetName string) error {
+	for _, rule := range r.columns {
+		start, _ := excelize.ColumnNumberToName(rule.startCol)
+		end, _ := excelize.ColumnNumberToName(rule.endCol)
+		if err := f.SetColStyle(sheetName, start+":"+end, rule.styleID); err != nil {
+			return fmt.Errorf("failed to set column style: %v", err)
+		}
+	}
+	for _, rule := range r.rows {
+		if err := f.SetRowStyle(sheetName, rule.startRow, rule.endRow, rule.styleID); err != nil {
+			return fmt.Errorf("failed to set row style: %v", err)
+		}
+	}
+	for _, rule := range r.cells {
+		start, _ := excelize.CoordinatesToCellName(rule.startCol, rule.startRow)
+		end, _ := excelize.CoordinatesToCellName(rule.endCol, rule.endRow)
+		if err := f.SetCellStyle(sheetName, start, end, rule.styleID); err != nil {
+			return fmt.Errorf("failed to set cell style: %v", err)
+		}
+	}
+	return nil
+}
+
+// cellStyle 流式写入时单元格的样式：单元格 > 行 > 列，均未声明时返回 false
+func (r *sheetStyleRules) cellStyle(col, row int) (int, bool) {
+	for _, group := range [][]styleRule{r.cells, r.rows, r.columns} {
+		for i := len(group) - 1; i >= 0; i-- {
+			if group[i].contains(col, row) {
+				return group[i].styleID, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// rowStyle 流式写入时整行的样式，未声明时返回0
+func (r *sheetStyleRules) rowStyle(row int) int {
+	for i := len(r.rows) - 1; i >= 0; i-- {
+		if r.rows[i].contains(0, row) {
+			return r.rows[i].styleID
+		}
+	}
+	return 0
+}
+
+// streamColumns 流式写入前设置列样式，需在写入行之前调用
+func (r *sheetStyleRules) streamColumns(sw *excelize.StreamWriter) error {
+	for _, rule := range r.columns {
+		if err := sw.SetColStyle(rule.startCol, rule.endCol, rule.styleID); err != nil {
+			return fmt.Errorf("failed to set column style: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseColumnRange 解析列或列范围，如 "G"、"G:H"
+func parseColumnRange(area string) (styleRule, error) {
+	start, end := splitRange(area)
+	startCol, err := excelize.ColumnNameToNumber(start)
+	if err != nil {
+		return styleRule{}, err
+	}
+	endCol, err := excelize.ColumnNameToNumber(end)
+	if err != nil {
+		return styleRule{}, err
+	}
+	if endCol < startCol {
+		startCol, endCol = endCol, startCol
+	}
+	return styleRule{startCol: startCol, endCol: endCol}, nil
+}
+
+// parseRowRange 解析行号或行范围，如 "5"、"6:8"
+func parseRowRange(area string) (styleRule, error) {
+	start, end := splitRange(area)
+	startRow, err := strconv.Atoi(start)
+	if err != nil || startRow < 1 {
+		return styleRule{}, fmt.Errorf("invalid row number %s", start)
+	}
+	endRow, err := strconv.Atoi(end)
+	if err != nil || endRow < 1 {
+		return styleRule{}, fmt.Errorf("invalid row number %s", end)
+	}
+	if endRow < startRow {
+		startRow, endRow = endRow, startRow
+	}
+	return styleRule{startRow: startRow, endRow: endRow}, nil
+}
+
+// parseCellRange 解析单元格或单元格范围，如 "A1"、"A2:D3"
+func parseCellRange(area string) (styleRule, error) {
+	start, end := splitRange(area)
+	startCol, startRow, err := excelize.CellNameToCoordinates(start)
+	if err != nil {
+		return styleRule{}, err
+	}
+	endCol, endRow, err := excelize.CellNameToCoordinates(end)
+	if err != nil {
+		return styleRule{}, err
+	}
+	if endCol < startCol {
+		startCol, endCol = endCol, startCol
+	}
+	if endRow < startRow {
+		startRow, endRow = endRow, startRow
+	}
+	return styleRule{startCol: startCol, startRow: startRow, endCol: endCol, endRow: endRow}, nil
+}
+
+// splitRange 拆分 "start:end" 形式的范围，单个值时起止相同
+func splitRange(area string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(area), ":", 2)
+	if len(parts) == 1 {
+		return parts[0], parts[0]
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
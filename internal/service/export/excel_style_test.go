@@ -0,0 +1,75 @@
+package export
+
+import (
+	"fmt"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWorkbookNewStyleReusesID(t *testing.T) {
+	f := newWorkbook(excelize.NewFile())
+	defer f.Close()
+
+	header, err := f.NewStyle(tableHeaderStyle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cell, err := f.NewStyle(tableCellStyle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == cell {
+		t.Fatalf("different styles share ID %d", header)
+	}
+	for i := 0; i < 3; i++ {
+		if id, err := f.NewStyle(tableHeaderStyle()); err != nil || id != header {
+			t.Fatalf("NewStyle = %d, %v; want cached ID %d", id, err, header)
+		}
+	}
+	if len(f.styles) != 2 {
+		t.Errorf("registry holds %d styles, want 2", len(f.styles))
+	}
+}
+
+// styledBenchmarkSheets 生成 sheets 个表格模式的sheet，共 rows 行数据
+func styledBenchmarkSheets(sheets, rows int) []interface{} {
+	headers := []interface{}{"序号", "品名", "规格", "颜色", "数量", "单价", "总价", "备注"}
+	result := make([]interface{}, sheets)
+	for s := range result {
+		sheetRows := make([]interface{}, rows/sheets)
+		for r := range sheetRows {
+			sheetRows[r] = []interface{}{float64(r + 1), "大班台", "2400*2000*750", "黑色", 2.0, 10141.0, 20282.0, ""}
+		}
+		result[s] = map[string]interface{}{
+			"name":    fmt.Sprintf("Sheet%d", s+1),
+			"headers": []interface{}{headers},
+			"rows":    sheetRows,
+		}
+	}
+	return result
+}
+
+// BenchmarkExportStyled50Sheets 50个sheet、共1万行带样式数据的导出
+func BenchmarkExportStyled50Sheets(b *testing.B) {
+	old := config.Get()
+	cfg := *old
+	cfg.Template.Path = "../../../templates"
+	config.Set(&cfg)
+	defer config.Set(old)
+
+	sheets := styledBenchmarkSheets(50, 10000)
+	xs := NewExcelService(template.NewTemplateService())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req := &model.ExportRequest{Data: map[string]interface{}{"sheets": sheets}}
+		if _, err := xs.ExportExcel(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
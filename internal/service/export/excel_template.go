@@ -0,0 +1,224 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"office-export-server/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// fillPlaceholderTemplate 通用模式：按模板单元格中的 {{field}} 占位符填充数据，
+// 带有 {{#items}}…{{/items}} 标记的行按数组元素逐行复制
+func (s *ExcelService) fillPlaceholderTemplate(f *workbook, sheetName string, req *model.ExportRequest) error {
+	// 读取模板原始内容，先记录行标记，避免数据中的文本被当作标记
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("failed to read template sheet: %v", err)
+	}
+	scope, totals, err := newTemplateScope(req.Data, rows)
+	if err != nil {
+		return err
+	}
+	markers := templateRowMarkers(rows)
+	formulas, err := parseColumnFormulas(req.Data)
+	if err != nil {
+		return err
+	}
+	// 先按展开后的行号确定公式的位置，公式在全部循环行展开之后写入，避免插入行时被再次调整
+	starts, counts := templateRowSpans(len(rows), markers, scope)
+	itemRows := templateItemRows(markers, starts, counts, totals.pricing.Items)
+
+	// 填充普通单元格
+	for r, row := range rows {
+		if _, ok := markers[r]; ok {
+			continue
+		}
+		if err := setTemplateRow(f.File, sheetName, r+1, row, scope); err != nil {
+			return err
+		}
+	}
+
+	// 从下往上展开循环行，插入的行不会影响尚未处理的行号
+	for r := len(rows) - 1; r >= 0; r-- {
+		name, ok := markers[r]
+		if !ok {
+			continue
+		}
+
+		row := stripRowMarker(rows[r], name)
+
+		value, _ := scope.lookup(name)
+		list := toItems(value)
+		if len(list) == 0 {
+			if err := f.RemoveRow(sheetName, r+1); err != nil {
+				return fmt.Errorf("failed to remove row %d: %v", r+1, err)
+			}
+			continue
+		}
+		for i := 1; i < len(list); i++ {
+			if err := f.DuplicateRow(sheetName, r+1); err != nil {
+				return fmt.Errorf("failed to duplicate row %d: %v", r+1, err)
+			}
+		}
+		for i, item := range list {
+			if err := setTemplateRow(f.File, sheetName, r+1+i, row, templateItemScope(scope, totals, name, item, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 写入公式单元格和明细行的列公式
+	for r, row := range rows {
+		if name, ok := markers[r]; ok {
+			row = stripRowMarker(row, name)
+		}
+		for i := 0; i < counts[r]; i++ {
+			refs := formulaRows{row: starts[r] + i, first: itemRows.first, last: itemRows.last}
+			for c, text := range row {
+				m := formulaCellRe.FindStringSubmatch(text)
+				if m == nil {
+					continue
+				}
+				cell, err := excelize.CoordinatesToCellName(c+1, refs.row)
+				if err != nil {
+					return err
+				}
+				if err := refs.setFormula(f, sheetName, cell, m[1]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return formulas.apply(f, sheetName, itemRows.first, itemRows.last)
+}
+
+// templateRowSpans 计算模板前 n 行在输出中的起始行号和行数：循环行按数组元素展开，数组为空时该行被删除
+func templateRowSpans(n int, markers map[int]string, scope *dataScope) ([]int, []int) {
+	starts := make([]int, n)
+	counts := make([]int, n)
+	rowNum := 1
+	for r := 0; r < n; r++ {
+		starts[r] = rowNum
+		counts[r] = 1
+		if name, ok := markers[r]; ok {
+			value, _ := scope.lookup(name)
+			counts[r] = len(toItems(value))
+		}
+		rowNum += counts[r]
+	}
+	return starts, counts
+}
+
+// templateItemRows 计价明细循环行展开后的行范围，模板中没有明细循环行时范围为空
+func templateItemRows(markers map[int]string, starts, counts []int, items string) formulaRows {
+	for r, name := range markers {
+		if name == items && r < len(starts) {
+			return formulaRows{first: starts[r], last: starts[r] + counts[r] - 1}
+		}
+	}
+	return formulaRows{first: 1, last: 0}
+}
+
+// newTemplateScope 创建占位符模板的数据作用域。模板引用了 {{totals.xxx}} 或 {{@amount}} 时才根据明细计算金额汇总，
+// 没有金额占位符的模板不要求明细中的单价和数量为数字；未计算时返回的汇总只包含 pricing
+func newTemplateScope(data map[string]interface{}, rows [][]string) (*dataScope, *orderTotals, error) {
+	scope := newDataScope(data, nil)
+	pricing, err := parsePricing(data, "items")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rowsUseTotals(rows) {
+		return scope, &orderTotals{pricing: pricing}, nil
+	}
+	items, _ := scope.lookup(pricing.Items)
+	totals, err := computeTotals(toItems(items), pricing)
+	if err != nil {
+		return nil, nil, err
+	}
+	scope.setVar("totals", totals.numbers())
+	return scope, totals, nil
+}
+
+// rowsUseTotals 判断模板单元格中是否引用了金额汇总
+func rowsUseTotals(rows [][]string) bool {
+	for _, row := range rows {
+		for _, text := range row {
+			if referencesTotals(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// templateItemScope 循环行中第 index 个元素的作用域，明细行可通过 {{@amount}} 引用行金额
+func templateItemScope(scope *dataScope, totals *orderTotals, name string, item interface{}, index int) *dataScope {
+	itemScope := scope.child(item, index)
+	if name == totals.pricing.Items && index < len(totals.Lines) {
+		itemScope.setVar("@amount", moneyFloat(totals.Lines[index]))
+	}
+	return itemScope
+}
+
+// templateRowMarkers 记录带有 {{#name}} 循环标记的行（从0开始）及其数组字段名
+func templateRowMarkers(rows [][]string) map[int]string {
+	markers := make(map[int]string)
+	for r, row := range rows {
+		for _, text := range row {
+			if m := rowMarkerRe.FindStringSubmatch(text); m != nil {
+				markers[r] = m[1]
+				break
+			}
+		}
+	}
+	return markers
+}
+
+// stripRowMarker 去掉循环行中的 {{#name}} 和 {{/name}} 标记
+func stripRowMarker(row []string, name string) []string {
+	stripped := make([]string, len(row))
+	for c, text := range row {
+		text = strings.ReplaceAll(text, "{{#"+name+"}}", "")
+		stripped[c] = strings.ReplaceAll(text, "{{/"+name+"}}", "")
+	}
+	return stripped
+}
+
+// setTemplateRow 替换一行中包含占位符的单元格
+func setTemplateRow(f *excelize.File, sheetName string, rowNum int, row []string, scope *dataScope) error {
+	for c, text := range row {
+		// 公式单元格在循环行展开后单独写入
+		if !strings.Contains(text, "{{") || formulaCellRe.MatchString(text) {
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(c+1, rowNum)
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellValue(sheetName, cell, templateCellValue(text, scope)); err != nil {
+			return fmt.Errorf("failed to set cell %s: %v", cell, err)
+		}
+	}
+	return nil
+}
+
+// templateCellValue 计算单元格的值：单元格只有一个占位符且取值为数字时保留数值类型，便于Excel计算
+func templateCellValue(text string, scope *dataScope) interface{} {
+	trimmed := strings.TrimSpace(text)
+	if m := placeholderRe.FindString(trimmed); m != "" && m == trimmed {
+		value, _ := scope.evaluate(placeholderName(m))
+		switch v := value.(type) {
+		case float64, float32, int, int64, bool:
+			return v
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return f
+			}
+		}
+		return formatValue(value)
+	}
+	return renderText(text, scope)
+}
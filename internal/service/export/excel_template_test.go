@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// useTemplateDir 将 files（相对模板目录的路径到内容）写入临时模板目录并切换为本地模板存储，测试结束后恢复配置
+func useTemplateDir(t testing.TB, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := config.Get()
+	cfg := *old
+	cfg.Template.Storage = "fs"
+	cfg.Template.Path = dir
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+	return dir
+}
+
+// placeholderWorkbook 生成只有一个sheet的占位符模板，cells 为单元格到内容
+func placeholderWorkbook(t testing.TB, cells map[string]interface{}) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for cell, value := range cells {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exportSheet 用模板 templateID 导出一个名为 name 的sheet，stream 为 true 时使用流式写入
+func exportSheet(t testing.TB, templateID string, sheet map[string]interface{}, stream bool) *excelize.File {
+	t.Helper()
+	xs := NewExcelService(template.NewTemplateService())
+	req := &model.ExportRequest{TemplateID: templateID, Stream: stream, Data: map[string]interface{}{"sheets": []interface{}{sheet}}}
+	return readWorkbook(t, xs, req)
+}
+
+// readWorkbook 导出Excel并重新打开，req.Stream 为 true 时使用流式写入
+func readWorkbook(t testing.TB, xs *ExcelService, req *model.ExportRequest) *excelize.File {
+	t.Helper()
+	var data []byte
+	if req.Stream {
+		file, err := xs.ExportExcelStream(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := file.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		data = buf.Bytes()
+	} else {
+		var err error
+		if data, err = xs.ExportExcel(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestPlaceholderTemplateWithoutTotals(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/plain.xlsx": placeholderWorkbook(t, map[string]interface{}{
+			"A1": "{{title}}",
+			"A2": "{{#items}}{{name}}",
+			"B2": "{{数量}}{{/items}}",
+		}),
+	})
+	sheet := map[string]interface{}{
+		"name":  "清单",
+		"title": "施工清单",
+		"items": []interface{}{
+			map[string]interface{}{"name": "灯具", "数量": "1套", "单价": 100.0},
+			map[string]interface{}{"name": "拆除", "数量": "按实结算"},
+		},
+	}
+
+	for _, stream := range []bool{false, true} {
+		f := exportSheet(t, "plain", sheet, stream)
+		for cell, want := range map[string]string{"A1": "施工清单", "A2": "灯具", "B2": "1套", "A3": "拆除", "B3": "按实结算"} {
+			if got, _ := f.GetCellValue("清单", cell); got != want {
+				t.Errorf("stream=%v: %s = %q, want %q", stream, cell, got, want)
+			}
+		}
+	}
+}
+
+func TestPlaceholderTemplateTotalsRequireNumbers(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/priced.xlsx": placeholderWorkbook(t, map[string]interface{}{
+			"A1": "{{#items}}{{name}}",
+			"B1": "{{数量}}{{/items}}",
+			"A2": "{{totals.total}}",
+		}),
+	})
+	sheet := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"name": "灯具", "数量": "1套", "单价": 100.0}},
+	}
+
+	xs := NewExcelService(template.NewTemplateService())
+	req := &model.ExportRequest{TemplateID: "priced", Data: map[string]interface{}{"sheets": []interface{}{sheet}}}
+	if _, err := xs.ExportExcel(req); err == nil || !strings.Contains(err.Error(), "数量 is not a valid number") {
+		t.Errorf("ExportExcel error = %v, want 数量 is not a valid number", err)
+	}
+}
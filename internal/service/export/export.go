@@ -1,44 +1,206 @@
 package export
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
 	"office-export-server/internal/model"
 	"office-export-server/internal/service/template"
 )
 
+// ErrInvalidData 请求数据不符合导出要求，如缺少明细数组、数值无法解析，属于客户端错误
+var ErrInvalidData = errors.New("invalid export data")
+
 // ExportService 导出服务接口
 type ExportService interface {
 	ExportExcel(req *model.ExportRequest) ([]byte, error)
+	ExportExcelStream(req *model.ExportRequest) (StreamedFile, error)
 	ExportWord(req *model.ExportRequest) ([]byte, error)
 	ExportPDF(req *model.ExportRequest) ([]byte, error)
+	Export(fileType string, req *model.ExportRequest) ([]byte, error)
+	ExportBatch(items []model.BatchExportItem, w io.Writer) (*model.BatchManifest, error)
+	Validate(fileType string, req *model.ExportRequest) ([]model.FieldError, error)
+	ApplyPreview(fileType string, req *model.ExportRequest) error
+	Assets(fileType string, req *model.ExportRequest) ([]string, error)
+}
+
+// FileFormat 导出文件格式
+type FileFormat struct {
+	Extension   string
+	ContentType string
+}
+
+// fileFormats 支持的导出文件类型
+var fileFormats = map[string]FileFormat{
+	"excel": {Extension: ".xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"word":  {Extension: ".docx", ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	"pdf":   {Extension: ".pdf", ContentType: "application/pdf"},
+}
+
+// LookupFormat 获取文件类型对应的导出格式，不支持的类型返回 false
+func LookupFormat(fileType string) (FileFormat, bool) {
+	format, ok := fileFormats[fileType]
+	return format, ok
 }
 
 // exportService 导出服务实现
 type exportService struct {
-	excelService *ExcelService
-	wordService  *WordService
-	pdfService   *PDFService
+	templateService template.TemplateService
+	excelService    *ExcelService
+	wordService     *WordService
+	pdfService      *PDFService
+	validator       *schemaValidator
 }
 
 // NewExportService 创建导出服务实例
 func NewExportService(templateService template.TemplateService) ExportService {
+	return newExportService(templateService)
+}
+
+// newExportService 创建使用指定模板服务的导出服务
+func newExportService(templateService template.TemplateService) *exportService {
 	return &exportService{
-		excelService: NewExcelService(templateService),
-		wordService:  NewWordService(),
-		pdfService:   NewPDFService(),
+		templateService: templateService,
+		excelService:    NewExcelService(templateService),
+		wordService:     NewWordService(templateService),
+		pdfService:      NewPDFService(templateService),
+		validator:       newSchemaValidator(templateService),
+	}
+}
+
+// forRequest 请求通过 template_version 固定了模板版本时，返回使用该版本模板的导出服务
+func (s *exportService) forRequest(fileType string, req *model.ExportRequest) (*exportService, error) {
+	if req.TemplateVersion == 0 {
+		return s, nil
+	}
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
 	}
+	pinned, err := s.templateService.Pin(templateID, fileType, req.TemplateVersion)
+	if err != nil {
+		return nil, err
+	}
+	svc := newExportService(pinned)
+	// 编译后的Schema按模板状态缓存，固定版本与当前版本的状态不同，可以共用缓存
+	svc.validator.schemas = s.validator.schemas
+	return svc, nil
 }
 
 // ExportExcel 导出Excel文件
 func (s *exportService) ExportExcel(req *model.ExportRequest) ([]byte, error) {
-	return s.excelService.ExportExcel(req)
+	svc, err := s.forRequest("excel", req)
+	if err != nil {
+		return nil, err
+	}
+	return svc.excelService.ExportExcel(req)
+}
+
+// ExportExcelStream 流式导出Excel文件
+func (s *exportService) ExportExcelStream(req *model.ExportRequest) (StreamedFile, error) {
+	svc, err := s.forRequest("excel", req)
+	if err != nil {
+		return nil, err
+	}
+	return svc.excelService.ExportExcelStream(req)
 }
 
 // ExportWord 导出Word文件
 func (s *exportService) ExportWord(req *model.ExportRequest) ([]byte, error) {
-	return s.wordService.ExportWord(req)
+	svc, err := s.forRequest("word", req)
+	if err != nil {
+		return nil, err
+	}
+	return svc.wordService.ExportWord(req)
 }
 
 // ExportPDF 导出PDF文件
 func (s *exportService) ExportPDF(req *model.ExportRequest) ([]byte, error) {
-	return s.pdfService.ExportPDF(req)
+	svc, err := s.forRequest("pdf", req)
+	if err != nil {
+		return nil, err
+	}
+	return svc.pdfService.ExportPDF(req)
+}
+
+// Export 按文件类型导出
+func (s *exportService) Export(fileType string, req *model.ExportRequest) ([]byte, error) {
+	switch fileType {
+	case "excel":
+		return s.ExportExcel(req)
+	case "word":
+		return s.ExportWord(req)
+	case "pdf":
+		return s.ExportPDF(req)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+// Validate 按模板声明的JSON Schema校验请求数据
+func (s *exportService) Validate(fileType string, req *model.ExportRequest) ([]model.FieldError, error) {
+	svc, err := s.forRequest(fileType, req)
+	if err != nil {
+		return nil, err
+	}
+	return svc.validator.Validate(fileType, req)
+}
+
+// ApplyPreview 预览模式：以模板的示例数据作为导出数据，请求 data 中的字段覆盖示例数据的同名字段
+func (s *exportService) ApplyPreview(fileType string, req *model.ExportRequest) error {
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
+	}
+
+	svc, err := s.forRequest(fileType, req)
+	if err != nil {
+		return err
+	}
+	raw, err := svc.templateService.LoadSample(templateID, fileType)
+	if err != nil {
+		return err
+	}
+	var sample map[string]interface{}
+	if err := json.Unmarshal(raw, &sample); err != nil {
+		return fmt.Errorf("invalid sample data for template %s: %v", templateID, err)
+	}
+	// 示例数据文件内容为 null 时按空对象处理
+	if sample == nil {
+		sample = map[string]interface{}{}
+	}
+
+	for key, value := range req.Data {
+		sample[key] = value
+	}
+	req.Data = sample
+	return nil
+}
+
+// Assets 导出请求会从模板目录读取的资源文件：PDF版式的字体和封面图片，以及数据中引用的本地图片，按路径排序
+func (s *exportService) Assets(fileType string, req *model.ExportRequest) ([]string, error) {
+	svc, err := s.forRequest(fileType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []string
+	if fileType == "pdf" {
+		if assets, err = svc.pdfService.assets(req); err != nil {
+			return nil, err
+		}
+	}
+	assets = append(assets, localImagePaths(req.Data)...)
+
+	sort.Strings(assets)
+	unique := assets[:0]
+	for i, asset := range assets {
+		if i == 0 || asset != assets[i-1] {
+			unique = append(unique, asset)
+		}
+	}
+	return unique, nil
 }
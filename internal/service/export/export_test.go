@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportInvalidData(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"excel/priced.xlsx": placeholderWorkbook(t, map[string]interface{}{
+			"A1": "{{#items}}{{name}}{{/items}}",
+			"A2": "{{totals.total}}",
+		}),
+	})
+	s := NewExportService(template.NewTemplateService())
+	sheet := func(data map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"sheets": []interface{}{data}}
+	}
+
+	tests := []struct {
+		name     string
+		fileType string
+		req      *model.ExportRequest
+	}{
+		{"excel without sheets", "excel", &model.ExportRequest{Data: map[string]interface{}{}}},
+		{"excel invalid number", "excel", &model.ExportRequest{TemplateID: "priced", Data: sheet(map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"name": "灯具", "数量": "1套", "单价": 100.0}},
+		})}},
+		{"excel invalid rules", "excel", &model.ExportRequest{TemplateID: "priced", Data: sheet(map[string]interface{}{
+			"items":       []interface{}{},
+			"validations": "A1",
+		})}},
+		{"pdf invalid layout", "pdf", &model.ExportRequest{Data: map[string]interface{}{"layout": "A4"}}},
+	}
+	for _, tt := range tests {
+		_, err := s.Export(tt.fileType, tt.req)
+		if !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%s: error = %v, want ErrInvalidData", tt.name, err)
+		}
+	}
+
+	if _, err := s.Export("excel", &model.ExportRequest{TemplateID: "missing", Data: sheet(map[string]interface{}{})}); err == nil || errors.Is(err, ErrInvalidData) {
+		t.Errorf("missing template: error = %v, want a non-client error", err)
+	}
+}
+
+func TestExportPinnedVersion(t *testing.T) {
+	useTemplateDir(t, nil)
+	ts := template.NewTemplateService()
+	// upload 上传A1为 text 的模板
+	upload := func(text string) *model.TemplateUpload {
+		return &model.TemplateUpload{ID: "offer", Type: "excel", Template: placeholderWorkbook(t, map[string]interface{}{"A1": text})}
+	}
+	if _, err := ts.CreateTemplate(upload("版本1 {{title}}")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.PublishVersion(upload("版本2 {{title}}")); err != nil {
+		t.Fatal(err)
+	}
+	s := NewExportService(ts)
+	sheet := map[string]interface{}{"name": "报价", "title": "报价单"}
+
+	for version, want := range map[int]string{0: "版本2 报价单", 1: "版本1 报价单", 2: "版本2 报价单"} {
+		req := &model.ExportRequest{TemplateID: "offer", TemplateVersion: version, Data: map[string]interface{}{"sheets": []interface{}{sheet}}}
+		if errs, err := s.Validate("excel", req); err != nil || len(errs) != 0 {
+			t.Fatalf("Validate(version %d) = %v, %v", version, errs, err)
+		}
+		data, err := s.Export("excel", req)
+		if err != nil {
+			t.Fatalf("Export(version %d): %v", version, err)
+		}
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := f.GetCellValue("报价", "A1"); got != want {
+			t.Errorf("version %d: A1 = %q, want %q", version, got, want)
+		}
+		f.Close()
+	}
+
+	req := &model.ExportRequest{TemplateID: "offer", TemplateVersion: 3, Data: map[string]interface{}{"sheets": []interface{}{sheet}}}
+	if _, err := s.Validate("excel", req); err != template.ErrVersionNotFound {
+		t.Errorf("Validate(version 3) = %v, want ErrVersionNotFound", err)
+	}
+	if _, err := s.Export("excel", req); err != template.ErrVersionNotFound {
+		t.Errorf("Export(version 3) = %v, want ErrVersionNotFound", err)
+	}
+}
@@ -0,0 +1,216 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/netutil"
+	"office-export-server/internal/service/template"
+)
+
+// imageHTTPClient 下载图片使用的HTTP客户端，只能访问 image 配置允许的地址
+var imageHTTPClient = netutil.NewClient(30*time.Second, imagePolicy)
+
+// imagePolicy 允许下载图片的地址范围
+func imagePolicy() netutil.Policy {
+	cfg := config.Get().Image
+	return netutil.Policy{AllowPrivate: cfg.AllowPrivate, AllowedHosts: cfg.AllowedHosts}
+}
+
+// imageExtensions 支持的图片类型与扩展名
+var imageExtensions = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+	"image/bmp":  "bmp",
+}
+
+// loadedImage 已加载的图片
+type loadedImage struct {
+	data   []byte
+	ext    string
+	width  int
+	height int
+}
+
+// parseImageData 解析请求中的图片值，支持字符串或 {"path": ..., "size": {...}} 对象
+func parseImageData(value interface{}) (*model.ImageData, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return &model.ImageData{Path: v}, nil
+	case map[string]interface{}:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var img model.ImageData
+		if err := json.Unmarshal(raw, &img); err != nil {
+			return nil, fmt.Errorf("invalid image data: %v", err)
+		}
+		if img.Path == "" {
+			return nil, nil
+		}
+		return &img, nil
+	default:
+		return nil, fmt.Errorf("invalid image data type: %T", value)
+	}
+}
+
+// isLocalImage 判断图片地址是否为模板目录下的相对路径
+func isLocalImage(src string) bool {
+	return src != "" && !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "data:")
+}
+
+// localImagePaths 收集数据中引用的模板目录图片（扩展名为常见图片格式的相对路径）
+func localImagePaths(data interface{}) []string {
+	var paths []string
+	switch v := data.(type) {
+	case string:
+		switch strings.ToLower(path.Ext(v)) {
+		case ".png", ".jpg", ".jpeg", ".gif", ".bmp":
+			if isLocalImage(v) {
+				paths = append(paths, v)
+			}
+		}
+	case map[string]interface{}:
+		for _, value := range v {
+			paths = append(paths, localImagePaths(value)...)
+		}
+	case []interface{}:
+		for _, value := range v {
+			paths = append(paths, localImagePaths(value)...)
+		}
+	}
+	return paths
+}
+
+// loadImage 加载图片，支持 http(s) URL、data: URI 以及模板目录下的相对路径
+func loadImage(src string, templateService template.TemplateService) (*loadedImage, error) {
+	var data []byte
+	var err error
+
+	switch {
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		data, err = downloadImage(src)
+	case strings.HasPrefix(src, "data:"):
+		data, err = decodeDataURI(src)
+	default:
+		data, err = templateService.LoadAsset(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ext, ok := imageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image type: %s", http.DetectContentType(data))
+	}
+
+	img := &loadedImage{data: data, ext: ext}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.width = cfg.Width
+		img.height = cfg.Height
+	}
+	return img, nil
+}
+
+// downloadImage 下载图片：只允许 image 配置范围内的 http(s) 地址，响应须为图片且不超过 image.max_size
+func downloadImage(imageURL string) ([]byte, error) {
+	u, err := imagePolicy().CheckURL(imageURL)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败：%v", err)
+	}
+
+	resp, err := imageHTTPClient.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败：%v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败：HTTP %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("下载图片失败：响应不是图片（Content-Type: %s）", resp.Header.Get("Content-Type"))
+	}
+
+	maxSize := config.Get().Image.MaxSize
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("下载图片失败：图片超过 %d 字节", maxSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败：%v", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("下载图片失败：图片超过 %d 字节", maxSize)
+	}
+	return data, nil
+}
+
+// decodeDataURI 解析 data:image/png;base64,... 形式的图片
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+
+	meta, payload := uri[len("data:"):comma], uri[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 image data: %v", err)
+		}
+		return data, nil
+	}
+
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URI: %v", err)
+	}
+	return []byte(data), nil
+}
+
+// fitImageSize 计算图片显示尺寸（像素），只指定宽或高时按原图比例缩放
+func fitImageSize(img *loadedImage, size model.ImageSize, maxWidth float64) (float64, float64) {
+	width, height := size.Width, size.Height
+	naturalW, naturalH := float64(img.width), float64(img.height)
+	if naturalW == 0 || naturalH == 0 {
+		naturalW, naturalH = maxWidth, maxWidth*3/4
+	}
+
+	switch {
+	case width > 0 && height > 0:
+	case width > 0:
+		height = width * naturalH / naturalW
+	case height > 0:
+		width = height * naturalW / naturalH
+	default:
+		width, height = naturalW, naturalH
+		if width > maxWidth {
+			width, height = maxWidth, maxWidth*naturalH/naturalW
+		}
+	}
+	return width, height
+}
@@ -0,0 +1,267 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"office-export-server/internal/model"
+)
+
+// 未配置字段名时，依次尝试的单价和数量字段
+var (
+	priceFieldAliases    = []string{"price", "单价", "预算价"}
+	quantityFieldAliases = []string{"quantity", "数量", "工程量"}
+)
+
+// amountCleaner 去除金额中的货币符号、千分位和空白
+var amountCleaner = strings.NewReplacer("¥", "", "￥", "", ",", "", " ", "")
+
+// parseDecimal 将数据值精确解析为有理数，支持数字和 "¥1,234.50" 形式的文本
+func parseDecimal(value interface{}) (*big.Rat, bool) {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		text = amountCleaner.Replace(v)
+	case float64:
+		// 使用最短十进制表示，避免 0.1 等二进制浮点误差
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case json.Number:
+		text = v.String()
+	default:
+		return nil, false
+	}
+
+	if text == "" {
+		return nil, false
+	}
+	r, ok := new(big.Rat).SetString(text)
+	return r, ok
+}
+
+// roundCents 按分四舍五入（远离零）
+func roundCents(r *big.Rat) *big.Rat {
+	scaled := new(big.Rat).Mul(r, big.NewRat(100, 1))
+	num, den := new(big.Int).Abs(scaled.Num()), scaled.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return new(big.Rat).SetFrac(quo, big.NewInt(100))
+}
+
+// formatMoney 金额格式化为两位小数
+func formatMoney(r *big.Rat) string {
+	return roundCents(r).FloatString(2)
+}
+
+// moneyFloat 金额转换为float64，用于写入Excel数值单元格
+func moneyFloat(r *big.Rat) float64 {
+	f, _ := roundCents(r).Float64()
+	return f
+}
+
+// percentOf 计算金额的百分比，结果按分四舍五入
+func percentOf(amount *big.Rat, rate float64) (*big.Rat, error) {
+	if rate == 0 {
+		return new(big.Rat), nil
+	}
+	r, ok := parseDecimal(rate)
+	if !ok {
+		return nil, fmt.Errorf("invalid rate: %v", rate)
+	}
+	result := new(big.Rat).Mul(amount, r)
+	return roundCents(result.Quo(result, big.NewRat(100, 1))), nil
+}
+
+// orderTotals 由明细计算的金额汇总
+type orderTotals struct {
+	pricing    model.Pricing
+	Lines      []*big.Rat // 每行金额（单价×数量）
+	Subtotal   *big.Rat   // 明细合计
+	Discount   *big.Rat   // 优惠金额
+	Discounted *big.Rat   // 优惠后金额
+	ServiceFee *big.Rat   // 服务费（按优惠后金额计算）
+	Tax        *big.Rat   // 税额（按优惠后金额加服务费计算）
+	Total      *big.Rat   // 总计
+}
+
+// parsePricing 读取请求中的 data.pricing 配置，defaultItems 为未配置时的明细字段名
+func parsePricing(data map[string]interface{}, defaultItems string) (model.Pricing, error) {
+	pricing := model.Pricing{Items: defaultItems}
+	if raw, ok := data["pricing"]; ok && raw != nil {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return pricing, fmt.Errorf("%w: invalid pricing: %v", ErrInvalidData, err)
+		}
+		if err := json.Unmarshal(encoded, &pricing); err != nil {
+			return pricing, fmt.Errorf("%w: invalid pricing: %v", ErrInvalidData, err)
+		}
+		if pricing.Items == "" {
+			pricing.Items = defaultItems
+		}
+	}
+	return pricing, nil
+}
+
+// computeTotals 根据明细计算小计、优惠、服务费、税额和总计
+func computeTotals(items []interface{}, pricing model.Pricing) (*orderTotals, error) {
+	totals := &orderTotals{pricing: pricing, Subtotal: new(big.Rat)}
+
+	for i, item := range items {
+		line, err := lineAmount(item, pricing)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s[%d]: %v", ErrInvalidData, pricing.Items, i, err)
+		}
+		totals.Lines = append(totals.Lines, line)
+		totals.Subtotal.Add(totals.Subtotal, line)
+	}
+
+	var err error
+	if totals.Discount, err = percentOf(totals.Subtotal, pricing.DiscountRate); err != nil {
+		return nil, err
+	}
+	totals.Discounted = new(big.Rat).Sub(totals.Subtotal, totals.Discount)
+
+	if totals.ServiceFee, err = percentOf(totals.Discounted, pricing.ServiceFeeRate); err != nil {
+		return nil, err
+	}
+	taxable := new(big.Rat).Add(totals.Discounted, totals.ServiceFee)
+
+	if totals.Tax, err = percentOf(taxable, pricing.TaxRate); err != nil {
+		return nil, err
+	}
+	totals.Total = new(big.Rat).Add(taxable, totals.Tax)
+
+	return totals, nil
+}
+
+// lineAmount 计算单行金额：单价×数量，按分四舍五入；缺少单价或数量时金额为0
+func lineAmount(item interface{}, pricing model.Pricing) (*big.Rat, error) {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok {
+		return new(big.Rat), nil
+	}
+
+	price, priceField, err := itemDecimal(itemMap, pricing.PriceField, priceFieldAliases)
+	if err != nil {
+		return nil, err
+	}
+	quantity, quantityField, err := itemDecimal(itemMap, pricing.QuantityField, quantityFieldAliases)
+	if err != nil {
+		return nil, err
+	}
+	if priceField == "" || quantityField == "" {
+		return new(big.Rat), nil
+	}
+
+	return roundCents(new(big.Rat).Mul(price, quantity)), nil
+}
+
+// itemDecimal 读取明细中的数值字段，field 为空时依次尝试别名
+func itemDecimal(item map[string]interface{}, field string, aliases []string) (*big.Rat, string, error) {
+	candidates := aliases
+	if field != "" {
+		candidates = []string{field}
+	}
+
+	for _, name := range candidates {
+		value, ok := item[name]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		r, ok := parseDecimal(value)
+		if !ok {
+			return nil, "", fmt.Errorf("%s is not a valid number: %v", name, value)
+		}
+		return r, name, nil
+	}
+	return nil, "", nil
+}
+
+// lineValue 第i行金额的格式化文本
+func (t *orderTotals) lineValue(i int) string {
+	if i < 0 || i >= len(t.Lines) {
+		return ""
+	}
+	return formatMoney(t.Lines[i])
+}
+
+// amounts 汇总金额及其在 {{totals.xxx}} 中的名称
+func (t *orderTotals) amounts() map[string]*big.Rat {
+	return map[string]*big.Rat{
+		"subtotal":    t.Subtotal,
+		"discount":    t.Discount,
+		"discounted":  t.Discounted,
+		"service_fee": t.ServiceFee,
+		"tax":         t.Tax,
+		"total":       t.Total,
+	}
+}
+
+// rates 费率及其在 {{totals.xxx}} 中的名称
+func (t *orderTotals) rates() map[string]interface{} {
+	return map[string]interface{}{
+		"discount_rate":    t.pricing.DiscountRate,
+		"service_fee_rate": t.pricing.ServiceFeeRate,
+		"tax_rate":         t.pricing.TaxRate,
+	}
+}
+
+// values 汇总结果，供模板通过 {{totals.xxx}} 引用，金额为两位小数文本
+func (t *orderTotals) values() map[string]interface{} {
+	values := t.rates()
+	for name, amount := range t.amounts() {
+		values[name] = formatMoney(amount)
+	}
+	return values
+}
+
+// numbers 汇总结果的数值形式，供Excel写入数值单元格
+func (t *orderTotals) numbers() map[string]interface{} {
+	values := t.rates()
+	for name, amount := range t.amounts() {
+		values[name] = moneyFloat(amount)
+	}
+	return values
+}
+
+// totalsLine 汇总中的一行（标签、金额和费率）
+type totalsLine struct {
+	label  string
+	amount *big.Rat
+	rate   float64 // 计算该行的费率（%），优惠为负数，总计行为0
+}
+
+// adjustments 配置了费率时需要展示的优惠、服务费和税费行，优惠金额为负数
+func (t *orderTotals) adjustments() []totalsLine {
+	var lines []totalsLine
+	if t.pricing.DiscountRate != 0 {
+		lines = append(lines, totalsLine{"优惠(" + formatRate(t.pricing.DiscountRate) + ")", new(big.Rat).Neg(t.Discount), -t.pricing.DiscountRate})
+	}
+	if t.pricing.ServiceFeeRate != 0 {
+		lines = append(lines, totalsLine{"服务费(" + formatRate(t.pricing.ServiceFeeRate) + ")", t.ServiceFee, t.pricing.ServiceFeeRate})
+	}
+	if t.pricing.TaxRate != 0 {
+		lines = append(lines, totalsLine{"税费(" + formatRate(t.pricing.TaxRate) + ")", t.Tax, t.pricing.TaxRate})
+	}
+	return lines
+}
+
+// formatRate 费率格式化为百分比文本
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
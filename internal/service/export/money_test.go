@@ -0,0 +1,100 @@
+package export
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/model"
+)
+
+func TestPercentOf(t *testing.T) {
+	tests := []struct {
+		amount string
+		rate   float64
+		want   string
+	}{
+		{"100", 0, "0.00"},
+		{"100", 13, "13.00"},
+		{"10.05", 50, "5.03"},
+		{"-10.05", 50, "-5.03"},
+		{"1", 0.1, "0.00"},
+		{"0.1", 30, "0.03"},
+		{"399.99", 10, "40.00"},
+	}
+	for _, tt := range tests {
+		amount, _ := new(big.Rat).SetString(tt.amount)
+		got, err := percentOf(amount, tt.rate)
+		if err != nil || formatMoney(got) != tt.want {
+			t.Errorf("percentOf(%s, %v) = %v, %v; want %s", tt.amount, tt.rate, got, err, tt.want)
+		}
+	}
+}
+
+func TestComputeTotals(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"price": 100.0, "quantity": 3.0},
+		map[string]interface{}{"单价": "¥33.33", "数量": "3"},
+		map[string]interface{}{"price": 0.1, "quantity": 3.0},
+		map[string]interface{}{"name": "赠品"},
+		"不是对象",
+	}
+	pricing := model.Pricing{Items: "items", DiscountRate: 10, ServiceFeeRate: 5, TaxRate: 6}
+	totals, err := computeTotals(items, pricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for i := range totals.Lines {
+		lines = append(lines, totals.lineValue(i))
+	}
+	if got := strings.Join(lines, ","); got != "300.00,99.99,0.30,0.00,0.00" {
+		t.Errorf("lines = %s", got)
+	}
+	// 优惠按小计，服务费按优惠后金额，税费按优惠后金额加服务费，每一步按分四舍五入
+	want := map[string]string{
+		"subtotal":    "400.29",
+		"discount":    "40.03", // 40.029
+		"discounted":  "360.26",
+		"service_fee": "18.01", // 18.013
+		"tax":         "22.70", // (360.26+18.01)×6% = 22.6962
+		"total":       "400.97",
+	}
+	values := totals.values()
+	for name, amount := range want {
+		if values[name] != amount {
+			t.Errorf("totals.%s = %v, want %s", name, values[name], amount)
+		}
+	}
+	if values["tax_rate"] != 6.0 {
+		t.Errorf("totals.tax_rate = %v, want 6", values["tax_rate"])
+	}
+	if got := totals.numbers()["total"]; got != 400.97 {
+		t.Errorf("numbers total = %v, want 400.97", got)
+	}
+
+	var labels []string
+	for _, line := range totals.adjustments() {
+		labels = append(labels, line.label+"="+formatMoney(line.amount))
+	}
+	if got := strings.Join(labels, ","); got != "优惠(10%)=-40.03,服务费(5%)=18.01,税费(6%)=22.70" {
+		t.Errorf("adjustments = %s", got)
+	}
+}
+
+func TestComputeTotalsFields(t *testing.T) {
+	items := []interface{}{map[string]interface{}{"price": "abc", "含税单价": 10.0, "件数": 2.0}}
+
+	pricing := model.Pricing{Items: "rows", PriceField: "含税单价", QuantityField: "件数"}
+	totals, err := computeTotals(items, pricing)
+	if err != nil || formatMoney(totals.Total) != "20.00" {
+		t.Errorf("configured fields: total = %v, %v; want 20.00", totals, err)
+	}
+
+	_, err = computeTotals(items, model.Pricing{Items: "rows"})
+	if !errors.Is(err, ErrInvalidData) || !strings.HasSuffix(err.Error(), "rows[0]: price is not a valid number: abc") {
+		t.Errorf("invalid price: %v", err)
+	}
+}
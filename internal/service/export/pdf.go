@@ -2,244 +2,591 @@ package export
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
 
 	"github.com/jung-kurt/gofpdf"
+	"gopkg.in/yaml.v3"
+)
+
+// PDF版式常量（单位：毫米）
+const (
+	pdfFontFamily   = "AlibabaPuHuiTi"
+	pdfMargin       = 10.0
+	pdfBottomMargin = 15.0
+	pdfLineHeight   = 7.0
+)
+
+// 默认字体（相对模板目录）
+const (
+	defaultPDFRegularFont = "fonts/Alibaba_PuHuiTi_2.0_55_Regular_55_Regular.ttf"
+	defaultPDFBoldFont    = "fonts/Alibaba_PuHuiTi_2.0_75_SemiBold_75_SemiBold.ttf"
 )
 
 // PDFService PDF导出服务
-type PDFService struct{}
+type PDFService struct {
+	templateService template.TemplateService
+}
 
 // NewPDFService 创建PDF导出服务实例
-func NewPDFService() *PDFService {
-	return &PDFService{}
+func NewPDFService(templateService template.TemplateService) *PDFService {
+	return &PDFService{
+		templateService: templateService,
+	}
 }
 
-// ExportPDF 导出PDF文件
+// ExportPDF 导出PDF文件，版式由请求中的 data.layout 或 templates/pdf/<template_id>.yaml 描述
 func (s *PDFService) ExportPDF(req *model.ExportRequest) ([]byte, error) {
-	// 创建新的PDF文档，使用横向布局
-	pdf := gofpdf.New("L", "mm", "A4", "")
-	pdf.AddPage()
+	layout, err := s.loadLayout(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 版式通过 *_style 引用命名样式，与Excel共用 templates/styles.yaml 中的定义
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
+	}
+	styles, err := loadStyleSheet(s.templateService, "pdf", templateID, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLayoutStyles(layout, styles); err != nil {
+		return nil, err
+	}
+
+	orientation := layout.Orientation
+	if orientation == "" {
+		orientation = "P"
+	}
+	pageSize := layout.PageSize
+	if pageSize == "" {
+		pageSize = "A4"
+	}
+
+	pdf := gofpdf.New(orientation, "mm", pageSize, "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfBottomMargin)
+	pdf.AliasNbPages("{nb}")
 
-	// 添加中文字体支持
 	// 注册中文字体（使用阿里巴巴普惠体）
-	// 使用相对于当前工作目录的正确路径
-	pdf.AddUTF8Font("AlibabaPuHuiTi", "", "templates/fonts/Alibaba_PuHuiTi_2.0_55_Regular_55_Regular.ttf")
-	pdf.AddUTF8Font("AlibabaPuHuiTi", "B", "templates/fonts/Alibaba_PuHuiTi_2.0_75_SemiBold_75_SemiBold.ttf")
-	pdf.AddUTF8Font("AlibabaPuHuiTi", "I", "templates/fonts/Alibaba_PuHuiTi_2.0_55_Regular_55_Regular.ttf")
-
-	// 设置默认字体为中文字体
-	pdf.SetFont("AlibabaPuHuiTi", "", 12)
-
-	// 获取项目数据
-	projectData, _ := req.Data["project"].(map[string]interface{})
-
-	// 顶部Logo和标题区域
-	pdf.SetXY(10, 10)
-	pdf.SetFont("AlibabaPuHuiTi", "B", 14)
-	pdf.CellFormat(100, 10, "ORVIBO欧瑞博", "", 0, "L", false, 0, "")
-	pdf.SetXY(257, 10)
-	pdf.SetFont("AlibabaPuHuiTi", "", 10)
-	pdf.CellFormat(30, 10, "5G时代全宅智能 就选欧瑞博", "", 1, "R", false, 0, "")
-
-	// 主视觉图片 - 调整尺寸，避免占用过多页面空间
-	if imagePath, ok := projectData["coverImage"].(string); ok && imagePath != "" {
-		pdf.ImageOptions(imagePath, 10, 20, 277, 70, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
-	} else {
+	if err := s.registerFonts(pdf, layout.Fonts); err != nil {
+		return nil, err
+	}
+	pdf.SetFont(pdfFontFamily, "", 12)
+
+	// 版式包含明细表格时必须传递明细数组，预览请使用模板的示例数据（preview: true）
+	scope := newDataScope(req.Data, nil)
+	if layout.Table != nil {
+		if value, _ := scope.lookup(layout.Table.Items); toItems(value) == nil {
+			return nil, fmt.Errorf("%w: %s is required and must be an array", ErrInvalidData, layout.Table.Items)
+		}
+	}
+
+	// 版式引用了 {{totals.xxx}} 或 {{@amount}} 时才根据明细计算金额汇总，
+	// 没有金额字段的版式不要求明细中的单价和数量为数字
+	defaultItems := "products"
+	if layout.Table != nil && layout.Table.Items != "" {
+		defaultItems = layout.Table.Items
+	}
+	pricing, err := parsePricing(req.Data, defaultItems)
+	if err != nil {
+		return nil, err
+	}
+	totals := &orderTotals{pricing: pricing}
+	if layoutUsesTotals(layout) {
+		items, _ := scope.lookup(pricing.Items)
+		if totals, err = computeTotals(toItems(items), pricing); err != nil {
+			return nil, err
+		}
+		scope.setVar("totals", totals.values())
+	}
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	r := &pdfRenderer{
+		pdf:             pdf,
+		layout:          layout,
+		scope:           scope,
+		totals:          totals,
+		styles:          styles,
+		templateService: s.templateService,
+		width:           pageWidth - 2*pdfMargin,
+		pageHeight:      pageHeight,
+	}
+
+	// 设置页脚回调函数，实现自动页码
+	pdf.SetFooterFunc(r.drawFooter)
+	pdf.AddPage()
+
+	r.drawHeader()
+	r.drawCover()
+	r.drawTitle()
+	r.drawInfo()
+	sums := r.drawTable()
+	r.drawTotals(sums)
+
+	// 保存文档到缓冲区
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to save pdf document: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// layoutUsesTotals 判断版式是否引用了金额汇总：文本中的 {{totals.xxx}}、{{@amount}}，
+// 以及直接写字段名的明细列和合计行的 when
+func layoutUsesTotals(layout *model.PDFLayout) bool {
+	texts := []string{layout.Header.Left, layout.Header.Right, layout.Title,
+		layout.Footer.Left, layout.Footer.Center, layout.Footer.Right}
+	if layout.Cover != nil {
+		texts = append(texts, layout.Cover.Image, layout.Cover.Text)
+	}
+	if layout.Info != nil {
+		for _, field := range layout.Info.Fields {
+			texts = append(texts, field.Label, field.Value)
+		}
+	}
+	if layout.Table != nil {
+		texts = append(texts, layout.Table.Title)
+		for _, column := range layout.Table.Columns {
+			if !strings.Contains(column.Field, "{{") && isTotalsExpr(column.Field) {
+				return true
+			}
+			texts = append(texts, column.Header, column.Field)
+		}
+	}
+	for _, row := range layout.Totals {
+		if isTotalsExpr(row.When) {
+			return true
+		}
+		texts = append(texts, row.Label, row.Value)
+	}
+
+	for _, text := range texts {
+		if referencesTotals(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLayout 读取PDF版式：优先使用请求中的 layout，其次读取模板目录中的版式文件
+func (s *PDFService) loadLayout(req *model.ExportRequest) (*model.PDFLayout, error) {
+	var layout model.PDFLayout
+
+	if raw, ok := req.Data["layout"]; ok {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid pdf layout: %v", ErrInvalidData, err)
+		}
+		if err := json.Unmarshal(data, &layout); err != nil {
+			return nil, fmt.Errorf("%w: invalid pdf layout: %v", ErrInvalidData, err)
+		}
+		return &layout, nil
+	}
+
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
+	}
+
+	data, err := s.templateService.LoadTemplate(templateID, "pdf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pdf layout: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("failed to parse pdf layout %s: %v", templateID, err)
+	}
+	return &layout, nil
+}
+
+// layoutFonts 版式使用的常规和粗体字体，未指定时使用默认字体
+func layoutFonts(fonts model.PDFFonts) (regular, bold string) {
+	regular, bold = fonts.Regular, fonts.Bold
+	if regular == "" {
+		regular = defaultPDFRegularFont
+	}
+	if bold == "" {
+		bold = defaultPDFBoldFont
+	}
+	return regular, bold
+}
+
+// registerFonts 从模板目录加载常规和粗体字体
+func (s *PDFService) registerFonts(pdf *gofpdf.Fpdf, fonts model.PDFFonts) error {
+	regular, bold := layoutFonts(fonts)
+	for style, fontPath := range map[string]string{"": regular, "B": bold} {
+		data, err := s.templateService.LoadAsset(fontPath)
+		if err != nil {
+			return fmt.Errorf("failed to load font %s: %v", fontPath, err)
+		}
+		pdf.AddUTF8FontFromBytes(pdfFontFamily, style, data)
+	}
+	return pdf.Error()
+}
+
+// assets 版式使用的字体和模板目录下的封面图片
+func (s *PDFService) assets(req *model.ExportRequest) ([]string, error) {
+	layout, err := s.loadLayout(req)
+	if err != nil {
+		return nil, err
+	}
+	regular, bold := layoutFonts(layout.Fonts)
+	assets := []string{regular, bold}
+	if layout.Cover != nil {
+		if src := renderText(layout.Cover.Image, newDataScope(req.Data, nil)); isLocalImage(src) {
+			assets = append(assets, src)
+		}
+	}
+	return assets, nil
+}
+
+// pdfRenderer 按版式绘制PDF
+type pdfRenderer struct {
+	pdf             *gofpdf.Fpdf
+	layout          *model.PDFLayout
+	scope           *dataScope
+	totals          *orderTotals
+	styles          styleSheet
+	templateService template.TemplateService
+	width           float64 // 可用宽度
+	pageHeight      float64
+}
+
+// text 渲染版式中的文本模板
+func (r *pdfRenderer) text(tpl string) string {
+	return renderText(tpl, r.scope)
+}
+
+// drawHeader 顶部品牌和标语
+func (r *pdfRenderer) drawHeader() {
+	header := r.layout.Header
+	if header.Left == "" && header.Right == "" {
+		return
+	}
+
+	pdf := r.pdf
+	y := pdf.GetY()
+	pdf.SetFont(pdfFontFamily, "B", 14)
+	pdf.CellFormat(r.width/2, 10, r.text(header.Left), "", 0, "L", false, 0, "")
+	pdf.SetFont(pdfFontFamily, "", 10)
+	pdf.CellFormat(r.width/2, 10, r.text(header.Right), "", 1, "R", false, 0, "")
+	pdf.SetY(y + 10)
+}
+
+// drawCover 主视觉图片，无图片时绘制占位区域
+func (r *pdfRenderer) drawCover() {
+	cover := r.layout.Cover
+	if cover == nil {
+		return
+	}
+
+	pdf := r.pdf
+	height := cover.Height
+	if height <= 0 {
+		height = 70
+	}
+	x, y := pdfMargin, pdf.GetY()
+
+	drawn := false
+	if src := r.text(cover.Image); src != "" {
+		if img, err := loadImage(src, r.templateService); err != nil {
+			log.Printf("加载封面图片失败：%v", err)
+		} else {
+			options := gofpdf.ImageOptions{ImageType: img.ext, ReadDpi: true}
+			pdf.RegisterImageOptionsReader("cover", options, bytes.NewReader(img.data))
+			pdf.ImageOptions("cover", x, y, r.width, height, false, options, 0, "")
+			drawn = pdf.Ok()
+			if !drawn {
+				log.Printf("绘制封面图片失败：%v", pdf.Error())
+				pdf.ClearError()
+			}
+		}
+	}
+
+	if !drawn {
 		// 绘制一个占位矩形
 		pdf.SetFillColor(200, 200, 200)
-		pdf.Rect(10, 20, 277, 70, "F")
-		pdf.SetFillColor(255, 255, 255)
+		pdf.Rect(x, y, r.width, height, "F")
 		pdf.SetTextColor(0, 0, 0)
-		pdf.SetFont("AlibabaPuHuiTi", "B", 18)
-		pdf.CellFormat(277, 70, "全宅智能定制方案", "", 1, "CM", false, 0, "")
-	}
-
-	// 项目标题
-	title, ok := req.Data["title"].(string)
-	if !ok || title == "" {
-		title = "全宅智能定制方案"
-	}
-	pdf.SetXY(10, 95)
-	pdf.SetFont("AlibabaPuHuiTi", "B", 16)
-	pdf.CellFormat(277, 10, title, "", 1, "C", false, 0, "")
-
-	// 项目基本信息表格 - 紧跟在标题下方
-	pdf.SetXY(10, 105)
-	pdf.SetFont("AlibabaPuHuiTi", "B", 10)
-	pdf.SetFillColor(240, 240, 240)
-
-	// 项目信息字段
-	infoFields := []struct {
-		label string
-		value string
-	}{
-		{"项目名称", "全宅智能定制方案20260112"},
-		{"客户名称", ""},
-		{"客户电话", ""},
-		{"户型", "一室一厅"},
-		{"地址", ""},
-		{"服务商", "xxx"},
-		{"联系人", "xxx"},
-		{"联系电话", "15625630782"},
-	}
-
-	// 绘制项目信息表格
-	pdf.SetLineWidth(0.2)
-	for i, field := range infoFields {
-		pdf.CellFormat(40, 7, field.label, "1", 0, "R", true, 0, "")
-		pdf.CellFormat(55, 7, field.value, "1", 0, "L", false, 0, "")
-		if (i+1)%3 == 0 {
+		pdf.SetFont(pdfFontFamily, "B", 18)
+		pdf.SetXY(x, y)
+		pdf.CellFormat(r.width, height, r.text(cover.Text), "", 0, "CM", false, 0, "")
+	}
+	pdf.SetXY(x, y+height+5)
+}
+
+// drawTitle 标题
+func (r *pdfRenderer) drawTitle() {
+	title := r.text(r.layout.Title)
+	if title == "" {
+		return
+	}
+	r.pdf.SetFont(pdfFontFamily, "B", 16)
+	r.pdf.CellFormat(r.width, 10, title, "", 1, "C", false, 0, "")
+}
+
+// drawInfo 键值信息表格
+func (r *pdfRenderer) drawInfo() {
+	info := r.layout.Info
+	if info == nil || len(info.Fields) == 0 {
+		return
+	}
+
+	columns := info.Columns
+	if columns <= 0 {
+		columns = 3
+	}
+	labelWidth := info.LabelWidth
+	if labelWidth <= 0 {
+		labelWidth = 40
+	}
+	valueWidth := r.width/float64(columns) - labelWidth
+
+	labelStyle := r.style(info.LabelStyle, pdfCellStyle{
+		bold: true, size: 10, fillColor: rgb{240, 240, 240}, fill: true, border: "1", lineWidth: 0.2, align: "R",
+	})
+	valueStyle := r.style(info.ValueStyle, pdfCellStyle{size: 10, border: "1", lineWidth: 0.2, align: "L"})
+
+	pdf := r.pdf
+	for i, field := range info.Fields {
+		r.cell(labelStyle, labelWidth, 7, r.text(field.Label), 0)
+		r.cell(valueStyle, valueWidth, 7, r.text(field.Value), 0)
+		if (i+1)%columns == 0 {
 			pdf.Ln(7)
 		}
 	}
 	// 确保表格绘制完成后正确换行
-	if len(infoFields)%3 != 0 {
+	if len(info.Fields)%columns != 0 {
 		pdf.Ln(7)
 	}
+	pdf.Ln(5)
+}
 
-	// 产品清单标题 - 调整位置，紧跟在项目信息表格下方
-	pdf.Ln(5) // 减少换行距离
-	pdf.SetFont("AlibabaPuHuiTi", "B", 14)
-	pdf.CellFormat(277, 10, "产品清单", "", 1, "L", false, 0, "")
+// columnWidths 计算明细表格列宽，未指定宽度的列平分剩余宽度
+func (r *pdfRenderer) columnWidths() []float64 {
+	columns := r.layout.Table.Columns
+	widths := make([]float64, len(columns))
 
-	// 产品表格
-	pdf.SetFont("AlibabaPuHuiTi", "B", 10)
-	pdf.SetFillColor(200, 220, 255)
-	pdf.SetTextColor(0, 0, 0)
-	pdf.SetLineWidth(0.3)
-
-	// 产品表头
-	headers := []string{"产品", "单价", "数量", "金额", "产品说明"}
-	// 调整列宽，充分利用横向页面宽度（A4横向宽度为297mm，左右边距各10mm，可用宽度277mm）
-	colWidths := []float64{70, 35, 25, 35, 112}
-
-	// 绘制表头
-	for i, header := range headers {
-		pdf.CellFormat(colWidths[i], 10, header, "1", 0, "C", true, 0, "")
-	}
-	pdf.Ln(10)
-
-	// 产品数据
-	products, ok := req.Data["products"].([]interface{})
-	if !ok {
-		// 使用示例数据
-		products = []interface{}{
-			map[string]interface{}{
-				"name":        "MixSwitch智能开关(三开 雅灰)",
-				"price":       "369.00",
-				"quantity":    "1.00",
-				"amount":      "¥369.00",
-				"description": "MixSwitch 超级智能开关 采用MixPad家族式大按键设计，支持独创MixCtrl技术，按键功能自由定义；V0级防火PC材质，安全耐用。",
-			},
-			map[string]interface{}{
-				"name":        "智能摄像机",
-				"price":       "249.00",
-				"quantity":    "1.00",
-				"amount":      "¥249.00",
-				"description": "智能摄像机 拥有355°高清广角、超强夜视及声音侦测告警功能，清晰的双向语音通话，让你不错过家人的任何重要时刻。",
-			},
-		}
-	}
-
-	// 绘制产品行
-	pdf.SetFont("AlibabaPuHuiTi", "", 9)
-
-	for i, productItem := range products {
-		product, ok := productItem.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		name, _ := product["name"].(string)
-		price, _ := product["price"].(string)
-		quantity, _ := product["quantity"].(string)
-		amountStr, _ := product["amount"].(string)
-		description, _ := product["description"].(string)
+	fixed, flexible := 0.0, 0
+	for i, column := range columns {
+		widths[i] = column.Width
+		if column.Width > 0 {
+			fixed += column.Width
+		} else {
+			flexible++
+		}
+	}
+	if flexible > 0 {
+		share := math.Max((r.width-fixed)/float64(flexible), 10)
+		for i := range widths {
+			if widths[i] <= 0 {
+				widths[i] = share
+			}
+		}
+	}
+	return widths
+}
+
+// drawTableHeader 明细表头
+func (r *pdfRenderer) drawTableHeader(widths []float64) {
+	style := r.style(r.layout.Table.HeaderStyle, pdfCellStyle{
+		bold: true, size: 10, fillColor: rgb{200, 220, 255}, fill: true, border: "1", lineWidth: 0.3, align: "C",
+	})
+	for i, column := range r.layout.Table.Columns {
+		r.cell(style, widths[i], 10, r.text(column.Header), 0)
+	}
+	r.pdf.Ln(10)
+}
+
+// drawTable 明细表格，返回需要汇总的列合计
+func (r *pdfRenderer) drawTable() map[string]interface{} {
+	table := r.layout.Table
+	sums := make(map[string]interface{})
+	if table == nil || len(table.Columns) == 0 {
+		return sums
+	}
+
+	pdf := r.pdf
+	if title := r.text(table.Title); title != "" {
+		pdf.SetFont(pdfFontFamily, "B", 14)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.CellFormat(r.width, 10, title, "", 1, "L", false, 0, "")
+	}
+
+	widths := r.columnWidths()
+	r.drawTableHeader(widths)
+
+	// 列样式未设置背景色时使用交替行背景，列的 align 优先于样式中的对齐方式
+	styles := make([]pdfCellStyle, len(table.Columns))
+	for c, column := range table.Columns {
+		styles[c] = r.style(column.Style, pdfCellStyle{size: 9, border: "1", lineWidth: 0.3})
+		if column.Align != "" || styles[c].align == "" {
+			styles[c].align = alignOrDefault(column.Align)
+		}
+	}
+
+	value, _ := r.scope.lookup(table.Items)
+	totals := make([]*big.Rat, len(table.Columns))
+	for c := range totals {
+		totals[c] = new(big.Rat)
+	}
+	for i, item := range toItems(value) {
+		itemScope := r.scope.child(item, i)
+		if table.Items == r.totals.pricing.Items {
+			itemScope.setVar("@amount", r.totals.lineValue(i))
+		}
+
+		texts := make([]string, len(table.Columns))
+		lines := 1
+		for c, column := range table.Columns {
+			texts[c] = columnText(column.Field, itemScope)
+			r.useStyle(styles[c])
+			if n := len(pdf.SplitText(texts[c], widths[c]-2)); n > lines {
+				lines = n
+			}
+			if column.Sum {
+				if amount, ok := parseDecimal(texts[c]); ok {
+					totals[c].Add(totals[c], amount)
+				}
+			}
+		}
+		rowHeight := float64(lines) * pdfLineHeight
+
+		// 当前页放不下时换页并重绘表头
+		if pdf.GetY()+rowHeight > r.pageHeight-pdfBottomMargin {
+			pdf.AddPage()
+			r.drawTableHeader(widths)
+		}
 
 		// 交替行背景色
+		stripe := rgb{255, 255, 255}
 		if i%2 == 0 {
-			pdf.SetFillColor(245, 250, 255)
-		} else {
-			pdf.SetFillColor(255, 255, 255)
+			stripe = rgb{245, 250, 255}
 		}
 
-		// 计算行高
-		lineHeight := 7.0
-		descLines := 1
-		if len(description) > 0 {
-			descWidth := pdf.GetStringWidth(description)
-			if descWidth > colWidths[4] {
-				descLines = int(descWidth/colWidths[4]) + 1
+		x, y := pdfMargin, pdf.GetY()
+		for c := range table.Columns {
+			style := styles[c]
+			if !style.fill {
+				style.fill, style.fillColor = true, stripe
 			}
+			r.drawBox(style, x, y, widths[c], rowHeight)
+			pdf.SetXY(x, y)
+			pdf.MultiCell(widths[c], pdfLineHeight, texts[c], "", style.align, false)
+			x += widths[c]
 		}
-		totalHeight := float64(descLines) * lineHeight
-
-		// 简化的表格行绘制
-		// 产品名称
-		pdf.MultiCell(colWidths[0], lineHeight, name, "1", "L", true)
-		// 单价
-		pdf.SetXY(pdf.GetX()-colWidths[0]+colWidths[0], pdf.GetY()-lineHeight)
-		pdf.MultiCell(colWidths[1], lineHeight, price, "1", "R", true)
-		// 数量
-		pdf.SetXY(pdf.GetX()-colWidths[1]+colWidths[0]+colWidths[1], pdf.GetY()-lineHeight)
-		pdf.MultiCell(colWidths[2], lineHeight, quantity, "1", "C", true)
-		// 金额
-		pdf.SetXY(pdf.GetX()-colWidths[2]+colWidths[0]+colWidths[1]+colWidths[2], pdf.GetY()-lineHeight)
-		pdf.MultiCell(colWidths[3], lineHeight, amountStr, "1", "R", true)
-		// 产品说明（支持多行）
-		pdf.SetXY(pdf.GetX()-colWidths[3]+colWidths[0]+colWidths[1]+colWidths[2]+colWidths[3], pdf.GetY()-lineHeight)
-		pdf.MultiCell(colWidths[4], lineHeight, description, "1", "L", true)
-
-		// 确保行高一致
-		currentY := pdf.GetY()
-		expectedY := pdf.GetY() - lineHeight + totalHeight
-		if currentY < expectedY {
-			pdf.SetXY(10, expectedY)
-		} else {
-			pdf.Ln(0)
+		pdf.SetXY(pdfMargin, y+rowHeight)
+	}
+
+	for c, column := range table.Columns {
+		if column.Sum {
+			sums[sumKey(column.Field)] = formatMoney(totals[c])
 		}
 	}
+	return sums
+}
 
-	// 绘制总计行
-	pdf.SetFont("AlibabaPuHuiTi", "B", 10)
-	pdf.SetFillColor(200, 220, 255)
+// drawTotals 合计行，可引用 {{totals.xxx}} 金额汇总和 {{sum.<field>}} 明细列合计
+func (r *pdfRenderer) drawTotals(sums map[string]interface{}) {
+	if len(r.layout.Totals) == 0 {
+		return
+	}
 
-	// 总计行
-	pdf.CellFormat(colWidths[0]+colWidths[1]+colWidths[2], 10, "总计", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[3], 10, "¥6192.00", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[4], 10, "", "1", 1, "L", true, 0, "")
+	// 合计金额显示在第一个汇总列或金额列（{{@amount}}）下方，标签占据其左侧的列
+	labelWidth, valueWidth := r.width/2, r.width/2
+	if table := r.layout.Table; table != nil && len(table.Columns) > 1 {
+		widths := r.columnWidths()
+		valueIdx := len(widths) - 1
+		for i, column := range table.Columns {
+			if column.Sum || strings.Contains(column.Field, "@amount") {
+				valueIdx = i
+				break
+			}
+		}
+		if valueIdx == 0 {
+			valueIdx = 1
+		}
+		labelWidth, valueWidth = 0, widths[valueIdx]
+		for _, w := range widths[:valueIdx] {
+			labelWidth += w
+		}
+	}
+	restWidth := r.width - labelWidth - valueWidth
+
+	scope := r.scope.child(map[string]interface{}{"sum": sums}, 0)
+	pdf := r.pdf
+	for _, row := range r.layout.Totals {
+		if row.When != "" {
+			if value, _ := scope.lookup(row.When); !isTruthy(value) {
+				continue
+			}
+		}
+		size := 10.0
+		if row.Emphasis {
+			size = 12
+		}
+		style := r.style(row.Style, pdfCellStyle{
+			bold: true, size: size, fillColor: rgb{200, 220, 255}, fill: true, border: "1", lineWidth: 0.3, align: "R",
+		})
+		r.cell(style, labelWidth, 10, renderText(row.Label, scope), 0)
+		r.cell(style, valueWidth, 10, renderText(row.Value, scope), 0)
+		if restWidth > 0 {
+			r.cell(style, restWidth, 10, "", 0)
+		}
+		pdf.Ln(10)
+	}
+}
 
-	// 服务费行
-	pdf.CellFormat(colWidths[0]+colWidths[1]+colWidths[2], 10, "服务费", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[3], 10, "¥1238.40", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[4], 10, "", "1", 1, "L", true, 0, "")
+// drawFooter 页脚
+func (r *pdfRenderer) drawFooter() {
+	footer := r.layout.Footer
+	if footer.Left == "" && footer.Center == "" && footer.Right == "" {
+		return
+	}
 
-	// 最终总计行
-	pdf.SetFont("AlibabaPuHuiTi", "B", 12)
-	pdf.CellFormat(colWidths[0]+colWidths[1]+colWidths[2], 10, "总计", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[3], 10, "¥7430.40", "1", 0, "R", true, 0, "")
-	pdf.CellFormat(colWidths[4], 10, "", "1", 1, "L", true, 0, "")
+	pdf := r.pdf
+	pageReplacer := strings.NewReplacer("{{@page}}", strconv.Itoa(pdf.PageNo()), "{{@pages}}", "{nb}")
+	cellWidth := r.width / 3
 
-	// 设置页脚回调函数，实现自动页码
-	pdf.SetFooterFunc(func() {
-		pdf.SetFont("AlibabaPuHuiTi", "", 8)
-		pdf.SetXY(10, -10)
-		pdf.CellFormat(200, 5, "全宅智能定制方案20260112", "", 0, "L", false, 0, "")
-		pdf.SetXY(240, -10)
-		pdf.CellFormat(20, 5, fmt.Sprintf("%d / %d", pdf.PageNo(), pdf.PageCount()), "", 0, "C", false, 0, "")
-		pdf.SetXY(270, -10)
-		pdf.CellFormat(20, 5, "xxx 15625630782", "", 1, "R", false, 0, "")
-	})
+	pdf.SetFont(pdfFontFamily, "", 8)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(pdfMargin, -10)
+	pdf.CellFormat(cellWidth, 5, r.text(pageReplacer.Replace(footer.Left)), "", 0, "L", false, 0, "")
+	pdf.CellFormat(cellWidth, 5, r.text(pageReplacer.Replace(footer.Center)), "", 0, "C", false, 0, "")
+	pdf.CellFormat(cellWidth, 5, r.text(pageReplacer.Replace(footer.Right)), "", 0, "R", false, 0, "")
+}
 
-	// 保存文档到缓冲区
-	var buf bytes.Buffer
-	err := pdf.Output(&buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save pdf document: %v", err)
+// columnText 计算明细单元格文本：field 为字段名，或包含 {{field}} 占位符的文本
+func columnText(field string, scope *dataScope) string {
+	if strings.Contains(field, "{{") {
+		return renderText(field, scope)
+	}
+	value, _ := scope.evaluate(field)
+	return formatValue(value)
+}
+
+// sumKey 汇总列的引用名：字段名或字段模板中的第一个占位符
+func sumKey(field string) string {
+	if m := placeholderRe.FindString(field); m != "" {
+		return placeholderName(m)
 	}
+	return field
+}
 
-	return buf.Bytes(), nil
+// alignOrDefault 对齐方式，默认左对齐
+func alignOrDefault(align string) string {
+	if align == "" {
+		return "L"
+	}
+	return align
 }
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"office-export-server/internal/model"
+)
+
+// rgb PDF颜色
+type rgb struct {
+	r, g, b int
+}
+
+// parseRGB 解析 #RRGGBB 形式的颜色，颜色已在加载样式时校验
+func parseRGB(color string) rgb {
+	value, _ := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	return rgb{int(value >> 16 & 0xFF), int(value >> 8 & 0xFF), int(value & 0xFF)}
+}
+
+// pdfCellStyle PDF单元格的字体、颜色、边框和对齐方式
+type pdfCellStyle struct {
+	bold      bool
+	size      float64
+	textColor rgb
+	fillColor rgb
+	fill      bool
+	border    string // CellFormat 的边框参数：""、"1" 或 "LTRB" 的组合
+	drawColor rgb
+	lineWidth float64
+	align     string // L、C、R
+}
+
+// pdfBorderWidths 边框样式对应的线宽（毫米）
+var pdfBorderWidths = map[string]float64{
+	"thin":   0.2,
+	"medium": 0.4,
+	"thick":  0.6,
+	"dashed": 0.2,
+	"dotted": 0.2,
+	"double": 0.4,
+}
+
+// pdfBorderSides 边框的边对应 CellFormat 的边框参数
+var pdfBorderSides = map[string]string{
+	"left":   "L",
+	"top":    "T",
+	"right":  "R",
+	"bottom": "B",
+}
+
+// with 以命名样式覆盖默认样式，样式中未设置的属性保持不变；字体族固定使用版式注册的字体
+func (st pdfCellStyle) with(def model.StyleDef) pdfCellStyle {
+	if font := def.Font; font != nil {
+		st.bold = font.Bold
+		if font.Size > 0 {
+			st.size = font.Size
+		}
+		if font.Color != "" {
+			st.textColor = parseRGB(font.Color)
+		}
+	}
+	if def.Fill != "" {
+		st.fill = true
+		st.fillColor = parseRGB(def.Fill)
+	}
+	if border := def.Border; border != nil {
+		st.border = "1"
+		if len(border.Sides) > 0 {
+			st.border = ""
+			for _, side := range border.Sides {
+				st.border += pdfBorderSides[side]
+			}
+		}
+		st.drawColor = rgb{}
+		if border.Color != "" {
+			st.drawColor = parseRGB(border.Color)
+		}
+		st.lineWidth = pdfBorderWidths["thin"]
+		if width, ok := pdfBorderWidths[border.Style]; ok {
+			st.lineWidth = width
+		}
+	}
+	if def.Align != nil {
+		switch def.Align.Horizontal {
+		case "left":
+			st.align = "L"
+		case "center":
+			st.align = "C"
+		case "right":
+			st.align = "R"
+		}
+	}
+	return st
+}
+
+// style 以版式引用的命名样式覆盖默认样式，name 为空时返回默认样式
+func (r *pdfRenderer) style(name string, base pdfCellStyle) pdfCellStyle {
+	if name == "" {
+		return base
+	}
+	return base.with(r.styles[name])
+}
+
+// useStyle 设置后续绘制使用的字体、颜色和线宽
+func (r *pdfRenderer) useStyle(st pdfCellStyle) {
+	pdf := r.pdf
+	fontStyle := ""
+	if st.bold {
+		fontStyle = "B"
+	}
+	pdf.SetFont(pdfFontFamily, fontStyle, st.size)
+	pdf.SetTextColor(st.textColor.r, st.textColor.g, st.textColor.b)
+	pdf.SetFillColor(st.fillColor.r, st.fillColor.g, st.fillColor.b)
+	pdf.SetDrawColor(st.drawColor.r, st.drawColor.g, st.drawColor.b)
+	if st.lineWidth > 0 {
+		pdf.SetLineWidth(st.lineWidth)
+	}
+}
+
+// cell 按样式绘制单行单元格
+func (r *pdfRenderer) cell(st pdfCellStyle, width, height float64, text string, ln int) {
+	r.useStyle(st)
+	r.pdf.CellFormat(width, height, text, st.border, ln, st.align, st.fill, 0, "")
+}
+
+// drawBox 按样式绘制多行单元格的背景和边框
+func (r *pdfRenderer) drawBox(st pdfCellStyle, x, y, width, height float64) {
+	pdf := r.pdf
+	r.useStyle(st)
+	if st.fill {
+		pdf.Rect(x, y, width, height, "F")
+	}
+	border := st.border
+	if border == "1" {
+		border = "LTRB"
+	}
+	if strings.Contains(border, "L") {
+		pdf.Line(x, y, x, y+height)
+	}
+	if strings.Contains(border, "T") {
+		pdf.Line(x, y, x+width, y)
+	}
+	if strings.Contains(border, "R") {
+		pdf.Line(x+width, y, x+width, y+height)
+	}
+	if strings.Contains(border, "B") {
+		pdf.Line(x, y+height, x+width, y+height)
+	}
+}
+
+// layoutStyleNames 版式中引用的全部命名样式
+func layoutStyleNames(layout *model.PDFLayout) []string {
+	var names []string
+	if info := layout.Info; info != nil {
+		names = append(names, info.LabelStyle, info.ValueStyle)
+	}
+	if table := layout.Table; table != nil {
+		names = append(names, table.HeaderStyle)
+		for _, column := range table.Columns {
+			names = append(names, column.Style)
+		}
+	}
+	for _, row := range layout.Totals {
+		names = append(names, row.Style)
+	}
+	return names
+}
+
+// checkLayoutStyles 检查版式引用的命名样式是否均已定义
+func checkLayoutStyles(layout *model.PDFLayout, styles styleSheet) error {
+	for _, name := range layoutStyleNames(layout) {
+		if name == "" {
+			continue
+		}
+		if _, err := styles.lookup(name); err != nil {
+			return fmt.Errorf("invalid pdf layout: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+)
+
+// testFont 仓库中的字体，测试中同时用作常规和粗体字体
+const testFont = "fonts/Alibaba_PuHuiTi_2.0_105_Heavy_105_Heavy.ttf"
+
+// usePDFFonts 使用只包含测试字体的临时模板目录
+func usePDFFonts(t *testing.T) {
+	t.Helper()
+	font, err := os.ReadFile("../../../templates/" + testFont)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTemplateDir(t, map[string][]byte{testFont: font})
+}
+
+// pdfLayout 只有明细表格的版式，totals 为合计行
+func pdfLayout(columns []interface{}, totals []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"fonts":  map[string]interface{}{"regular": testFont, "bold": testFont},
+		"title":  "{{title}}",
+		"table":  map[string]interface{}{"items": "products", "columns": columns},
+		"totals": totals,
+	}
+}
+
+func TestLayoutUsesTotals(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout model.PDFLayout
+		want   bool
+	}{
+		{"none", model.PDFLayout{Title: "{{title}}", Table: &model.PDFTable{Columns: []model.PDFColumn{{Field: "数量"}}}}, false},
+		{"amount placeholder", model.PDFLayout{Table: &model.PDFTable{Columns: []model.PDFColumn{{Field: "¥{{@amount}}"}}}}, true},
+		{"amount field", model.PDFLayout{Table: &model.PDFTable{Columns: []model.PDFColumn{{Field: "@amount"}}}}, true},
+		{"totals value", model.PDFLayout{Totals: []model.PDFTotalRow{{Label: "总计", Value: "{{totals.total|rmb_upper}}"}}}, true},
+		{"totals when", model.PDFLayout{Totals: []model.PDFTotalRow{{Label: "含税", When: "totals.tax_rate"}}}, true},
+		{"footer", model.PDFLayout{Footer: model.PDFFooter{Right: "{{#if totals.tax_rate}}含税{{/if}}"}}, true},
+		{"sum only", model.PDFLayout{Totals: []model.PDFTotalRow{{Label: "合计", Value: "{{sum.price}}"}}}, false},
+	}
+	for _, tt := range tests {
+		if got := layoutUsesTotals(&tt.layout); got != tt.want {
+			t.Errorf("%s: layoutUsesTotals = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportPDFWithoutTotals(t *testing.T) {
+	usePDFFonts(t)
+	ps := NewPDFService(template.NewTemplateService())
+	products := []interface{}{
+		map[string]interface{}{"name": "灯具", "数量": "1套", "price": 100.0},
+		map[string]interface{}{"name": "拆除", "数量": "按实结算"},
+	}
+	columns := []interface{}{
+		map[string]interface{}{"header": "产品", "field": "name"},
+		map[string]interface{}{"header": "数量", "field": "数量"},
+	}
+
+	req := &model.ExportRequest{Data: map[string]interface{}{
+		"title":    "施工清单",
+		"products": products,
+		"layout":   pdfLayout(columns, nil),
+	}}
+	data, err := ps.ExportPDF(req)
+	if err != nil {
+		t.Fatalf("ExportPDF: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Errorf("output is not a PDF: %q", data[:min(len(data), 8)])
+	}
+
+	req.Data["layout"] = pdfLayout(columns, []interface{}{map[string]interface{}{"label": "总计", "value": "{{totals.total}}"}})
+	if _, err := ps.ExportPDF(req); err == nil || !strings.Contains(err.Error(), "数量 is not a valid number") {
+		t.Errorf("ExportPDF with totals: %v, want 数量 is not a valid number", err)
+	}
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholderRe 匹配 {{field}} 形式的占位符
+var placeholderRe = regexp.MustCompile(`\{\{[^{}]+\}\}`)
+
+// dataScope 占位符取值作用域，循环时子作用域可回退到父作用域查找
+type dataScope struct {
+	data   interface{}
+	vars   map[string]interface{} // 服务端计算的变量（如 totals、@amount），优先于请求数据
+	index  int
+	parent *dataScope
+}
+
+// newDataScope 创建取值作用域
+func newDataScope(data interface{}, parent *dataScope) *dataScope {
+	return &dataScope{data: data, parent: parent}
+}
+
+// child 创建循环元素对应的子作用域
+func (s *dataScope) child(data interface{}, index int) *dataScope {
+	return &dataScope{data: data, index: index, parent: s}
+}
+
+// setVar 设置作用域变量
+func (s *dataScope) setVar(name string, value interface{}) *dataScope {
+	if s.vars == nil {
+		s.vars = make(map[string]interface{})
+	}
+	s.vars[name] = value
+	return s
+}
+
+// lookup 按路径查找值，支持 a.b.c 形式的嵌套路径，以及 this 和 @index
+func (s *dataScope) lookup(path string) (interface{}, bool) {
+	path = strings.TrimSpace(path)
+	switch path {
+	case "this", ".":
+		return s.data, true
+	case "@index":
+		return s.index + 1, true
+	}
+
+	for scope := s; scope != nil; scope = scope.parent {
+		if value, ok := lookupPath(scope.vars, path); ok {
+			return value, true
+		}
+		if value, ok := lookupPath(scope.data, path); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// placeholderFormatters 占位符格式化器，用法为 {{field|formatter}}
+var placeholderFormatters = map[string]func(value interface{}) interface{}{
+	"rmb_upper": formatRMBUpper,
+}
+
+// evaluate 计算占位符表达式的值，支持 {{field|formatter}} 形式的格式化，多个格式化器依次执行
+func (s *dataScope) evaluate(expr string) (interface{}, bool) {
+	parts := strings.Split(expr, "|")
+	value, ok := s.lookup(parts[0])
+	for _, name := range parts[1:] {
+		name = strings.TrimSpace(name)
+		formatter, exists := placeholderFormatters[name]
+		if !exists {
+			log.Printf("未知的占位符格式化器：%s", name)
+			continue
+		}
+		value = formatter(value)
+	}
+	return value, ok
+}
+
+// lookupPath 在嵌套map中按点号路径取值
+func lookupPath(data interface{}, path string) (interface{}, bool) {
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// placeholderName 去掉占位符两侧的花括号
+func placeholderName(placeholder string) string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(placeholder, "{{"), "}}"))
+}
+
+// referencesTotals 判断文本中是否有引用金额汇总的占位符：{{totals.xxx}}、{{@amount}}，以及以它们为条件的区块
+func referencesTotals(text string) bool {
+	for _, placeholder := range placeholderRe.FindAllString(text, -1) {
+		if isTotalsExpr(placeholderName(placeholder)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTotalsExpr 判断表达式是否引用金额汇总，表达式可带 #if 等区块前缀和 | 格式化函数
+func isTotalsExpr(expr string) bool {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return false
+	}
+	name := strings.TrimSpace(strings.Split(fields[len(fields)-1], "|")[0])
+	return name == "@amount" || name == "totals" || strings.HasPrefix(name, "totals.")
+}
+
+// formatValue 将数据值格式化为文本
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toItems 将数据值转换为循环用的元素列表
+func toItems(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+// isTruthy 判断条件区块的取值是否成立：空值、false、空字符串、0、空数组和空对象均视为不成立
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case int:
+		return v != 0
+	case json.Number:
+		f, err := v.Float64()
+		return err != nil || f != 0
+	case []interface{}:
+		return len(v) > 0
+	case []map[string]interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// renderText 替换文本中的全部占位符
+func renderText(text string, scope *dataScope) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, _ := scope.evaluate(placeholderName(placeholder))
+		return formatValue(value)
+	})
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// 大写金额使用的数字和单位
+var (
+	rmbDigits     = []string{"零", "壹", "贰", "叁", "肆", "伍", "陆", "柒", "捌", "玖"}
+	rmbUnits      = []string{"", "拾", "佰", "仟"}
+	rmbGroupUnits = []string{"", "万", "亿"}
+)
+
+// rmbUpperLimit 支持转换的金额上限（不含），即一万亿元
+var rmbUpperLimit = new(big.Rat).SetInt64(1000000000000)
+
+// rmbUpper 将金额转换为中文大写人民币，如 22459.2 -> 贰万贰仟肆佰伍拾玖元贰角整
+// 金额按分四舍五入；到元或角为止时以"整"结尾，到分时不加"整"
+func rmbUpper(amount *big.Rat) (string, error) {
+	cents := roundCents(amount)
+	negative := cents.Sign() < 0
+	if negative {
+		cents.Neg(cents)
+	}
+	if cents.Cmp(rmbUpperLimit) >= 0 {
+		return "", fmt.Errorf("amount too large for uppercase conversion: %s", amount.FloatString(2))
+	}
+
+	// 拆分为整数部分和角、分
+	total := new(big.Int).Quo(new(big.Int).Mul(cents.Num(), big.NewInt(100)), cents.Denom())
+	yuan, rem := new(big.Int).QuoRem(total, big.NewInt(100), new(big.Int))
+	jiao, fen := rem.Int64()/10, rem.Int64()%10
+
+	var buf strings.Builder
+	if negative {
+		buf.WriteString("负")
+	}
+
+	if yuan.Sign() > 0 {
+		buf.WriteString(rmbInteger(yuan.String()))
+		buf.WriteString("元")
+	}
+
+	switch {
+	case jiao == 0 && fen == 0:
+		if yuan.Sign() == 0 {
+			buf.WriteString("零元")
+		}
+		buf.WriteString("整")
+	case fen == 0:
+		buf.WriteString(rmbDigits[jiao] + "角整")
+	case jiao == 0:
+		if yuan.Sign() > 0 {
+			buf.WriteString("零")
+		}
+		buf.WriteString(rmbDigits[fen] + "分")
+	default:
+		buf.WriteString(rmbDigits[jiao] + "角" + rmbDigits[fen] + "分")
+	}
+	return buf.String(), nil
+}
+
+// rmbInteger 转换整数部分，连续的零只读一个"零"，整组为零时省略该组单位
+func rmbInteger(digits string) string {
+	var buf strings.Builder
+	zero := false
+	groupHasDigit := false
+	for i, c := range digits {
+		pos := len(digits) - 1 - i
+		d := int(c - '0')
+
+		if d == 0 {
+			zero = true
+		} else {
+			if zero && buf.Len() > 0 {
+				buf.WriteString("零")
+			}
+			zero = false
+			groupHasDigit = true
+			buf.WriteString(rmbDigits[d] + rmbUnits[pos%4])
+		}
+
+		if pos%4 == 0 {
+			if groupHasDigit {
+				buf.WriteString(rmbGroupUnits[pos/4])
+			}
+			groupHasDigit = false
+		}
+	}
+	return buf.String()
+}
+
+// formatRMBUpper 占位符格式化器 rmb_upper，数据值无法解析为金额时原样输出
+func formatRMBUpper(value interface{}) interface{} {
+	amount, ok := parseDecimal(value)
+	if !ok {
+		return value
+	}
+	text, err := rmbUpper(amount)
+	if err != nil {
+		return value
+	}
+	return text
+}
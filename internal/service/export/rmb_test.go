@@ -0,0 +1,66 @@
+package export
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestRMBUpper(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{"0", "零元整"},
+		{"0.004", "零元整"},
+		{"0.005", "壹分"},
+		{"0.05", "伍分"},
+		{"0.5", "伍角整"},
+		{"1.005", "壹元零壹分"},
+		{"1.2", "壹元贰角整"},
+		{"10.00", "壹拾元整"},
+		{"1010", "壹仟零壹拾元整"},
+		{"1000100", "壹佰万零壹佰元整"},
+		{"100001", "壹拾万零壹元整"},
+		{"22459.2", "贰万贰仟肆佰伍拾玖元贰角整"},
+		{"100000000", "壹亿元整"},
+		{"100000001", "壹亿零壹元整"},
+		{"100010000", "壹亿零壹万元整"},
+		{"10000000000", "壹佰亿元整"},
+		{"999999999999.99", "玖仟玖佰玖拾玖亿玖仟玖佰玖拾玖万玖仟玖佰玖拾玖元玖角玖分"},
+		{"-1.5", "负壹元伍角整"},
+		{"-0.005", "负壹分"},
+	}
+	for _, tt := range tests {
+		amount, _ := new(big.Rat).SetString(tt.amount)
+		got, err := rmbUpper(amount)
+		if err != nil || got != tt.want {
+			t.Errorf("rmbUpper(%s) = %q, %v; want %q", tt.amount, got, err, tt.want)
+		}
+	}
+
+	for _, amount := range []string{"1000000000000", "999999999999.995", "-1000000000000"} {
+		r, _ := new(big.Rat).SetString(amount)
+		if got, err := rmbUpper(r); err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Errorf("rmbUpper(%s) = %q, %v; want too large error", amount, got, err)
+		}
+	}
+}
+
+func TestFormatRMBUpper(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{22459.2, "贰万贰仟肆佰伍拾玖元贰角整"},
+		{"¥1,234.50", "壹仟贰佰叁拾肆元伍角整"},
+		{"按实结算", "按实结算"},
+		{nil, nil},
+		{1e12, 1e12},
+	}
+	for _, tt := range tests {
+		if got := formatRMBUpper(tt.value); got != tt.want {
+			t.Errorf("formatRMBUpper(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaValidator 按模板声明的JSON Schema校验请求数据
+type schemaValidator struct {
+	templateService template.TemplateService
+	schemas         *schemaCache
+}
+
+// newSchemaValidator 创建请求数据校验器
+func newSchemaValidator(templateService template.TemplateService) *schemaValidator {
+	return &schemaValidator{
+		templateService: templateService,
+		schemas:         &schemaCache{entries: make(map[string]cachedSchema)},
+	}
+}
+
+// schemaCache 编译后的模板Schema，按模板缓存，模板文件变化后重新编译
+type schemaCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSchema // 键为 类型/模板ID
+}
+
+// cachedSchema 缓存的Schema及编译时模板的状态，模板未声明Schema时 schema 为 nil
+type cachedSchema struct {
+	stamp  string
+	schema *jsonschema.Schema
+}
+
+// get 获取模板状态为 stamp 时缓存的Schema
+func (c *schemaCache) get(key, stamp string) (*jsonschema.Schema, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.entries[key]
+	if !ok || cached.stamp != stamp {
+		return nil, false
+	}
+	return cached.schema, true
+}
+
+// put 缓存模板状态为 stamp 时的Schema，替换该模板此前缓存的Schema
+func (c *schemaCache) put(key, stamp string, schema *jsonschema.Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cachedSchema{stamp: stamp, schema: schema}
+}
+
+// Validate 校验导出请求，返回全部字段错误：模板描述文件中的 required_fields 须有值，并按模板声明的Schema校验
+// Excel按sheet校验，每个sheet使用其模板（sheet级 template_id 优先）的Schema，表格模式的sheet不校验
+func (v *schemaValidator) Validate(fileType string, req *model.ExportRequest) ([]model.FieldError, error) {
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
+	}
+
+	if fileType != "excel" {
+		return v.validate(templateID, fileType, req.Data, "data")
+	}
+
+	sheets, ok := req.Data["sheets"].([]interface{})
+	if !ok || len(sheets) == 0 {
+		return []model.FieldError{{Field: "data.sheets", Message: "is required and must be a non-empty array"}}, nil
+	}
+
+	var fieldErrors []model.FieldError
+	for i, sheetData := range sheets {
+		sheetMap, ok := sheetData.(map[string]interface{})
+		if !ok || isTableSheet(sheetMap) {
+			continue
+		}
+		sheetTemplateID := templateID
+		if id, ok := sheetMap["template_id"].(string); ok && id != "" {
+			sheetTemplateID = id
+		}
+		errs, err := v.validate(sheetTemplateID, fileType, sheetMap, fmt.Sprintf("data.sheets[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		fieldErrors = append(fieldErrors, errs...)
+	}
+	return fieldErrors, nil
+}
+
+// validate 检查模板的必填字段并使用模板的Schema校验数据，prefix 为数据在请求中的路径
+func (v *schemaValidator) validate(templateID, fileType string, data map[string]interface{}, prefix string) ([]model.FieldError, error) {
+	meta, err := v.templateService.LoadMeta(templateID, fileType)
+	if err != nil {
+		return nil, err
+	}
+	var fieldErrors []model.FieldError
+	if meta != nil {
+		fieldErrors = requiredFieldErrors(meta.RequiredFields, data, prefix)
+	}
+
+	schemaErrors, err := v.validateSchema(templateID, fileType, data, prefix)
+	if err != nil {
+		return nil, err
+	}
+	// Schema同样声明为 required 的字段只列出一次
+	reported := make(map[model.FieldError]bool, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		reported[fieldError] = true
+	}
+	for _, fieldError := range schemaErrors {
+		if !reported[fieldError] {
+			fieldErrors = append(fieldErrors, fieldError)
+		}
+	}
+	return fieldErrors, nil
+}
+
+// requiredFieldErrors 检查必填字段，字段可用 a.b 引用嵌套对象的属性，缺少、为 null 或空字符串时视为未填写
+func requiredFieldErrors(fields []string, data map[string]interface{}, prefix string) []model.FieldError {
+	var fieldErrors []model.FieldError
+	for _, field := range fields {
+		var value interface{} = data
+		for _, key := range strings.Split(field, ".") {
+			object, _ := value.(map[string]interface{})
+			value = object[key]
+		}
+		if value == nil || value == "" {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: prefix + "." + field, Message: "is required"})
+		}
+	}
+	return fieldErrors
+}
+
+// validateSchema 使用模板的Schema校验数据，模板未声明Schema时不校验
+func (v *schemaValidator) validateSchema(templateID, fileType string, data interface{}, prefix string) ([]model.FieldError, error) {
+	schema, err := v.loadSchema(templateID, fileType)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+
+	err = schema.Validate(data)
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, fmt.Errorf("failed to validate data: %v", err)
+	}
+
+	var fieldErrors []model.FieldError
+	collectFieldErrors(validationErr, prefix, &fieldErrors)
+	return fieldErrors, nil
+}
+
+// loadSchema 获取模板编译后的Schema，模板未变化时使用缓存；模板不在索引中时每次重新编译
+func (v *schemaValidator) loadSchema(templateID, fileType string) (*jsonschema.Schema, error) {
+	key := fileType + "/" + templateID
+	stamp := v.templateService.TemplateStamp(templateID, fileType)
+	if stamp != "" {
+		if schema, ok := v.schemas.get(key, stamp); ok {
+			return schema, nil
+		}
+	}
+
+	raw, err := v.templateService.LoadSchema(templateID, fileType)
+	if err != nil {
+		return nil, err
+	}
+	var schema *jsonschema.Schema
+	if raw != nil {
+		name := key + ".schema.json"
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(name, bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %v", name, err)
+		}
+		if schema, err = compiler.Compile(name); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %v", name, err)
+		}
+	}
+	if stamp != "" {
+		v.schemas.put(key, stamp, schema)
+	}
+	return schema, nil
+}
+
+// schemaPropertyRe 匹配校验信息中用引号括起的属性名
+var schemaPropertyRe = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// collectFieldErrors 收集最底层的校验错误，缺少字段和多余字段按字段逐个列出
+func collectFieldErrors(err *jsonschema.ValidationError, prefix string, out *[]model.FieldError) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			collectFieldErrors(cause, prefix, out)
+		}
+		return
+	}
+
+	field := instanceField(prefix, err.InstanceLocation)
+	switch {
+	case strings.HasSuffix(err.KeywordLocation, "/required"):
+		for _, m := range schemaPropertyRe.FindAllStringSubmatch(err.Message, -1) {
+			*out = append(*out, model.FieldError{Field: field + "." + m[1], Message: "is required"})
+		}
+	case strings.HasSuffix(err.KeywordLocation, "/additionalProperties"):
+		for _, m := range schemaPropertyRe.FindAllStringSubmatch(err.Message, -1) {
+			*out = append(*out, model.FieldError{Field: field + "." + m[1], Message: "is not allowed"})
+		}
+	default:
+		*out = append(*out, model.FieldError{Field: field, Message: err.Message})
+	}
+}
+
+// instanceField 将JSON Pointer形式的数据位置转换为字段路径，如 /items/0/数量 -> data.items[0].数量
+func instanceField(prefix, pointer string) string {
+	var buf strings.Builder
+	buf.WriteString(prefix)
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if _, err := strconv.Atoi(token); err == nil {
+			buf.WriteString("[" + token + "]")
+		} else {
+			buf.WriteString("." + token)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+)
+
+func TestValidateInvalidMeta(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"word/good.meta.yaml": []byte("required_fields: [title]\n"),
+		"word/bad.meta.yaml":  []byte("required_fields: [title\n"),
+	})
+	v := newSchemaValidator(template.NewTemplateService())
+
+	errs, err := v.Validate("word", &model.ExportRequest{TemplateID: "good", Data: map[string]interface{}{}})
+	if err != nil || len(errs) != 1 || errs[0].Field != "data.title" {
+		t.Errorf("Validate(good) = %v, %v; want data.title is required", errs, err)
+	}
+	if _, err := v.Validate("word", &model.ExportRequest{TemplateID: "bad", Data: map[string]interface{}{}}); err == nil || !strings.Contains(err.Error(), "word/bad.meta.yaml") {
+		t.Errorf("Validate(bad) error = %v, want an error naming the meta file", err)
+	}
+}
+
+// quoteSchema 要求 title 为字符串、items[].数量 为数字的Schema
+const quoteSchema = `{
+	"type": "object",
+	"required": ["title"],
+	"properties": {
+		"title": {"type": "string"},
+		"items": {"type": "array", "items": {"type": "object", "properties": {"数量": {"type": "number"}}}}
+	}
+}`
+
+func TestValidateSchemaFieldErrors(t *testing.T) {
+	useTemplateDir(t, map[string][]byte{
+		"word/quote.docx":        []byte("docx"),
+		"word/quote.schema.json": []byte(quoteSchema),
+	})
+	v := newSchemaValidator(template.NewTemplateService())
+
+	data := map[string]interface{}{"items": []interface{}{map[string]interface{}{"数量": "两个"}}}
+	errs, err := v.Validate("word", &model.ExportRequest{TemplateID: "quote", Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.FieldError{
+		{Field: "data.title", Message: "is required"},
+		{Field: "data.items[0].数量", Message: "expected number, but got string"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Validate = %v, want %v", errs, want)
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	dir := useTemplateDir(t, map[string][]byte{
+		"word/quote.docx":        []byte("docx"),
+		"word/quote.schema.json": []byte(quoteSchema),
+	})
+	svc := template.NewTemplateService()
+	v := newSchemaValidator(svc)
+	req := &model.ExportRequest{TemplateID: "quote", Data: map[string]interface{}{}}
+
+	if errs, err := v.Validate("word", req); err != nil || len(errs) != 1 {
+		t.Fatalf("Validate = %v, %v; want data.title is required", errs, err)
+	}
+	first, _ := v.schemas.get("word/quote", svc.TemplateStamp("quote", "word"))
+	if first == nil {
+		t.Fatal("schema is not cached")
+	}
+	if _, err := v.Validate("word", req); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := v.schemas.get("word/quote", svc.TemplateStamp("quote", "word")); again != first {
+		t.Error("schema was compiled again for an unchanged template")
+	}
+
+	// 修改Schema并重新加载模板后使用新的Schema
+	if err := os.WriteFile(filepath.Join(dir, "word", "quote.schema.json"), []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if errs, err := v.Validate("word", req); err != nil || len(errs) != 0 {
+		t.Errorf("Validate after reload = %v, %v; want no errors", errs, err)
+	}
+}
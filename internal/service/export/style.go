@@ -0,0 +1,146 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// styleSheet 按名称索引的命名样式
+type styleSheet map[string]model.StyleDef
+
+// loadStyleSheet 加载模板可用的命名样式：依次合并共用样式文件、模板的样式文件和请求的 data.styles，同名样式后者覆盖前者
+func loadStyleSheet(templateService template.TemplateService, fileType, templateID string, data map[string]interface{}) (styleSheet, error) {
+	styles := make(styleSheet)
+
+	shared, err := templateService.LoadSharedStyles()
+	if err != nil {
+		return nil, err
+	}
+	if err := styles.mergeYAML(shared, "shared styles"); err != nil {
+		return nil, err
+	}
+
+	own, err := templateService.LoadStyles(templateID, fileType)
+	if err != nil {
+		return nil, err
+	}
+	if err := styles.mergeYAML(own, fmt.Sprintf("styles of template %s", templateID)); err != nil {
+		return nil, err
+	}
+
+	if raw, ok := data["styles"]; ok {
+		var defs map[string]model.StyleDef
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid data.styles: %v", ErrInvalidData, err)
+		}
+		if err := json.Unmarshal(encoded, &defs); err != nil {
+			return nil, fmt.Errorf("%w: invalid data.styles: %v", ErrInvalidData, err)
+		}
+		for name, def := range defs {
+			styles[name] = def
+		}
+	}
+
+	for name, def := range styles {
+		if err := validateStyle(def); err != nil {
+			return nil, fmt.Errorf("invalid style %s: %v", name, err)
+		}
+	}
+	return styles, nil
+}
+
+// mergeYAML 合并YAML（或JSON）格式的样式文件内容
+func (s styleSheet) mergeYAML(raw []byte, source string) error {
+	if raw == nil {
+		return nil
+	}
+	var defs map[string]model.StyleDef
+	if err := yaml.Unmarshal(raw, &defs); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", source, err)
+	}
+	for name, def := range defs {
+		s[name] = def
+	}
+	return nil
+}
+
+// lookup 按名称查找样式
+func (s styleSheet) lookup(name string) (model.StyleDef, error) {
+	def, ok := s[name]
+	if !ok {
+		return model.StyleDef{}, fmt.Errorf("style not defined: %s", name)
+	}
+	return def, nil
+}
+
+// colorRe 匹配 #RRGGBB 形式的颜色，# 可省略
+var colorRe = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// borderStyles 边框样式名称对应的Excel边框样式编号
+var borderStyles = map[string]int{
+	"thin":   1,
+	"medium": 2,
+	"dashed": 3,
+	"dotted": 4,
+	"thick":  5,
+	"double": 6,
+}
+
+// 边框的四条边，未指定时使用全部
+var borderSides = []string{"left", "top", "right", "bottom"}
+
+// validateStyle 检查样式中的颜色、边框和对齐方式是否有效
+func validateStyle(def model.StyleDef) error {
+	colors := []string{def.Fill}
+	if def.Font != nil {
+		colors = append(colors, def.Font.Color)
+	}
+	if def.Border != nil {
+		colors = append(colors, def.Border.Color)
+		if _, ok := borderStyles[def.Border.Style]; def.Border.Style != "" && !ok {
+			return fmt.Errorf("unknown border style: %s", def.Border.Style)
+		}
+		for _, side := range def.Border.Sides {
+			if !containsString(borderSides, side) {
+				return fmt.Errorf("unknown border side: %s", side)
+			}
+		}
+	}
+	for _, color := range colors {
+		if color != "" && !colorRe.MatchString(color) {
+			return fmt.Errorf("invalid color %q, expected #RRGGBB", color)
+		}
+	}
+	if def.Align != nil {
+		if h := def.Align.Horizontal; h != "" && !containsString([]string{"left", "center", "right"}, h) {
+			return fmt.Errorf("unknown horizontal alignment: %s", h)
+		}
+		if v := def.Align.Vertical; v != "" && !containsString([]string{"top", "center", "bottom"}, v) {
+			return fmt.Errorf("unknown vertical alignment: %s", v)
+		}
+	}
+	return nil
+}
+
+// normalizeColor 统一颜色格式为 #RRGGBB
+func normalizeColor(color string) string {
+	return "#" + strings.ToUpper(strings.TrimPrefix(color, "#"))
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
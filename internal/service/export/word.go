@@ -2,19 +2,450 @@ package export
 
 import (
 	"fmt"
+	"log"
+	"regexp"
+	"strings"
 
 	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
 )
 
 // WordService Word导出服务
-type WordService struct{}
+type WordService struct {
+	templateService template.TemplateService
+}
 
 // NewWordService 创建Word导出服务实例
-func NewWordService() *WordService {
-	return &WordService{}
+func NewWordService(templateService template.TemplateService) *WordService {
+	return &WordService{
+		templateService: templateService,
+	}
 }
 
-// ExportWord 导出Word文件（暂时简化实现）
+// ExportWord 导出Word文件，使用请求数据替换模板中的 {{field}} 占位符
 func (s *WordService) ExportWord(req *model.ExportRequest) ([]byte, error) {
-	return nil, fmt.Errorf("Word export feature is currently under development, please try Excel or PDF export instead")
+	templateID := req.TemplateID
+	if templateID == "" {
+		templateID = "default"
+	}
+
+	// 加载模板文件
+	templateData, err := s.templateService.LoadTemplate(templateID, "word")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %v", err)
+	}
+
+	pkg, err := openDocxPackage(templateData)
+	if err != nil {
+		return nil, err
+	}
+
+	// 正文、页眉和页脚，先合并被拆分的占位符，保证每个占位符都位于同一个文本节点中
+	var parts []string
+	for _, part := range pkg.names {
+		if part != "word/document.xml" && !headerFooterPartRe.MatchString(part) {
+			continue
+		}
+		xml, _ := replaceElements(string(pkg.parts[part]), "w:p", func(p string) (string, error) {
+			return mergeSplitPlaceholders(p), nil
+		})
+		pkg.setPart(part, []byte(xml))
+		parts = append(parts, part)
+	}
+
+	renderer := &wordRenderer{
+		pkg:             pkg,
+		templateService: s.templateService,
+		images:          make(map[string]*wordImage),
+	}
+
+	// 模板引用了 {{totals.xxx}} 或 {{@amount}} 时才根据明细计算金额汇总，
+	// 没有金额占位符的模板（如合同）不要求明细中的单价和数量为数字
+	scope := newDataScope(req.Data, nil)
+	if usesTotals(pkg, parts) {
+		pricing, err := parsePricing(req.Data, "items")
+		if err != nil {
+			return nil, err
+		}
+		items, _ := scope.lookup(pricing.Items)
+		totals, err := computeTotals(toItems(items), pricing)
+		if err != nil {
+			return nil, err
+		}
+		scope.setVar("totals", totals.values())
+		renderer.totals = totals
+	}
+
+	// 依次渲染正文、页眉和页脚
+	for _, part := range parts {
+		if err := renderer.renderPart(part, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	return pkg.bytes()
+}
+
+// usesTotals 判断模板是否引用了金额汇总：{{totals.xxx}}、{{@amount}}，以及以它们为条件的区块
+func usesTotals(pkg *docxPackage, parts []string) bool {
+	for _, part := range parts {
+		if referencesTotals(string(pkg.parts[part])) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerFooterPartRe 匹配页眉页脚部件
+var headerFooterPartRe = regexp.MustCompile(`^word/(header|footer)\d*\.xml$`)
+
+// maxWordImageWidth 未指定尺寸时图片的最大宽度（像素），约为A4纵向版心宽度
+const maxWordImageWidth = 600
+
+// wordRenderer Word模板渲染器
+type wordRenderer struct {
+	pkg             *docxPackage
+	templateService template.TemplateService
+	part            string
+	totals          *orderTotals          // 模板未引用金额汇总时为 nil
+	images          map[string]*wordImage // 已嵌入的图片（部件+来源+尺寸），避免重复嵌入
+	imageSeq        int
+}
+
+// wordImage 已嵌入文档包的图片
+type wordImage struct {
+	relID    string
+	fileName string
+	width    float64
+	height   float64
+}
+
+// renderPart 渲染一个XML部件（正文、页眉或页脚），部件中被拆分的占位符须已合并
+func (r *wordRenderer) renderPart(part string, scope *dataScope) error {
+	r.part = part
+	xml, err := r.render(string(r.pkg.parts[part]), scope)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", part, err)
+	}
+	r.pkg.setPart(part, []byte(xml))
+	return nil
+}
+
+// render 在指定作用域下渲染XML片段
+func (r *wordRenderer) render(xml string, scope *dataScope) (string, error) {
+	xml, err := r.renderBlocks(xml, scope)
+	if err != nil {
+		return "", err
+	}
+
+	xml, err = r.renderTableRows(xml, scope)
+	if err != nil {
+		return "", err
+	}
+
+	return replaceElements(xml, "w:r", func(run string) (string, error) {
+		return r.renderRun(run, scope)
+	})
+}
+
+// blockMarkerRe 匹配独占一个段落的区块标记：{{#if field}}、{{#each list}}、{{else}}、{{/if}}、{{/each}}
+var blockMarkerRe = regexp.MustCompile(`^\{\{\s*(#if|#each|else|/if|/each)\s*([^{}]*?)\s*\}\}$`)
+
+// unknownBlockRe 匹配独占一个段落、带参数但不受支持的区块标记，如 {{#unless field}}
+var unknownBlockRe = regexp.MustCompile(`^\{\{\s*#(\S+)\s+[^{}]+\}\}$`)
+
+// blockMarker 区块标记段落
+type blockMarker struct {
+	kind string
+	arg  string
+	span xmlSpan
+}
+
+// renderBlocks 处理跨段落的 {{#if}}…{{else}}…{{/if}} 条件区块和 {{#each}}…{{/each}} 循环区块
+func (r *wordRenderer) renderBlocks(xml string, scope *dataScope) (string, error) {
+	var markers []blockMarker
+	for _, span := range findElements(xml, "w:p") {
+		text := strings.TrimSpace(elementText(xml[span.start:span.end]))
+		if m := blockMarkerRe.FindStringSubmatch(text); m != nil {
+			markers = append(markers, blockMarker{kind: m[1], arg: m[2], span: span})
+		} else if m := unknownBlockRe.FindStringSubmatch(text); m != nil {
+			return "", fmt.Errorf("unsupported block {{#%s}}, use {{#if}} or {{#each}}", m[1])
+		}
+	}
+	if len(markers) == 0 {
+		return xml, nil
+	}
+
+	var buf strings.Builder
+	last := 0
+	for i := 0; i < len(markers); {
+		open := markers[i]
+		if open.kind != "#if" && open.kind != "#each" {
+			return "", fmt.Errorf("unexpected {{%s}} without matching block start", open.kind)
+		}
+		if open.arg == "" {
+			return "", fmt.Errorf("{{%s}} requires a field name", open.kind)
+		}
+
+		// 查找与之匹配的 {{else}} 和结束标记
+		depth, elseIdx, closeIdx := 0, -1, -1
+		for j := i + 1; j < len(markers) && closeIdx < 0; j++ {
+			switch markers[j].kind {
+			case "#if", "#each":
+				depth++
+			case "else":
+				if depth == 0 {
+					elseIdx = j
+				}
+			default:
+				if depth == 0 {
+					closeIdx = j
+				} else {
+					depth--
+				}
+			}
+		}
+		if closeIdx < 0 {
+			return "", fmt.Errorf("{{%s %s}} is not closed", open.kind, open.arg)
+		}
+		if markers[closeIdx].kind != "/"+open.kind[1:] {
+			return "", fmt.Errorf("{{%s %s}} is closed by {{%s}}", open.kind, open.arg, markers[closeIdx].kind)
+		}
+
+		body, alternative := xml[open.span.end:markers[closeIdx].span.start], ""
+		if elseIdx >= 0 {
+			body = xml[open.span.end:markers[elseIdx].span.start]
+			alternative = xml[markers[elseIdx].span.end:markers[closeIdx].span.start]
+		}
+		if !isBalancedBlock(body) || !isBalancedBlock(alternative) {
+			return "", fmt.Errorf("{{%s %s}} must start and end in the same table cell or document body", open.kind, open.arg)
+		}
+
+		rendered, err := r.renderBlock(open, body, alternative, scope)
+		if err != nil {
+			return "", err
+		}
+
+		buf.WriteString(xml[last:open.span.start])
+		buf.WriteString(rendered)
+		last = markers[closeIdx].span.end
+		i = closeIdx + 1
+	}
+	buf.WriteString(xml[last:])
+	return buf.String(), nil
+}
+
+// renderBlock 渲染单个区块；{{#each}} 的 {{else}} 部分在列表为空时输出
+func (r *wordRenderer) renderBlock(marker blockMarker, body, alternative string, scope *dataScope) (string, error) {
+	value, _ := scope.lookup(marker.arg)
+
+	if marker.kind == "#if" {
+		if isTruthy(value) {
+			return r.render(body, scope)
+		}
+		return r.render(alternative, scope)
+	}
+
+	items := toItems(value)
+	if len(items) == 0 {
+		return r.render(alternative, scope)
+	}
+
+	var buf strings.Builder
+	for i, item := range items {
+		rendered, err := r.render(body, r.itemScope(scope, marker.arg, item, i))
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(rendered)
+	}
+	return buf.String(), nil
+}
+
+// isBalancedBlock 判断区块内的表格结构是否完整，防止区块跨越单元格边界：
+// 每个表格、行和单元格的结束标签之前须有对应的开始标签，且全部闭合
+func isBalancedBlock(xml string) bool {
+	for _, tag := range []string{"w:tbl", "w:tr", "w:tc"} {
+		depth := 0
+		for i := 0; i < len(xml); {
+			j := strings.Index(xml[i:], "<")
+			if j < 0 {
+				break
+			}
+			i += j + 1
+			switch {
+			case strings.HasPrefix(xml[i:], "/"+tag+">"):
+				if depth--; depth < 0 {
+					return false
+				}
+			case strings.HasPrefix(xml[i:], tag) && isTagBoundary(xml, i+len(tag)):
+				if end := strings.Index(xml[i:], ">"); end < 0 || xml[i+end-1] != '/' {
+					depth++
+				}
+			}
+		}
+		if depth != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rowMarkerRe 匹配表格行的循环标记 {{#items}}
+var rowMarkerRe = regexp.MustCompile(`\{\{#([^\s{}]+)\}\}`)
+
+// renderTableRows 将带有 {{#items}}…{{/items}} 标记的表格行按数组元素逐行复制
+func (r *wordRenderer) renderTableRows(xml string, scope *dataScope) (string, error) {
+	return replaceElements(xml, "w:tr", func(row string) (string, error) {
+		m := rowMarkerRe.FindStringSubmatch(elementText(row))
+		if m == nil {
+			return row, nil
+		}
+
+		name := m[1]
+		row = strings.ReplaceAll(row, "{{#"+name+"}}", "")
+		row = strings.ReplaceAll(row, "{{/"+name+"}}", "")
+
+		value, _ := scope.lookup(name)
+		var buf strings.Builder
+		for i, item := range toItems(value) {
+			rendered, err := r.render(row, r.itemScope(scope, name, item, i))
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(rendered)
+		}
+		return buf.String(), nil
+	})
+}
+
+// itemScope 创建循环元素的子作用域，遍历计价明细时可通过 {{@amount}} 引用该行金额
+func (r *wordRenderer) itemScope(scope *dataScope, list string, item interface{}, index int) *dataScope {
+	child := scope.child(item, index)
+	if r.totals != nil && list == r.totals.pricing.Items {
+		child.setVar("@amount", r.totals.lineValue(index))
+	}
+	return child
+}
+
+// renderRun 替换文本段中的占位符
+func (r *wordRenderer) renderRun(run string, scope *dataScope) (string, error) {
+	if !strings.Contains(run, "{{") {
+		return run, nil
+	}
+
+	// 文本段格式，拆分文本段（如插入页码域）时沿用
+	runProps := ""
+	if spans := findElements(run, "w:rPr"); len(spans) > 0 {
+		runProps = run[spans[0].start:spans[0].end]
+	}
+
+	var renderErr error
+	result := textNodeRe.ReplaceAllStringFunc(run, func(node string) string {
+		m := textNodeRe.FindStringSubmatch(node)
+		text := m[2]
+		if !strings.Contains(text, "{{") {
+			return node
+		}
+
+		var buf strings.Builder
+		buf.WriteString(`<w:t xml:space="preserve">`)
+		last := 0
+		for _, loc := range placeholderRe.FindAllStringIndex(text, -1) {
+			buf.WriteString(text[last:loc[0]])
+			value, err := r.placeholderXML(xmlUnescaper.Replace(text[loc[0]:loc[1]]), scope, runProps)
+			if err != nil && renderErr == nil {
+				renderErr = err
+			}
+			buf.WriteString(value)
+			last = loc[1]
+		}
+		buf.WriteString(text[last:])
+		buf.WriteString("</w:t>")
+		return buf.String()
+	})
+	if renderErr != nil {
+		return "", renderErr
+	}
+	return result, nil
+}
+
+// placeholderXML 生成占位符替换后的XML（位于 <w:t> 内部）
+func (r *wordRenderer) placeholderXML(placeholder string, scope *dataScope, runProps string) (string, error) {
+	name := placeholderName(placeholder)
+	switch name {
+	case "@page":
+		return pageFieldXML("PAGE", runProps), nil
+	case "@pages":
+		return pageFieldXML("NUMPAGES", runProps), nil
+	}
+	if strings.HasPrefix(name, "image:") {
+		return r.imageXML(strings.TrimSpace(strings.TrimPrefix(name, "image:")), scope)
+	}
+
+	value, _ := scope.evaluate(name)
+	return wordTextXML(formatValue(value)), nil
+}
+
+// imageXML 将 {{image:field}} 占位符替换为嵌入式图片
+func (r *wordRenderer) imageXML(name string, scope *dataScope) (string, error) {
+	value, _ := scope.lookup(name)
+	imageData, err := parseImageData(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid image %s: %v", ErrInvalidData, name, err)
+	}
+	if imageData == nil {
+		return "", nil
+	}
+
+	cacheKey := fmt.Sprintf("%s|%s|%v", r.part, imageData.Path, imageData.Size)
+	embedded, ok := r.images[cacheKey]
+	if !ok {
+		img, err := loadImage(imageData.Path, r.templateService)
+		if err != nil {
+			log.Printf("插入图片%s失败：%v", name, err)
+			// 图片插入失败不影响整体导出，继续执行
+			return "", nil
+		}
+
+		embedded = &wordImage{}
+		embedded.width, embedded.height = fitImageSize(img, imageData.Size, maxWordImageWidth)
+		embedded.relID, embedded.fileName = r.pkg.addImage(r.part, img.data, img.ext)
+		r.images[cacheKey] = embedded
+	}
+
+	// docPr id 需在文档内唯一，从较大的值开始编号以避开模板中已有的图形
+	r.imageSeq++
+	drawing := inlineImageXML(embedded.relID, embedded.fileName, 10000+r.imageSeq, embedded.width, embedded.height)
+	return `</w:t>` + drawing + `<w:t xml:space="preserve">`, nil
+}
+
+// pageFieldXML 生成页码域（PAGE/NUMPAGES），由Word在打开和打印时计算实际页码
+func pageFieldXML(instr, runProps string) string {
+	var buf strings.Builder
+	buf.WriteString(`</w:t></w:r>`)
+	for _, content := range []string{
+		`<w:fldChar w:fldCharType="begin"/>`,
+		`<w:instrText xml:space="preserve"> ` + instr + ` </w:instrText>`,
+		`<w:fldChar w:fldCharType="separate"/>`,
+		`<w:t>1</w:t>`,
+		`<w:fldChar w:fldCharType="end"/>`,
+	} {
+		buf.WriteString(`<w:r>` + runProps + content + `</w:r>`)
+	}
+	buf.WriteString(`<w:r>` + runProps + `<w:t xml:space="preserve">`)
+	return buf.String()
+}
+
+// wordTextEscaper 转义XML特殊字符，同时转义花括号，避免数据中的 {{ }} 被再次当作占位符
+var wordTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "{", "&#123;", "}", "&#125;")
+
+// wordTextXML 转义文本，并将换行转换为 <w:br/>
+func wordTextXML(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = wordTextEscaper.Replace(line)
+	}
+	return strings.Join(lines, `</w:t><w:br/><w:t xml:space="preserve">`)
 }
@@ -0,0 +1,371 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/template"
+)
+
+const wordNamespace = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+
+// wordParagraph 段落，每段文本为一个文本段（Word编辑时常把一个占位符拆到多个文本段中）
+func wordParagraph(texts ...string) string {
+	var buf strings.Builder
+	buf.WriteString("<w:p>")
+	for _, text := range texts {
+		buf.WriteString(`<w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">` + xmlEscaper.Replace(text) + `</w:t></w:r>`)
+	}
+	buf.WriteString("</w:p>")
+	return buf.String()
+}
+
+// wordTable 表格，每行为各单元格的文本
+func wordTable(rows ...[]string) string {
+	var buf strings.Builder
+	buf.WriteString("<w:tbl>")
+	for _, row := range rows {
+		buf.WriteString("<w:tr>")
+		for _, cell := range row {
+			buf.WriteString("<w:tc>" + wordParagraph(cell) + "</w:tc>")
+		}
+		buf.WriteString("</w:tr>")
+	}
+	buf.WriteString("</w:tbl>")
+	return buf.String()
+}
+
+// docxTemplate 生成最小的docx模板，body 为正文内容，parts 为额外的部件（如页眉页脚）
+func docxTemplate(t *testing.T, body string, parts map[string]string) []byte {
+	t.Helper()
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="xml" ContentType="application/xml"/></Types>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8"?><w:document ` + wordNamespace + `><w:body>` + body + `</w:body></w:document>`},
+	}
+	for name, content := range parts {
+		files = append(files, struct{ name, content string }{name, content})
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, file := range files {
+		fw, err := w.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, file.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exportWord 使用 body 生成的模板导出Word，返回导出文件的部件
+func exportWord(t *testing.T, body string, parts map[string]string, data map[string]interface{}) (*docxPackage, error) {
+	t.Helper()
+	useTemplateDir(t, map[string][]byte{"word/test.docx": docxTemplate(t, body, parts)})
+	out, err := NewWordService(template.NewTemplateService()).ExportWord(&model.ExportRequest{TemplateID: "test", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := openDocxPackage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range pkg.names {
+		if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels") {
+			checkWellFormed(t, name, pkg.parts[name])
+		}
+	}
+	return pkg, nil
+}
+
+// checkWellFormed 检查部件为格式正确的XML
+func checkWellFormed(t *testing.T, name string, data []byte) {
+	t.Helper()
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("%s is not well-formed: %v\n%s", name, err, data)
+		}
+	}
+}
+
+// wordText XML片段中全部文本节点解码后的文本
+func wordText(t *testing.T, s string) string {
+	t.Helper()
+	var buf strings.Builder
+	for _, m := range textNodeRe.FindAllStringSubmatch(s, -1) {
+		var text string
+		if err := xml.Unmarshal([]byte("<t>"+m[2]+"</t>"), &text); err != nil {
+			t.Fatalf("invalid text node %q: %v", m[0], err)
+		}
+		buf.WriteString(text)
+	}
+	return buf.String()
+}
+
+// documentText 正文的全部文本，段落之间以换行分隔
+func documentText(t *testing.T, pkg *docxPackage) string {
+	t.Helper()
+	doc := string(pkg.parts["word/document.xml"])
+	var lines []string
+	for _, span := range findElements(doc, "w:p") {
+		lines = append(lines, wordText(t, doc[span.start:span.end]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestExportWordPlaceholders(t *testing.T) {
+	body := wordParagraph("客户：{{client.name}}") +
+		wordParagraph("备注：{{note}}") +
+		wordParagraph("金额：{{amount}}，数量：{{数量}}，缺失：{{missing}}。")
+	pkg, err := exportWord(t, body, nil, map[string]interface{}{
+		"client": map[string]interface{}{"name": "张三"},
+		"note":   "A & B <c> {{x}}\n第二行",
+		"amount": 1234.5,
+		"数量":     "1套",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "客户：张三\n备注：A & B <c> {{x}}第二行\n金额：1234.5，数量：1套，缺失：。"
+	if got := documentText(t, pkg); got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	doc := string(pkg.parts["word/document.xml"])
+	if !strings.Contains(doc, "A &amp; B &lt;c&gt; &#123;&#123;x&#125;&#125;</w:t><w:br/>") {
+		t.Errorf("note is not escaped or the line break is missing:\n%s", doc)
+	}
+}
+
+func TestMergeSplitPlaceholders(t *testing.T) {
+	paragraph := `<w:p><w:r><w:t>客户：{{cli</w:t></w:r><w:r><w:rPr><w:i/></w:rPr><w:t>ent.na</w:t></w:r><w:r><w:t>me}}，电话</w:t></w:r></w:p>`
+	merged := mergeSplitPlaceholders(paragraph)
+
+	var texts []string
+	for _, m := range textNodeRe.FindAllStringSubmatch(merged, -1) {
+		texts = append(texts, m[2])
+	}
+	if got := strings.Join(texts, "|"); got != "客户：{{client.name}}||，电话" {
+		t.Errorf("text nodes = %q", got)
+	}
+	if !strings.Contains(merged, "<w:rPr><w:i/></w:rPr>") {
+		t.Errorf("run properties were dropped: %s", merged)
+	}
+
+	unsplit := `<w:p><w:r><w:t>{{a}}</w:t></w:r><w:r><w:t>{{b}}</w:t></w:r></w:p>`
+	if got := mergeSplitPlaceholders(unsplit); got != unsplit {
+		t.Errorf("paragraph without split placeholders changed: %s", got)
+	}
+
+	pkg, err := exportWord(t, wordParagraph("客户：{{cli", "ent.na", "me}}"), nil, map[string]interface{}{
+		"client": map[string]interface{}{"name": "张三"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := documentText(t, pkg); got != "客户：张三" {
+		t.Errorf("text = %q, want 客户：张三", got)
+	}
+}
+
+func TestExportWordTableRows(t *testing.T) {
+	body := wordTable(
+		[]string{"序号", "品名", "数量"},
+		[]string{"{{#items}}{{@index}}", "{{name}}", "{{数量}}{{/items}}"},
+		[]string{"合计", "", "{{total}}"},
+	)
+	items := []interface{}{
+		map[string]interface{}{"name": "灯具", "数量": 2.0},
+		map[string]interface{}{"name": "拆除", "数量": "按实结算"},
+	}
+
+	pkg, err := exportWord(t, body, nil, map[string]interface{}{"items": items, "total": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := documentText(t, pkg), "序号\n品名\n数量\n1\n灯具\n2\n2\n拆除\n按实结算\n合计\n\n3"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+
+	pkg, err = exportWord(t, body, nil, map[string]interface{}{"items": []interface{}{}, "total": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(findElements(string(pkg.parts["word/document.xml"]), "w:tr")); got != 2 {
+		t.Errorf("rows with empty items = %d, want 2", got)
+	}
+}
+
+// pngDataURI 生成 width×height 的PNG图片的 data: URI
+func pngDataURI(t *testing.T, width, height int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestExportWordImages(t *testing.T) {
+	body := wordParagraph("{{image:logo}}") + wordParagraph("{{image:logo}}")
+	logo := map[string]interface{}{"path": pngDataURI(t, 40, 20), "size": map[string]interface{}{"width": 80}}
+
+	pkg, err := exportWord(t, body, nil, map[string]interface{}{"logo": logo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(pkg.parts["word/document.xml"])
+	if n := strings.Count(doc, "<w:drawing>"); n != 2 {
+		t.Errorf("document has %d drawings, want 2", n)
+	}
+	// 80×40 像素
+	if !strings.Contains(doc, `<wp:extent cx="762000" cy="381000"/>`) {
+		t.Errorf("image size not scaled by width:\n%s", doc)
+	}
+	if _, ok := pkg.parts["word/media/export_image1.png"]; !ok {
+		t.Errorf("image part missing, parts: %v", pkg.names)
+	}
+	if _, ok := pkg.parts["word/media/export_image2.png"]; ok {
+		t.Error("the same image was embedded twice")
+	}
+	if rels := string(pkg.parts["word/_rels/document.xml.rels"]); !strings.Contains(rels, `Target="media/export_image1.png"`) {
+		t.Errorf("relationship missing: %s", rels)
+	}
+	if types := string(pkg.parts["[Content_Types].xml"]); !strings.Contains(types, `Extension="png"`) {
+		t.Errorf("png content type missing: %s", types)
+	}
+
+	// 图片加载失败时跳过该图片，不影响导出
+	for _, path := range []string{"images/missing.png", "data:image/png;base64,aGVsbG8="} {
+		pkg, err := exportWord(t, body, nil, map[string]interface{}{"logo": path})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if doc := string(pkg.parts["word/document.xml"]); strings.Contains(doc, "<w:drawing>") {
+			t.Errorf("%s: drawing inserted for an image that failed to load", path)
+		}
+		for _, name := range pkg.names {
+			if strings.HasPrefix(name, "word/media/") {
+				t.Errorf("%s: unexpected part %s", path, name)
+			}
+		}
+	}
+
+	if _, err := exportWord(t, body, nil, map[string]interface{}{"logo": 5.0}); err == nil || !strings.Contains(err.Error(), "invalid image logo") {
+		t.Errorf("numeric image value: %v, want invalid image logo", err)
+	}
+}
+
+func TestExportWordBlocks(t *testing.T) {
+	body := wordParagraph("{{#if vip}}") + wordParagraph("尊敬的VIP客户") + wordParagraph("{{else}}") + wordParagraph("尊敬的客户") + wordParagraph("{{/if}}") +
+		wordParagraph("{{#each rooms}}") + wordParagraph("{{@index}}. {{name}}") +
+		wordParagraph("{{#if note}}") + wordParagraph("备注：{{note}}") + wordParagraph("{{/if}}") +
+		wordParagraph("{{else}}") + wordParagraph("无房间") + wordParagraph("{{/each}}")
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{"if true, each items", map[string]interface{}{
+			"vip": true,
+			"rooms": []interface{}{
+				map[string]interface{}{"name": "客厅", "note": "吊顶"},
+				map[string]interface{}{"name": "卧室"},
+			},
+		}, "尊敬的VIP客户\n1. 客厅\n备注：吊顶\n2. 卧室"},
+		{"else branches", map[string]interface{}{"vip": false, "rooms": []interface{}{}}, "尊敬的客户\n无房间"},
+	}
+	for _, tt := range tests {
+		pkg, err := exportWord(t, body, nil, tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := documentText(t, pkg); got != tt.want {
+			t.Errorf("%s: text = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportWordBlockAroundTable(t *testing.T) {
+	body := wordParagraph("{{#if show}}") + wordTable([]string{"{{a}}", "{{b}}"}) + wordParagraph("{{/if}}")
+	for show, want := range map[bool]string{true: "1\n2", false: ""} {
+		pkg, err := exportWord(t, body, nil, map[string]interface{}{"show": show, "a": 1.0, "b": 2.0})
+		if err != nil {
+			t.Fatalf("show=%v: %v", show, err)
+		}
+		if got := documentText(t, pkg); got != want {
+			t.Errorf("show=%v: text = %q, want %q", show, got, want)
+		}
+	}
+}
+
+func TestExportWordInvalidBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"not closed", wordParagraph("{{#if a}}") + wordParagraph("x"), "{{#if a}} is not closed"},
+		{"close without start", wordParagraph("x") + wordParagraph("{{/if}}"), "unexpected {{/if}} without matching block start"},
+		{"else without start", wordParagraph("{{else}}"), "unexpected {{else}} without matching block start"},
+		{"mismatched close", wordParagraph("{{#if a}}") + wordParagraph("x") + wordParagraph("{{/each}}"), "{{#if a}} is closed by {{/each}}"},
+		{"missing field", wordParagraph("{{#each}}") + wordParagraph("{{/each}}"), "{{#each}} requires a field name"},
+		{"unknown block", wordParagraph("{{#unless a}}") + wordParagraph("x") + wordParagraph("{{/unless}}"), "unsupported block {{#unless}}"},
+		{"across cells", wordTable([]string{"{{#if a}}", "{{/if}}"}), "must start and end in the same table cell"},
+	}
+	for _, tt := range tests {
+		_, err := exportWord(t, tt.body, nil, map[string]interface{}{"a": true})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestExportWordHeaderFooter(t *testing.T) {
+	parts := map[string]string{
+		"word/header1.xml": `<w:hdr ` + wordNamespace + `>` + wordParagraph("{{company}}") + `</w:hdr>`,
+		"word/footer1.xml": `<w:ftr ` + wordNamespace + `>` + wordParagraph("第{{@page}}页 / 共{{@pages}}页") + `</w:ftr>`,
+	}
+	pkg, err := exportWord(t, wordParagraph("{{title}}"), parts, map[string]interface{}{"company": "某装饰公司", "title": "合同"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header := wordText(t, string(pkg.parts["word/header1.xml"])); header != "某装饰公司" {
+		t.Errorf("header text = %q", header)
+	}
+	footer := string(pkg.parts["word/footer1.xml"])
+	for _, want := range []string{
+		`<w:instrText xml:space="preserve"> PAGE </w:instrText>`,
+		`<w:instrText xml:space="preserve"> NUMPAGES </w:instrText>`,
+		`<w:fldChar w:fldCharType="begin"/>`,
+		`<w:fldChar w:fldCharType="end"/>`,
+	} {
+		if !strings.Contains(footer, want) {
+			t.Errorf("footer missing %s:\n%s", want, footer)
+		}
+	}
+	// 页码域的文本段沿用占位符所在文本段的格式
+	if n := strings.Count(footer, "<w:rPr><w:b/></w:rPr>"); n < 10 {
+		t.Errorf("footer has %d formatted runs, want the field runs to keep the run properties:\n%s", n, footer)
+	}
+	if got := wordText(t, footer); got != "第1页 / 共1页" {
+		t.Errorf("footer text = %q", got)
+	}
+}
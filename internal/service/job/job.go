@@ -0,0 +1,410 @@
+package job
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+)
+
+var (
+	// ErrQueueFull 排队任务数已达上限
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrJobNotFound 任务不存在或已过期
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotReady 任务尚未成功完成，没有可下载的结果
+	ErrJobNotReady = errors.New("job result is not ready")
+	// ErrInvalidCallbackURL 回调地址的协议或主机不允许
+	ErrInvalidCallbackURL = errors.New("invalid callback_url")
+)
+
+// JobService 异步导出任务服务接口
+type JobService interface {
+	Submit(fileType string, req *model.JobRequest, jobsURL string) (*model.Job, error)
+	Get(id string) (*model.Job, error)
+	ResultPath(id string) (string, error)
+}
+
+// jobEntry 任务及其执行所需的数据
+type jobEntry struct {
+	job            model.Job
+	req            *model.ExportRequest
+	resultPath     string
+	callbackSecret string
+	downloadURL    string
+}
+
+// jobService 任务服务实现：有界队列 + 固定数量的工作协程，结果写入本地目录并按TTL清理
+type jobService struct {
+	exportService export.ExportService
+	resultDir     string
+	resultTTL     time.Duration
+	queue         chan *jobEntry
+	notifier      *webhookNotifier
+
+	mu   sync.RWMutex
+	jobs map[string]*jobEntry
+}
+
+// NewJobService 创建任务服务实例并启动工作协程
+func NewJobService(exportService export.ExportService) JobService {
+	cfg := config.Get().Job
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	s := &jobService{
+		exportService: exportService,
+		resultDir:     cfg.ResultDir,
+		resultTTL:     cfg.ResultTTL,
+		queue:         make(chan *jobEntry, cfg.QueueSize),
+		jobs:          make(map[string]*jobEntry),
+		notifier:      newWebhookNotifier(cfg.WebhookMaxAttempts, cfg.WebhookBackoff, cfg.WebhookTimeout, webhookPolicy),
+	}
+	if s.resultDir == "" {
+		s.resultDir = filepath.Join(os.TempDir(), "office-export-jobs")
+	}
+	if s.resultTTL <= 0 {
+		s.resultTTL = time.Hour
+	}
+
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	go s.cleanupLoop()
+
+	return s
+}
+
+// Submit 提交导出任务，队列已满时返回 ErrQueueFull，回调地址不允许时返回 ErrInvalidCallbackURL
+// jobsURL 为任务接口的外部访问地址（如 https://host/api/v1/jobs），用于生成回调中的下载地址，为空时不接受回调
+func (s *jobService) Submit(fileType string, req *model.JobRequest, jobsURL string) (*model.Job, error) {
+	if _, ok := export.LookupFormat(fileType); !ok {
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+	if req.CallbackURL != "" {
+		if jobsURL == "" {
+			return nil, fmt.Errorf("%w: job.public_url must be configured to use callbacks", ErrInvalidCallbackURL)
+		}
+		if _, err := webhookPolicy().CheckURL(req.CallbackURL); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCallbackURL, err)
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	entry := &jobEntry{
+		job: model.Job{
+			ID:         id,
+			Status:     model.JobQueued,
+			FileType:   fileType,
+			TemplateID: req.TemplateID,
+			CreatedAt:  time.Now(),
+		},
+		req:            &req.ExportRequest,
+		callbackSecret: req.CallbackSecret,
+		downloadURL:    strings.TrimSuffix(jobsURL, "/") + "/" + id + "/result",
+	}
+	if req.CallbackURL != "" {
+		entry.job.Callback = &model.JobCallback{
+			URL:    req.CallbackURL,
+			Status: model.CallbackPending,
+		}
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = entry
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- entry:
+	default:
+		s.mu.Lock()
+		delete(s.jobs, id)
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return entry.snapshot(), nil
+}
+
+// Get 获取任务状态
+func (s *jobService) Get(id string) (*model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return entry.snapshot(), nil
+}
+
+// ResultPath 获取已完成任务的结果文件路径
+func (s *jobService) ResultPath(id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.jobs[id]
+	if !ok {
+		return "", ErrJobNotFound
+	}
+	if entry.job.Status != model.JobSucceeded {
+		return "", ErrJobNotReady
+	}
+	return entry.resultPath, nil
+}
+
+// worker 从队列中依次取出任务执行
+func (s *jobService) worker() {
+	for entry := range s.queue {
+		s.run(entry)
+	}
+}
+
+// run 执行导出任务，进度按阶段更新：开始 10%，文件生成后 90%，结果写入后 100%
+func (s *jobService) run(entry *jobEntry) {
+	s.update(entry, func(job *model.Job) {
+		now := time.Now()
+		job.Status = model.JobRunning
+		job.Progress = 10
+		job.StartedAt = &now
+	})
+
+	path, size, checksum, err := s.export(entry)
+	if err != nil {
+		log.Printf("导出任务 %s 失败：%v", entry.job.ID, err)
+	}
+
+	s.update(entry, func(job *model.Job) {
+		now := time.Now()
+		expiresAt := now.Add(s.resultTTL)
+		job.FinishedAt = &now
+		job.ExpiresAt = &expiresAt
+		if err != nil {
+			job.Status = model.JobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = model.JobSucceeded
+		job.Progress = 100
+		job.FileSize = size
+		job.Checksum = checksum
+		entry.resultPath = path
+	})
+	entry.req = nil
+
+	if entry.job.Callback != nil {
+		go s.notify(entry)
+	}
+}
+
+// notify 投递任务完成回调，并记录每次投递结果
+func (s *jobService) notify(entry *jobEntry) {
+	s.mu.RLock()
+	job := entry.job
+	url := job.Callback.URL
+	s.mu.RUnlock()
+
+	payload := model.JobCallbackPayload{
+		JobID:      job.ID,
+		Status:     job.Status,
+		FileType:   job.FileType,
+		TemplateID: job.TemplateID,
+		FileSize:   job.FileSize,
+		Checksum:   job.Checksum,
+		Error:      job.Error,
+		FinishedAt: *job.FinishedAt,
+	}
+	if job.Status == model.JobSucceeded {
+		payload.DownloadURL = entry.downloadURL
+	}
+
+	s.notifier.deliver(url, entry.callbackSecret, payload, func(delivery model.CallbackDelivery, status model.CallbackStatus) {
+		s.update(entry, func(job *model.Job) {
+			job.Callback.Deliveries = append(job.Callback.Deliveries, delivery)
+			job.Callback.Status = status
+		})
+	})
+}
+
+// export 生成导出文件并写入结果目录，返回文件路径、大小和SHA-256，导出过程中的panic记为任务失败
+func (s *jobService) export(entry *jobEntry) (path string, size int64, checksum string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("export panicked: %v", r)
+		}
+	}()
+
+	fileType := entry.job.FileType
+	var result io.WriterTo
+	if fileType == "excel" && entry.req.Stream {
+		// 流式导出的Excel直接写入结果文件，不在内存中缓存
+		file, err := s.exportService.ExportExcelStream(entry.req)
+		if err != nil {
+			return "", 0, "", fmt.Errorf("failed to export file: %v", err)
+		}
+		defer file.Close()
+		result = file
+	} else {
+		data, err := s.exportService.Export(fileType, entry.req)
+		if err != nil {
+			return "", 0, "", fmt.Errorf("failed to export file: %v", err)
+		}
+		result = bytes.NewReader(data)
+	}
+	s.update(entry, func(job *model.Job) {
+		job.Progress = 90
+	})
+
+	if err := os.MkdirAll(s.resultDir, 0755); err != nil {
+		return "", 0, "", fmt.Errorf("failed to create result directory: %v", err)
+	}
+	format, _ := export.LookupFormat(fileType)
+	path = filepath.Join(s.resultDir, entry.job.ID+format.Extension)
+	size, checksum, err = writeResult(path, result)
+	if err != nil {
+		return "", 0, "", err
+	}
+	return path, size, checksum, nil
+}
+
+// writeResult 将导出结果写入文件，返回文件大小和SHA-256
+func writeResult(path string, result io.WriterTo) (int64, string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create result file: %v", err)
+	}
+	hash := sha256.New()
+	size, err := result.WriteTo(io.MultiWriter(file, hash))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, "", fmt.Errorf("failed to write result file: %v", err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// snapshot 复制任务状态，调用方需持有锁
+func (e *jobEntry) snapshot() *model.Job {
+	job := e.job
+	if e.job.Callback != nil {
+		callback := *e.job.Callback
+		callback.Deliveries = append([]model.CallbackDelivery(nil), e.job.Callback.Deliveries...)
+		job.Callback = &callback
+	}
+	return &job
+}
+
+// callbackPending 判断任务回调是否尚未投递结束，调用方需持有锁
+func (e *jobEntry) callbackPending() bool {
+	return e.job.Callback != nil && e.job.Callback.Status == model.CallbackPending
+}
+
+// update 在锁内修改任务状态
+func (s *jobService) update(entry *jobEntry, fn func(job *model.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&entry.job)
+}
+
+// cleanupLoop 定期删除过期的任务和结果文件
+func (s *jobService) cleanupLoop() {
+	interval := s.resultTTL / 2
+	if interval > 10*time.Minute {
+		interval = 10 * time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.cleanup(time.Now())
+	}
+}
+
+// cleanup 删除已过期的任务；结果目录中超过TTL的结果文件（如服务重启前遗留的结果）也一并删除
+// 回调仍在投递（含等待重试）的任务保留到投递结束，避免接收方拿到的下载地址失效
+func (s *jobService) cleanup(now time.Time) {
+	s.mu.Lock()
+	kept := make(map[string]bool)
+	for id, entry := range s.jobs {
+		if entry.job.ExpiresAt == nil || now.Before(*entry.job.ExpiresAt) || entry.callbackPending() {
+			if entry.resultPath != "" {
+				kept[filepath.Base(entry.resultPath)] = true
+			}
+			continue
+		}
+		if entry.resultPath != "" {
+			if err := os.Remove(entry.resultPath); err != nil && !os.IsNotExist(err) {
+				log.Printf("删除导出结果 %s 失败：%v", entry.resultPath, err)
+			}
+		}
+		delete(s.jobs, id)
+	}
+	s.mu.Unlock()
+
+	files, err := ioutil.ReadDir(s.resultDir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || !isResultFile(file.Name()) || kept[file.Name()] || now.Sub(file.ModTime()) < s.resultTTL {
+			continue
+		}
+		path := filepath.Join(s.resultDir, file.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除导出结果 %s 失败：%v", path, err)
+		}
+	}
+}
+
+// newJobID 生成随机任务ID
+func newJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isResultFile 判断文件名是否为任务结果文件（任务ID + 导出格式扩展名），避免误删结果目录中的其他文件
+func isResultFile(name string) bool {
+	ext := filepath.Ext(name)
+	id := strings.TrimSuffix(name, ext)
+	if len(id) != 32 {
+		return false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return false
+	}
+	for _, fileType := range []string{"excel", "word", "pdf"} {
+		if format, _ := export.LookupFormat(fileType); format.Extension == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+)
+
+// fakeExport 导出服务，导出前等待 release 关闭（为 nil 时不等待），templateID 为 fail 时导出失败
+type fakeExport struct {
+	export.ExportService
+	release chan struct{}
+}
+
+func (f *fakeExport) Export(fileType string, req *model.ExportRequest) ([]byte, error) {
+	if f.release != nil {
+		<-f.release
+	}
+	if req.TemplateID == "fail" {
+		return nil, errors.New("template is broken")
+	}
+	return []byte(fileType + ":" + req.TemplateID), nil
+}
+
+// newTestService 创建使用 exportService 的任务服务，结果写入临时目录
+func newTestService(t *testing.T, exportService export.ExportService, queueSize int) *jobService {
+	t.Helper()
+	setJobConfig(t, func(cfg *config.Config) {
+		cfg.Job.Workers = 1
+		cfg.Job.QueueSize = queueSize
+		cfg.Job.ResultDir = t.TempDir()
+		cfg.Job.ResultTTL = time.Hour
+	})
+	return NewJobService(exportService).(*jobService)
+}
+
+// waitStatus 等待任务进入 status 状态
+func waitStatus(t *testing.T, s JobService, id string, status model.JobStatus) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	s := newTestService(t, &fakeExport{release: release}, 10)
+
+	submitted, err := s.Submit("excel", &model.JobRequest{ExportRequest: model.ExportRequest{TemplateID: "quote"}}, "https://export.example.com/api/v1/jobs/")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if submitted.Status != model.JobQueued || submitted.FileType != "excel" || submitted.TemplateID != "quote" {
+		t.Errorf("submitted job = %+v, want queued excel/quote", submitted)
+	}
+	s.mu.RLock()
+	downloadURL := s.jobs[submitted.ID].downloadURL
+	s.mu.RUnlock()
+	if downloadURL != "https://export.example.com/api/v1/jobs/"+submitted.ID+"/result" {
+		t.Errorf("downloadURL = %q", downloadURL)
+	}
+
+	running := waitStatus(t, s, submitted.ID, model.JobRunning)
+	if running.Progress != 10 || running.StartedAt == nil {
+		t.Errorf("running job = %+v, want progress 10 and started_at", running)
+	}
+	if _, err := s.ResultPath(submitted.ID); err != ErrJobNotReady {
+		t.Errorf("ResultPath while running = %v, want ErrJobNotReady", err)
+	}
+
+	close(release)
+	done := waitStatus(t, s, submitted.ID, model.JobSucceeded)
+	sum := sha256.Sum256([]byte("excel:quote"))
+	if done.Progress != 100 || done.FileSize != int64(len("excel:quote")) || done.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("finished job = %+v", done)
+	}
+	if done.FinishedAt == nil || done.ExpiresAt == nil || !done.ExpiresAt.Equal(done.FinishedAt.Add(time.Hour)) {
+		t.Errorf("finished_at = %v, expires_at = %v, want expiry after result_ttl", done.FinishedAt, done.ExpiresAt)
+	}
+	path, err := s.ResultPath(submitted.ID)
+	if err != nil {
+		t.Fatalf("ResultPath: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "excel:quote" || filepath.Ext(path) != ".xlsx" {
+		t.Errorf("result %s = %q, %v", path, data, err)
+	}
+
+	failed, err := s.Submit("word", &model.JobRequest{ExportRequest: model.ExportRequest{TemplateID: "fail"}}, "")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	job := waitStatus(t, s, failed.ID, model.JobFailed)
+	if !strings.Contains(job.Error, "template is broken") || job.ExpiresAt == nil {
+		t.Errorf("failed job = %+v, want the export error and an expiry", job)
+	}
+	if _, err := s.ResultPath(failed.ID); err != ErrJobNotReady {
+		t.Errorf("ResultPath of failed job = %v, want ErrJobNotReady", err)
+	}
+	if _, err := s.Get("missing"); err != ErrJobNotFound {
+		t.Errorf("Get(missing) = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	// 任务一直执行中，测试结束后不再写入结果目录
+	release := make(chan struct{})
+	s := newTestService(t, &fakeExport{release: release}, 1)
+	req := &model.JobRequest{ExportRequest: model.ExportRequest{TemplateID: "quote"}}
+
+	// 第一个任务由工作协程执行，第二个任务占满队列
+	first, err := s.Submit("excel", req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, s, first.ID, model.JobRunning)
+	if _, err := s.Submit("excel", req, ""); err != nil {
+		t.Fatalf("Submit with a free slot: %v", err)
+	}
+	if _, err := s.Submit("excel", req, ""); err != ErrQueueFull {
+		t.Errorf("Submit to a full queue = %v, want ErrQueueFull", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.jobs) != 2 {
+		t.Errorf("jobs = %d, want the rejected job not stored", len(s.jobs))
+	}
+}
+
+func TestCleanupRemovesExpiredJobs(t *testing.T) {
+	dir := t.TempDir()
+	s := &jobService{
+		resultDir: dir,
+		resultTTL: time.Hour,
+		jobs:      make(map[string]*jobEntry),
+	}
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	// writeFile 写入结果目录中的文件，修改时间为 modTime
+	writeFile := func(name string, modTime time.Time) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	expired := finishedEntry("", "", now.Add(-time.Minute))
+	expired.job.Callback = nil
+	expired.resultPath = writeFile(expired.job.ID+".xlsx", old)
+	s.jobs[expired.job.ID] = expired
+
+	active := finishedEntry("", "", now.Add(time.Minute))
+	active.job.ID = "fedcba9876543210fedcba9876543210"
+	active.job.Callback = nil
+	active.resultPath = writeFile(active.job.ID+".xlsx", old)
+	s.jobs[active.job.ID] = active
+
+	orphan := writeFile("00000000000000000000000000000000.pdf", old)
+	recent := writeFile("11111111111111111111111111111111.pdf", now)
+	other := writeFile("notes.txt", old)
+
+	s.cleanup(now)
+	if _, err := s.Get(expired.job.ID); err != ErrJobNotFound {
+		t.Errorf("expired job kept: %v", err)
+	}
+	if _, err := s.Get(active.job.ID); err != nil {
+		t.Errorf("unexpired job removed: %v", err)
+	}
+	for path, kept := range map[string]bool{expired.resultPath: false, active.resultPath: true, orphan: false, recent: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("%s: kept = %v, want %v", filepath.Base(path), err == nil, kept)
+		}
+	}
+}
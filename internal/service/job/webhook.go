@@ -0,0 +1,130 @@
+package job
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/netutil"
+)
+
+// 回调请求头
+const (
+	webhookSignatureHeader = "X-Export-Signature" // sha256=<HMAC-SHA256(secret, timestamp + "." + body)>
+	webhookTimestampHeader = "X-Export-Timestamp" // 签名时的Unix时间戳（秒）
+	webhookJobHeader       = "X-Export-Job-ID"
+)
+
+// webhookPolicy 允许回调的地址范围
+func webhookPolicy() netutil.Policy {
+	cfg := config.Get().Job
+	return netutil.Policy{AllowPrivate: cfg.WebhookAllowPrivate, AllowedHosts: cfg.WebhookAllowedHosts}
+}
+
+// webhookNotifier 任务完成回调投递器，失败时按指数退避重试
+type webhookNotifier struct {
+	client      *http.Client
+	policy      func() netutil.Policy
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// newWebhookNotifier 创建回调投递器，只投递到 policy 允许的地址
+func newWebhookNotifier(maxAttempts int, backoff, timeout time.Duration, policy func() netutil.Policy) *webhookNotifier {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &webhookNotifier{
+		client:      netutil.NewClient(timeout, policy),
+		policy:      policy,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// deliver 投递回调直到接收方返回2xx或重试次数用尽，每次投递结果通过 record 记录
+func (n *webhookNotifier) deliver(url, secret string, payload model.JobCallbackPayload, record func(model.CallbackDelivery, model.CallbackStatus)) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("任务 %s 回调数据序列化失败：%v", payload.JobID, err)
+		record(model.CallbackDelivery{Attempt: 1, At: time.Now(), Error: err.Error()}, model.CallbackFailed)
+		return
+	}
+
+	wait := n.backoff
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		delivery := model.CallbackDelivery{Attempt: attempt, At: time.Now()}
+		statusCode, err := n.post(url, secret, payload.JobID, body)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			record(delivery, model.CallbackDelivered)
+			return
+		}
+
+		delivery.Error = err.Error()
+		log.Printf("任务 %s 第%d次回调失败：%v", payload.JobID, attempt, err)
+		if attempt == n.maxAttempts {
+			record(delivery, model.CallbackFailed)
+			return
+		}
+		record(delivery, model.CallbackPending)
+
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post 发送一次回调请求，非2xx响应视为失败
+func (n *webhookNotifier) post(url, secret, jobID string, body []byte) (int, error) {
+	if _, err := n.policy().CheckURL(url); err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create callback request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookJobHeader, jobID)
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook 计算回调签名：HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,324 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/netutil"
+)
+
+// allowPrivate 允许访问 httptest 监听的回环地址
+func allowPrivate() netutil.Policy {
+	return netutil.Policy{AllowPrivate: true}
+}
+
+// setJobConfig 修改任务配置，测试结束后恢复
+func setJobConfig(t *testing.T, fn func(cfg *config.Config)) {
+	t.Helper()
+	old := config.Get()
+	cfg := *old
+	fn(&cfg)
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+}
+
+// callbackRecorder 记录收到的回调请求，前 failures 次返回500
+type callbackRecorder struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func (r *callbackRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.times = append(r.times, time.Now())
+	if len(r.requests) <= r.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishedEntry 构造已成功完成、带回调的任务
+func finishedEntry(url, secret string, expiresAt time.Time) *jobEntry {
+	finishedAt := expiresAt.Add(-time.Hour)
+	return &jobEntry{
+		job: model.Job{
+			ID:         "0123456789abcdef0123456789abcdef",
+			Status:     model.JobSucceeded,
+			FileType:   "excel",
+			TemplateID: "quote",
+			FileSize:   3,
+			Checksum:   "abc",
+			FinishedAt: &finishedAt,
+			ExpiresAt:  &expiresAt,
+			Callback:   &model.JobCallback{URL: url, Status: model.CallbackPending},
+		},
+		callbackSecret: secret,
+		downloadURL:    "https://export.example.com/api/v1/jobs/0123456789abcdef0123456789abcdef/result",
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	s := &jobService{
+		jobs:     make(map[string]*jobEntry),
+		notifier: newWebhookNotifier(3, time.Millisecond, time.Second, allowPrivate),
+	}
+	entry := finishedEntry(server.URL, "s3cret", time.Now().Add(time.Hour))
+	s.notify(entry)
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("got %d callback requests, want 1", len(recorder.requests))
+	}
+	req, body := recorder.requests[0], recorder.bodies[0]
+	if got := req.Header.Get(webhookJobHeader); got != entry.job.ID {
+		t.Errorf("%s = %q, want %q", webhookJobHeader, got, entry.job.ID)
+	}
+	timestamp := req.Header.Get(webhookTimestampHeader)
+	if timestamp == "" {
+		t.Fatalf("missing %s header", webhookTimestampHeader)
+	}
+	want := "sha256=" + signWebhook("s3cret", timestamp, body)
+	if got := req.Header.Get(webhookSignatureHeader); got != want {
+		t.Errorf("%s = %q, want %q", webhookSignatureHeader, got, want)
+	}
+	if got := req.Header.Get(webhookSignatureHeader); got == "sha256="+signWebhook("other", timestamp, body) {
+		t.Errorf("signature does not depend on the secret")
+	}
+
+	var payload model.JobCallbackPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.JobID != entry.job.ID || payload.Status != model.JobSucceeded || payload.DownloadURL != entry.downloadURL {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	job := entry.snapshot()
+	if job.Callback.Status != model.CallbackDelivered {
+		t.Errorf("callback status = %s, want %s", job.Callback.Status, model.CallbackDelivered)
+	}
+}
+
+func TestWebhookWithoutSecret(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	s := &jobService{
+		jobs:     make(map[string]*jobEntry),
+		notifier: newWebhookNotifier(1, time.Millisecond, time.Second, allowPrivate),
+	}
+	s.notify(finishedEntry(server.URL, "", time.Now().Add(time.Hour)))
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("got %d callback requests, want 1", len(recorder.requests))
+	}
+	if got := recorder.requests[0].Header.Get(webhookSignatureHeader); got != "" {
+		t.Errorf("unexpected signature without secret: %q", got)
+	}
+}
+
+func TestWebhookRetryOn5xx(t *testing.T) {
+	recorder := &callbackRecorder{failures: 2}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	backoff := 20 * time.Millisecond
+	s := &jobService{
+		jobs:     make(map[string]*jobEntry),
+		notifier: newWebhookNotifier(5, backoff, time.Second, allowPrivate),
+	}
+	entry := finishedEntry(server.URL, "s3cret", time.Now().Add(time.Hour))
+	s.notify(entry)
+
+	if len(recorder.requests) != 3 {
+		t.Fatalf("got %d callback requests, want 3", len(recorder.requests))
+	}
+	// 重试间隔按指数退避：backoff、2×backoff
+	for i, min := range []time.Duration{backoff, 2 * backoff} {
+		if gap := recorder.times[i+1].Sub(recorder.times[i]); gap < min {
+			t.Errorf("retry %d after %v, want at least %v", i+1, gap, min)
+		}
+	}
+
+	deliveries := entry.snapshot().Callback.Deliveries
+	if len(deliveries) != 3 {
+		t.Fatalf("got %d delivery log entries, want 3", len(deliveries))
+	}
+	for i, delivery := range deliveries {
+		if delivery.Attempt != i+1 {
+			t.Errorf("delivery %d attempt = %d", i, delivery.Attempt)
+		}
+		if delivery.At.IsZero() {
+			t.Errorf("delivery %d has no time", i)
+		}
+	}
+	for _, delivery := range deliveries[:2] {
+		if delivery.StatusCode != http.StatusInternalServerError || !strings.Contains(delivery.Error, "500") {
+			t.Errorf("failed delivery logged as %+v", delivery)
+		}
+	}
+	if last := deliveries[2]; last.StatusCode != http.StatusNoContent || last.Error != "" {
+		t.Errorf("successful delivery logged as %+v", last)
+	}
+	if status := entry.snapshot().Callback.Status; status != model.CallbackDelivered {
+		t.Errorf("callback status = %s, want %s", status, model.CallbackDelivered)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	recorder := &callbackRecorder{failures: 10}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	s := &jobService{
+		jobs:     make(map[string]*jobEntry),
+		notifier: newWebhookNotifier(3, time.Millisecond, time.Second, allowPrivate),
+	}
+	entry := finishedEntry(server.URL, "", time.Now().Add(time.Hour))
+	s.notify(entry)
+
+	if len(recorder.requests) != 3 {
+		t.Fatalf("got %d callback requests, want 3", len(recorder.requests))
+	}
+	callback := entry.snapshot().Callback
+	if callback.Status != model.CallbackFailed || len(callback.Deliveries) != 3 {
+		t.Errorf("callback = %+v, want failed after 3 deliveries", callback)
+	}
+}
+
+func TestWebhookRejectsPrivateAddress(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	s := &jobService{
+		jobs:     make(map[string]*jobEntry),
+		notifier: newWebhookNotifier(1, time.Millisecond, time.Second, func() netutil.Policy { return netutil.Policy{} }),
+	}
+	entry := finishedEntry(server.URL, "", time.Now().Add(time.Hour))
+	s.notify(entry)
+
+	if len(recorder.requests) != 0 {
+		t.Fatalf("callback was delivered to a loopback address")
+	}
+	callback := entry.snapshot().Callback
+	if callback.Status != model.CallbackFailed || len(callback.Deliveries) != 1 || !strings.Contains(callback.Deliveries[0].Error, "private address") {
+		t.Errorf("callback = %+v, want failed with private address error", callback)
+	}
+}
+
+func TestSubmitValidatesCallbackURL(t *testing.T) {
+	setJobConfig(t, func(cfg *config.Config) {
+		cfg.Job.WebhookAllowPrivate = false
+		cfg.Job.WebhookAllowedHosts = nil
+	})
+
+	s := &jobService{
+		jobs:  make(map[string]*jobEntry),
+		queue: make(chan *jobEntry, 10),
+	}
+	for _, callbackURL := range []string{
+		"ftp://example.com/hook",
+		"file:///etc/passwd",
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+	} {
+		req := &model.JobRequest{CallbackURL: callbackURL}
+		if _, err := s.Submit("excel", req, "http://localhost/api/v1/jobs"); !errors.Is(err, ErrInvalidCallbackURL) {
+			t.Errorf("Submit(%q) error = %v, want ErrInvalidCallbackURL", callbackURL, err)
+		}
+	}
+	if len(s.jobs) != 0 {
+		t.Errorf("rejected jobs were stored: %d", len(s.jobs))
+	}
+
+	// 未配置 public_url 时无法生成可信的下载地址
+	if _, err := s.Submit("excel", &model.JobRequest{CallbackURL: "https://hooks.example.com/export"}, ""); !errors.Is(err, ErrInvalidCallbackURL) {
+		t.Errorf("callback without public_url: error = %v, want ErrInvalidCallbackURL", err)
+	}
+	if _, err := s.Submit("excel", &model.JobRequest{CallbackURL: "https://hooks.example.com/export"}, "http://localhost/api/v1/jobs"); err != nil {
+		t.Errorf("public callback rejected: %v", err)
+	}
+
+	setJobConfig(t, func(cfg *config.Config) {
+		cfg.Job.WebhookAllowedHosts = []string{"*.example.com"}
+	})
+	if _, err := s.Submit("excel", &model.JobRequest{CallbackURL: "https://hooks.example.org/export"}, "http://localhost/api/v1/jobs"); !errors.Is(err, ErrInvalidCallbackURL) {
+		t.Errorf("host outside webhook_allowed_hosts accepted: %v", err)
+	}
+
+	setJobConfig(t, func(cfg *config.Config) {
+		cfg.Job.WebhookAllowedHosts = nil
+		cfg.Job.WebhookAllowPrivate = true
+	})
+	if _, err := s.Submit("excel", &model.JobRequest{CallbackURL: "http://127.0.0.1:8080/hook"}, "http://localhost/api/v1/jobs"); err != nil {
+		t.Errorf("private callback rejected with webhook_allow_private: %v", err)
+	}
+}
+
+func TestCleanupKeepsJobWhileCallbackPending(t *testing.T) {
+	dir := t.TempDir()
+	s := &jobService{
+		resultDir: dir,
+		resultTTL: time.Hour,
+		jobs:      make(map[string]*jobEntry),
+	}
+
+	now := time.Now()
+	entry := finishedEntry("https://hooks.example.com/export", "", now.Add(-time.Minute))
+	entry.resultPath = filepath.Join(dir, entry.job.ID+".xlsx")
+	if err := os.WriteFile(entry.resultPath, []byte("xlsx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 结果文件的修改时间同样超过TTL
+	old := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(entry.resultPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	s.jobs[entry.job.ID] = entry
+
+	s.cleanup(now)
+	if _, err := s.Get(entry.job.ID); err != nil {
+		t.Fatalf("job removed while callback is pending: %v", err)
+	}
+	if _, err := os.Stat(entry.resultPath); err != nil {
+		t.Fatalf("result removed while callback is pending: %v", err)
+	}
+
+	s.update(entry, func(job *model.Job) {
+		job.Callback.Status = model.CallbackFailed
+	})
+	s.cleanup(now)
+	if _, err := s.Get(entry.job.ID); err != ErrJobNotFound {
+		t.Fatalf("expired job kept after delivery finished: %v", err)
+	}
+	if _, err := os.Stat(entry.resultPath); !os.IsNotExist(err) {
+		t.Fatalf("expired result kept after delivery finished: %v", err)
+	}
+}
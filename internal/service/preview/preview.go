@@ -0,0 +1,240 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/template"
+)
+
+var (
+	// ErrUnsupportedFormat 不支持的预览格式
+	ErrUnsupportedFormat = errors.New("unsupported preview format")
+	// ErrConverterUnavailable 未安装预览所需的转换程序
+	ErrConverterUnavailable = errors.New("preview converter is not available")
+)
+
+// formatContentTypes 预览格式对应的Content-Type
+var formatContentTypes = map[string]string{
+	"png": "image/png",
+	"pdf": "application/pdf",
+}
+
+// Preview 渲染好的模板预览
+type Preview struct {
+	Data        []byte
+	ContentType string
+	Checksum    string // 模板文件、示例数据和引用资源的摘要，模板变化时随之变化，可用作ETag
+}
+
+// PreviewService 模板预览服务接口
+type PreviewService interface {
+	Render(fileType string, format string, req *model.ExportRequest) (*Preview, error)
+}
+
+// cacheEntry 缓存的预览结果
+type cacheEntry struct {
+	checksum string
+	data     []byte
+}
+
+// previewService 预览服务实现：先按正常流程导出文件，Excel和Word再经LibreOffice转换为PDF，
+// PNG由pdftoppm渲染PDF首页；结果按模板缓存在内存中，模板文件或示例数据变化后重新渲染
+type previewService struct {
+	exportService   export.ExportService
+	templateService template.TemplateService
+
+	// renderMu 串行执行渲染，避免同时启动多个LibreOffice进程，并发请求同一模板时也只渲染一次
+	renderMu sync.Mutex
+	mu       sync.Mutex
+	cache    map[string]*cacheEntry
+}
+
+// NewPreviewService 创建预览服务实例
+func NewPreviewService(exportService export.ExportService, templateService template.TemplateService) PreviewService {
+	return &previewService{
+		exportService:   exportService,
+		templateService: templateService,
+		cache:           make(map[string]*cacheEntry),
+	}
+}
+
+// Render 渲染模板预览，req 为已填入示例数据的导出请求；format 为 png（首页图片）或 pdf
+func (s *previewService) Render(fileType string, format string, req *model.ExportRequest) (*Preview, error) {
+	contentType, ok := formatContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	checksum, err := s.checksum(fileType, req)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.Join([]string{fileType, req.TemplateID, format}, "/")
+	if data := s.cached(key, checksum); data != nil {
+		return &Preview{Data: data, ContentType: contentType, Checksum: checksum}, nil
+	}
+
+	s.renderMu.Lock()
+	defer s.renderMu.Unlock()
+
+	// 等待期间其他请求可能已完成同一模板的渲染
+	if data := s.cached(key, checksum); data != nil {
+		return &Preview{Data: data, ContentType: contentType, Checksum: checksum}, nil
+	}
+
+	data, err := s.render(fileType, format, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[key] = &cacheEntry{checksum: checksum, data: data}
+	s.mu.Unlock()
+
+	return &Preview{Data: data, ContentType: contentType, Checksum: checksum}, nil
+}
+
+// cached 读取缓存的预览，模板已变化时返回 nil
+func (s *previewService) cached(key, checksum string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.cache[key]; ok && entry.checksum == checksum {
+		return entry.data
+	}
+	return nil
+}
+
+// checksum 计算模板文件、命名样式、请求数据以及导出引用的资源文件（字体、图片）的摘要
+// 资源文件按大小和修改时间计入，替换字体或图片后预览随之更新
+func (s *previewService) checksum(fileType string, req *model.ExportRequest) (string, error) {
+	templateData, err := s.templateService.LoadTemplate(req.TemplateID, fileType)
+	if err != nil {
+		return "", err
+	}
+	styles, err := s.templateService.LoadStyles(req.TemplateID, fileType)
+	if err != nil {
+		return "", err
+	}
+	sharedStyles, err := s.templateService.LoadSharedStyles()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(req.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode preview data: %v", err)
+	}
+	assets, err := s.exportService.Assets(fileType, req)
+	if err != nil {
+		return "", err
+	}
+	var stamps []string
+	for _, asset := range assets {
+		stamp, err := s.templateService.AssetStamp(asset)
+		if err != nil {
+			return "", err
+		}
+		stamps = append(stamps, asset+"="+stamp)
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{templateData, styles, sharedStyles, data, []byte(strings.Join(stamps, "\n"))} {
+		h.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// render 导出文件并转换为预览格式
+func (s *previewService) render(fileType string, format string, req *model.ExportRequest) ([]byte, error) {
+	fileBytes, err := s.exportService.Export(fileType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := ioutil.TempDir("", "office-export-preview-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preview directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// 每次渲染读取当前配置，重新加载配置后立即生效
+	cfg := config.Get().Preview
+
+	// PDF模板直接导出为PDF，Excel和Word先转换为PDF
+	pdfPath := filepath.Join(workDir, "preview.pdf")
+	if fileType == "pdf" {
+		if err := ioutil.WriteFile(pdfPath, fileBytes, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write preview file: %v", err)
+		}
+	} else {
+		fileFormat, _ := export.LookupFormat(fileType)
+		inputPath := filepath.Join(workDir, "preview"+fileFormat.Extension)
+		if err := ioutil.WriteFile(inputPath, fileBytes, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write preview file: %v", err)
+		}
+		// 指定独立的用户配置目录，避免与本机正在运行的LibreOffice冲突
+		profile := "-env:UserInstallation=file://" + filepath.ToSlash(filepath.Join(workDir, "profile"))
+		if err := run(cfg.Converter, cfg.Timeout, profile, "--headless", "--convert-to", "pdf", "--outdir", workDir, inputPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if format == "pdf" {
+		return readOutput(pdfPath)
+	}
+
+	// 只渲染第一页，pdftoppm 按输出前缀追加 .png
+	pngPrefix := filepath.Join(workDir, "page")
+	if err := run(cfg.Rasterizer, cfg.Timeout, "-png", "-f", "1", "-l", "1", "-r", strconv.Itoa(cfg.DPI), "-singlefile", pdfPath, pngPrefix); err != nil {
+		return nil, err
+	}
+	return readOutput(pngPrefix + ".png")
+}
+
+// run 执行转换程序，程序不存在时返回 ErrConverterUnavailable
+func run(name string, timeout time.Duration, args ...string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrConverterUnavailable, name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %s", filepath.Base(name), timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(name), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// readOutput 读取转换结果，转换程序正常退出但没有生成文件时返回错误
+func readOutput(filePath string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("preview conversion produced no output: %s", filepath.Base(filePath))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview file: %v", err)
+	}
+	return data, nil
+}
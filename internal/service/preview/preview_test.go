@@ -0,0 +1,149 @@
+package preview
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/template"
+)
+
+// fakeExport 导出服务，记录导出次数，导出引用的资源为 assets
+type fakeExport struct {
+	export.ExportService
+	assets  []string
+	exports int
+}
+
+func (f *fakeExport) Export(fileType string, req *model.ExportRequest) ([]byte, error) {
+	f.exports++
+	return []byte("%PDF " + req.TemplateID), nil
+}
+
+func (f *fakeExport) Assets(fileType string, req *model.ExportRequest) ([]string, error) {
+	return f.assets, nil
+}
+
+// useTemplateFiles 将 files 写入临时模板目录并切换为本地模板存储，返回模板目录
+func useTemplateFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		writeFile(t, dir, name, data)
+	}
+	old := config.Get()
+	cfg := *old
+	cfg.Template.Storage = "fs"
+	cfg.Template.Path = dir
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+	return dir
+}
+
+// writeFile 写入模板目录中的文件
+func writeFile(t *testing.T, dir, name, data string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreviewCache(t *testing.T) {
+	dir := useTemplateFiles(t, map[string]string{
+		"pdf/quote.yaml":        "title: 报价单\n",
+		"pdf/quote.styles.yaml": "title: {font: {size: 16}}\n",
+		"styles.yaml":           "body: {font: {size: 10}}\n",
+		"fonts/regular.ttf":     "font",
+		"images/logo.png":       "png",
+		"images/unused.png":     "png",
+	})
+	exporter := &fakeExport{assets: []string{"fonts/regular.ttf", "images/logo.png"}}
+	s := NewPreviewService(exporter, template.NewTemplateService())
+	req := &model.ExportRequest{TemplateID: "quote", Data: map[string]interface{}{"title": "报价单"}}
+
+	first, err := s.Render("pdf", "pdf", req)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if string(first.Data) != "%PDF quote" || first.ContentType != "application/pdf" || first.Checksum == "" {
+		t.Errorf("preview = %q %s %q", first.Data, first.ContentType, first.Checksum)
+	}
+	checksum := first.Checksum
+
+	// render 渲染预览，changed 为 true 时期望摘要变化并重新导出
+	render := func(name string, changed bool) {
+		t.Helper()
+		before := exporter.exports
+		preview, err := s.Render("pdf", "pdf", req)
+		if err != nil {
+			t.Fatalf("%s: Render: %v", name, err)
+		}
+		if got := preview.Checksum != checksum; got != changed {
+			t.Errorf("%s: checksum changed = %v, want %v", name, got, changed)
+		}
+		if got := exporter.exports != before; got != changed {
+			t.Errorf("%s: exported again = %v, want %v", name, got, changed)
+		}
+		checksum = preview.Checksum
+	}
+
+	render("unchanged", false)
+	writeFile(t, dir, "images/unused.png", "png image not referenced")
+	render("unreferenced image", false)
+
+	writeFile(t, dir, "pdf/quote.yaml", "title: 新报价单\n")
+	render("template", true)
+	writeFile(t, dir, "pdf/quote.styles.yaml", "title: {font: {size: 18}}\n")
+	render("template styles", true)
+	writeFile(t, dir, "styles.yaml", "body: {font: {size: 11}}\n")
+	render("shared styles", true)
+	req.Data["title"] = "新报价单"
+	render("data", true)
+	writeFile(t, dir, "fonts/regular.ttf", "another font")
+	render("font", true)
+	writeFile(t, dir, "images/logo.png", "another logo")
+	render("image", true)
+	if err := os.Remove(filepath.Join(dir, "images", "logo.png")); err != nil {
+		t.Fatal(err)
+	}
+	render("removed image", true)
+	render("unchanged again", false)
+}
+
+func TestPreviewErrors(t *testing.T) {
+	useTemplateFiles(t, map[string]string{"excel/quote.xlsx": "xlsx", "pdf/quote.yaml": "title: 报价单\n"})
+	old := config.Get()
+	cfg := *old
+	cfg.Preview.Converter = "office-export-missing-converter"
+	cfg.Preview.Rasterizer = "office-export-missing-rasterizer"
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(old) })
+
+	exporter := &fakeExport{}
+	s := NewPreviewService(exporter, template.NewTemplateService())
+	req := &model.ExportRequest{TemplateID: "quote", Data: map[string]interface{}{}}
+
+	if _, err := s.Render("excel", "gif", req); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Render(gif) = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := s.Render("excel", "pdf", req); !errors.Is(err, ErrConverterUnavailable) {
+		t.Errorf("Render without converter = %v, want ErrConverterUnavailable", err)
+	}
+	if _, err := s.Render("pdf", "png", req); !errors.Is(err, ErrConverterUnavailable) {
+		t.Errorf("Render(png) without rasterizer = %v, want ErrConverterUnavailable", err)
+	}
+	if _, err := s.Render("excel", "pdf", &model.ExportRequest{TemplateID: "missing"}); err == nil {
+		t.Error("Render of a missing template succeeded")
+	}
+	if exporter.exports != 2 {
+		t.Errorf("exports = %d, want only the requests with an existing template exported", exporter.exports)
+	}
+}
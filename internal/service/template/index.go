@@ -0,0 +1,136 @@
+package template
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+)
+
+// templateIndex 模板索引：模板存储及其中全部模板的信息，重新加载时整体替换
+type templateIndex struct {
+	storage Storage
+	entries []indexEntry
+	err     error // 建立索引失败的原因，此时获取模板列表返回该错误
+}
+
+// indexEntry 索引中的一个模板
+type indexEntry struct {
+	info  model.TemplateInfo
+	stamp string // 模板文件及附属文件的大小和修改时间，用于判断模板是否变化
+}
+
+// key 模板在索引中的唯一标识，如 excel/quote
+func (e indexEntry) key() string {
+	return e.info.Type + "/" + e.info.ID
+}
+
+// storage 当前的模板存储
+func (s *templateService) storage() Storage {
+	return s.index.Load().storage
+}
+
+// Reload 按当前配置的模板存储重新建立模板索引，返回发生变化的模板；失败时保留原有索引
+func (s *templateService) Reload() ([]string, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	storage, err := NewStorage(config.Get())
+	if err != nil {
+		return nil, err
+	}
+	return s.rebuild(storage)
+}
+
+// refresh 模板创建、发布或删除后更新模板索引，调用方须持有 writeMu
+func (s *templateService) refresh() {
+	if _, err := s.rebuild(s.storage()); err != nil {
+		log.Printf("Failed to refresh template index: %v", err)
+	}
+}
+
+// rebuild 扫描模板存储建立新索引并替换原有索引，返回两次索引之间的差异
+func (s *templateService) rebuild(storage Storage) ([]string, error) {
+	scanner := &templateService{index: new(atomic.Pointer[templateIndex])}
+	scanner.index.Store(&templateIndex{storage: storage})
+	entries, err := scanner.scanTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	next := &templateIndex{storage: storage, entries: entries}
+	changes := diffIndex(s.index.Load(), next)
+	s.index.Store(next)
+	return changes, nil
+}
+
+// diffIndex 比较两次索引，列出新增、删除和修改的模板
+func diffIndex(old, next *templateIndex) []string {
+	if old == nil {
+		return nil
+	}
+
+	var changes []string
+	if from, to := old.location(), next.location(); from != to {
+		changes = append(changes, fmt.Sprintf("template storage: %s -> %s", from, to))
+	}
+	stamps := make(map[string]string, len(old.entries))
+	for _, entry := range old.entries {
+		stamps[entry.key()] = entry.stamp
+	}
+	for _, entry := range next.entries {
+		stamp, ok := stamps[entry.key()]
+		switch {
+		case !ok:
+			changes = append(changes, "template added: "+entry.key())
+		case stamp != entry.stamp:
+			changes = append(changes, "template updated: "+entry.key())
+		}
+		delete(stamps, entry.key())
+	}
+	var removed []string
+	for key := range stamps {
+		removed = append(removed, "template removed: "+key)
+	}
+	sort.Strings(removed)
+	return append(changes, removed...)
+}
+
+// TemplateStamp 模板文件及附属文件的状态，模板变化（包括重新加载后的文件变化）时随之改变，用于缓存由模板派生的数据；
+// 固定版本的模板为版本目录，模板不在索引中时返回空字符串
+func (s *templateService) TemplateStamp(templateID string, fileType string) string {
+	index := s.index.Load()
+	if p := s.pin; p != nil && p.templateID == templateID && p.fileType == fileType {
+		return index.location() + "|" + p.dir
+	}
+	for _, entry := range index.entries {
+		if entry.info.ID == templateID && entry.info.Type == fileType {
+			return index.location() + "|" + entry.stamp
+		}
+	}
+	return ""
+}
+
+// location 模板存储的位置，如模板目录或 s3://bucket/prefix/
+func (index *templateIndex) location() string {
+	if index.storage == nil {
+		return ""
+	}
+	return index.storage.Location("")
+}
+
+// fileStamp 模板文件及附属文件的大小和修改时间，files 为模板所在目录下的文件
+func fileStamp(templateID, fileType string, files map[string]fs.FileInfo) string {
+	var parts []string
+	for _, fileName := range templateFileNames(templateID, fileType) {
+		if info, ok := files[fileName]; ok {
+			parts = append(parts, fmt.Sprintf("%s:%d:%d", fileName, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,98 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"office-export-server/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadMeta 读取模板描述文件（<template_id>.meta.yaml），模板没有描述文件时返回 nil
+func (s *templateService) LoadMeta(templateID string, fileType string) (*model.TemplateMeta, error) {
+	name := path.Join(s.fileDir(templateID, fileType), cleanName(templateID+metaSuffix))
+
+	data, err := s.storage().ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta file: %v", err)
+	}
+
+	meta, err := parseMeta(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %v", fileType, path.Base(name), err)
+	}
+	return meta, nil
+}
+
+// LoadThumbnail 读取模板描述文件中声明的缩略图，返回图片内容和文件名；模板没有缩略图时返回 ErrTemplateNotFound
+func (s *templateService) LoadThumbnail(templateID string, fileType string) ([]byte, string, error) {
+	if err := checkTemplateRef(templateID, fileType); err != nil {
+		return nil, "", err
+	}
+	meta, err := s.LoadMeta(templateID, fileType)
+	if err != nil {
+		return nil, "", err
+	}
+	if meta == nil || meta.Thumbnail == "" {
+		return nil, "", ErrTemplateNotFound
+	}
+
+	// 缩略图路径相对模板所在目录，不允许跳出该目录
+	name := path.Join(s.fileDir(templateID, fileType), cleanName(meta.Thumbnail))
+	data, err := s.storage().ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read thumbnail: %v", err)
+	}
+	return data, path.Base(name), nil
+}
+
+// parseMeta 解析模板描述文件
+func parseMeta(data []byte) (*model.TemplateMeta, error) {
+	var meta model.TemplateMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid meta: %v", err)
+	}
+	return &meta, nil
+}
+
+// applyMeta 用描述文件补充模板信息，通过接口上传时填写的名称和说明优先
+func applyMeta(info *model.TemplateInfo, meta *model.TemplateMeta) {
+	if meta.Name != "" {
+		info.Name = meta.Name
+	}
+	if meta.Description != "" {
+		info.Description = meta.Description
+	}
+	info.Owner = meta.Owner
+	info.Tags = meta.Tags
+	info.RequiredFields = meta.RequiredFields
+	info.Sample = meta.Sample
+	info.Thumbnail = meta.Thumbnail
+}
+
+// matchTags 判断模板是否包含全部筛选标签，标签比较不区分大小写
+func matchTags(tags []string, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if strings.EqualFold(tag, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"office-export-server/internal/config"
+	"office-export-server/templates"
+)
+
+// ErrReadOnlyStorage 模板存储不支持写入（如内置模板）
+var ErrReadOnlyStorage = errors.New("template storage is read-only")
+
+// Storage 模板存储后端。name 为相对模板根目录、以 / 分隔的路径，如 excel/quote.xlsx；
+// 文件不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+type Storage interface {
+	ReadFile(name string) ([]byte, error)
+	// ReadDir 列出目录下的文件和子目录，目录不存在时返回空
+	ReadDir(name string) ([]fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	// WriteFile 写入文件，读取方不会读到写了一半的文件
+	WriteFile(name string, data []byte) error
+	// RemoveAll 删除文件或目录及其下全部文件，不存在时不报错
+	RemoveAll(name string) error
+	// Location 文件的完整位置，用于展示，如本地路径或 s3://bucket/key
+	Location(name string) string
+}
+
+// NewStorage 根据配置创建模板存储：fs（默认）为本地目录 template.path，embed 为程序内置的模板，s3 为S3兼容的对象存储
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.Template.Storage {
+	case "", "fs":
+		return newFSStorage(cfg.Template.Path), nil
+	case "embed":
+		return newEmbedStorage(templates.FS, "embed://"), nil
+	case "s3":
+		return newS3Storage(cfg.Template.S3)
+	default:
+		return nil, fmt.Errorf("unsupported template storage: %s", cfg.Template.Storage)
+	}
+}
+
+// cleanName 规范化存储路径，去掉开头的 /，不允许跳出模板根目录
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// fsStorage 本地目录中的模板
+type fsStorage struct {
+	root string
+}
+
+// newFSStorage 创建本地目录模板存储
+func newFSStorage(root string) *fsStorage {
+	return &fsStorage{root: root}
+}
+
+// path 存储路径对应的本地路径
+func (s *fsStorage) path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(cleanName(name)))
+}
+
+func (s *fsStorage) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(name))
+}
+
+func (s *fsStorage) ReadDir(name string) ([]fs.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return infos, err
+}
+
+func (s *fsStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(s.path(name))
+}
+
+func (s *fsStorage) WriteFile(name string, data []byte) error {
+	filePath := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, data)
+}
+
+func (s *fsStorage) RemoveAll(name string) error {
+	return os.RemoveAll(s.path(name))
+}
+
+func (s *fsStorage) Location(name string) string {
+	return s.path(name)
+}
+
+// writeFileAtomic 先写入同目录的临时文件再改名，读取方不会读到写了一半的文件
+func writeFileAtomic(filePath string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// embedStorage 编译进程序的模板（只读），容器中无需挂载模板目录
+type embedStorage struct {
+	fsys   fs.FS
+	prefix string // Location 的前缀
+}
+
+// newEmbedStorage 创建只读的内置模板存储
+func newEmbedStorage(fsys fs.FS, prefix string) *embedStorage {
+	return &embedStorage{fsys: fsys, prefix: prefix}
+}
+
+// name 存储路径对应的 fs.FS 路径，根目录为 "."
+func (s *embedStorage) name(name string) string {
+	if name = cleanName(name); name == "" {
+		return "."
+	}
+	return name
+}
+
+func (s *embedStorage) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, s.name(name))
+}
+
+func (s *embedStorage) ReadDir(name string) ([]fs.FileInfo, error) {
+	entries, err := fs.ReadDir(s.fsys, s.name(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *embedStorage) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(s.fsys, s.name(name))
+}
+
+func (s *embedStorage) WriteFile(name string, data []byte) error {
+	return ErrReadOnlyStorage
+}
+
+func (s *embedStorage) RemoveAll(name string) error {
+	return ErrReadOnlyStorage
+}
+
+func (s *embedStorage) Location(name string) string {
+	return s.prefix + cleanName(name)
+}
+
+// unavailableStorage 无法创建的模板存储（如S3配置无效），全部操作返回创建时的错误
+type unavailableStorage struct {
+	err error
+}
+
+func (s unavailableStorage) ReadFile(name string) ([]byte, error)       { return nil, s.err }
+func (s unavailableStorage) ReadDir(name string) ([]fs.FileInfo, error) { return nil, s.err }
+func (s unavailableStorage) Stat(name string) (fs.FileInfo, error)      { return nil, s.err }
+func (s unavailableStorage) WriteFile(name string, data []byte) error   { return s.err }
+func (s unavailableStorage) RemoveAll(name string) error                { return s.err }
+func (s unavailableStorage) Location(name string) string                { return cleanName(name) }
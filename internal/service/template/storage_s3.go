@@ -0,0 +1,387 @@
+package template
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"office-export-server/internal/config"
+)
+
+// s3MaxObjectSize 读取单个对象的大小上限，防止异常的大对象耗尽内存
+const s3MaxObjectSize = 100 << 20
+
+// s3Storage S3兼容对象存储（AWS S3、MinIO等）中的模板，对象键为 prefix + 存储路径
+// 只使用 GetObject、HeadObject、PutObject、DeleteObject 和 ListObjectsV2，请求按 AWS Signature V4 签名
+type s3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+// newS3Storage 创建S3模板存储，未配置密钥时使用环境变量 AWS_ACCESS_KEY_ID 和 AWS_SECRET_ACCESS_KEY，均为空时发送匿名请求
+func newS3Storage(cfg config.S3Config) (*s3Storage, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	s := &s3Storage{
+		endpoint:  u,
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		prefix:    cleanName(cfg.Prefix),
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		pathStyle: cfg.PathStyle,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if s.prefix != "" {
+		s.prefix += "/"
+	}
+	if s.accessKey == "" && s.secretKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// key 存储路径对应的对象键
+func (s *s3Storage) key(name string) string {
+	return s.prefix + cleanName(name)
+}
+
+func (s *s3Storage) ReadFile(name string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("GetObject", name, resp)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, s3MaxObjectSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3 object %s: %v", name, err)
+	}
+	if len(data) > s3MaxObjectSize {
+		return nil, fmt.Errorf("s3 object %s exceeds %d bytes", name, s3MaxObjectSize)
+	}
+	return data, nil
+}
+
+func (s *s3Storage) Stat(name string) (fs.FileInfo, error) {
+	resp, err := s.do(http.MethodHead, s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("HeadObject", name, resp)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &s3FileInfo{name: path.Base(name), size: resp.ContentLength, modTime: modTime}, nil
+}
+
+func (s *s3Storage) ReadDir(name string) ([]fs.FileInfo, error) {
+	dir := s.key(name)
+	if dir != "" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	var infos []fs.FileInfo
+	err := s.list(dir, "/", func(result *s3ListResult) {
+		for _, object := range result.Contents {
+			if object.Key == dir {
+				continue
+			}
+			infos = append(infos, &s3FileInfo{name: strings.TrimPrefix(object.Key, dir), size: object.Size, modTime: object.LastModified})
+		}
+		for _, prefix := range result.CommonPrefixes {
+			infos = append(infos, &s3FileInfo{name: strings.TrimSuffix(strings.TrimPrefix(prefix.Prefix, dir), "/"), dir: true})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (s *s3Storage) WriteFile(name string, data []byte) error {
+	resp, err := s.do(http.MethodPut, s.key(name), nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("PutObject", name, resp)
+	}
+	return nil
+}
+
+func (s *s3Storage) RemoveAll(name string) error {
+	key := s.key(name)
+	keys := []string{key}
+	err := s.list(key+"/", "", func(result *s3ListResult) {
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		resp, err := s.do(http.MethodDelete, key, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return s3Error("DeleteObject", key, resp)
+		}
+	}
+	return nil
+}
+
+func (s *s3Storage) Location(name string) string {
+	return "s3://" + s.bucket + "/" + s.key(name)
+}
+
+// s3ListResult ListObjectsV2 的响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list 分页列出前缀下的对象，delimiter 为 / 时只列出直接子项
+func (s *s3Storage) list(prefix, delimiter string, fn func(*s3ListResult)) error {
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if delimiter != "" {
+			query["delimiter"] = delimiter
+		}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error("ListObjectsV2", prefix, resp)
+			resp.Body.Close()
+			return err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("invalid s3 list response: %v", err)
+		}
+
+		fn(&result)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do 发送签名后的请求，key 为空时请求存储桶本身
+func (s *s3Storage) do(method, key string, query map[string]string, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = strings.TrimSuffix("/"+s.bucket+objectPath, "/")
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3Query(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %v", err)
+	}
+	return resp, nil
+}
+
+// sign 按 AWS Signature V4 为请求签名，未配置密钥时不签名
+func (s *s3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("x-amz-content-sha256", payload)
+	req.Header.Set("x-amz-date", amzDate)
+	if s.accessKey == "" {
+		return
+	}
+
+	// 参与签名的请求头：Host 和全部 x-amz-* 头，名称小写并排序
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	date := amzDate[:8]
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按S3签名要求进行URI编码：只保留字母、数字和 -._~，路径中保留 /
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query 按参数名排序并编码查询参数，结果同时用作请求的查询串和签名中的规范查询串
+func s3Query(query map[string]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, s3Escape(name, true)+"="+s3Escape(query[name], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Error 将S3的错误响应转换为错误
+func s3Error(op, name string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3 %s %s: %s: %s", op, name, e.Code, e.Message)
+	}
+	return fmt.Errorf("s3 %s %s: %s", op, name, resp.Status)
+}
+
+// s3FileInfo 对象或公共前缀（目录）的信息
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *s3FileInfo) Name() string       { return i.name }
+func (i *s3FileInfo) Size() int64        { return i.size }
+func (i *s3FileInfo) ModTime() time.Time { return i.modTime }
+func (i *s3FileInfo) IsDir() bool        { return i.dir }
+func (i *s3FileInfo) Sys() interface{}   { return nil }
+
+func (i *s3FileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
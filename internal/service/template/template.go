@@ -1,11 +1,14 @@
 package template
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"log"
+	"path"
 	"strings"
+	"sync/atomic"
 
 	"office-export-server/internal/config"
 	"office-export-server/internal/model"
@@ -14,30 +17,72 @@ import (
 // TemplateService 模板服务接口
 type TemplateService interface {
 	LoadTemplate(templateID string, fileType string) ([]byte, error)
-	GetAllTemplates() ([]model.TemplateInfo, error)
+	GetAllTemplates(filter model.TemplateFilter) ([]model.TemplateInfo, error)
+	Reload() ([]string, error)
 	GetTemplatePath(templateID string, fileType string) (string, error)
+	LoadAsset(assetPath string) ([]byte, error)
+	AssetStamp(assetPath string) (string, error)
+	TemplateStamp(templateID string, fileType string) string
+	LoadSchema(templateID string, fileType string) ([]byte, error)
+	LoadSample(templateID string, fileType string) ([]byte, error)
+	LoadStyles(templateID string, fileType string) ([]byte, error)
+	LoadSharedStyles() ([]byte, error)
+	LoadMeta(templateID string, fileType string) (*model.TemplateMeta, error)
+	LoadThumbnail(templateID string, fileType string) ([]byte, string, error)
+	CreateTemplate(upload *model.TemplateUpload) (*model.TemplateVersion, error)
+	PublishVersion(upload *model.TemplateUpload) (*model.TemplateVersion, error)
+	DeleteTemplate(templateID string, fileType string) error
+	ListVersions(templateID string, fileType string) ([]model.TemplateVersion, error)
+	Pin(templateID string, fileType string, version int) (TemplateService, error)
 }
 
+// 与模板文件同目录存放的附属文件后缀，如 excel/quote.schema.json、excel/quote.sample.json
+const (
+	schemaSuffix = ".schema.json" // 数据JSON Schema
+	sampleSuffix = ".sample.json" // 预览用的示例数据
+	stylesSuffix = ".styles"      // 命名样式，YAML或JSON格式，如 excel/quote.styles.yaml
+	metaSuffix   = ".meta.yaml"   // 模板描述：名称、说明、标签、缩略图等
+)
+
+// sharedStylesName 所有模板共用的命名样式文件（位于模板根目录，不含扩展名）
+const sharedStylesName = "styles"
+
+// stylesExtensions 命名样式文件支持的扩展名，按顺序查找
+var stylesExtensions = []string{".yaml", ".yml", ".json"}
+
 // templateService 模板服务实现
 type templateService struct {
-	templateDir string
+	index *atomic.Pointer[templateIndex] // 模板存储和模板索引，固定版本的模板服务与原服务共享
+	pin   *templatePin                   // 固定版本的模板，为 nil 时全部使用当前版本
 }
 
-// NewTemplateService 创建模板服务实例
+// NewTemplateService 创建模板服务实例，扫描配置的模板存储建立模板索引
 func NewTemplateService() TemplateService {
-	return &templateService{
-		templateDir: config.GlobalConfig.Template.Path,
+	s := &templateService{index: new(atomic.Pointer[templateIndex])}
+	storage, err := NewStorage(config.Get())
+	if err != nil {
+		log.Printf("Failed to open template storage: %v", err)
+		s.index.Store(&templateIndex{storage: unavailableStorage{err}, err: err})
+		return s
 	}
+	if _, err := s.rebuild(storage); err != nil {
+		log.Printf("Failed to index templates in %s: %v", storage.Location(""), err)
+		s.index.Store(&templateIndex{storage: storage, err: err})
+	}
+	return s
 }
 
 // LoadTemplate 加载模板文件
 func (s *templateService) LoadTemplate(templateID string, fileType string) ([]byte, error) {
-	filePath, err := s.GetTemplatePath(templateID, fileType)
+	name, err := s.templateName(templateID, fileType)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := ioutil.ReadFile(filePath)
+	data, err := s.storage().ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("template not found: %s", s.storage().Location(name))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read template file: %v", err)
 	}
@@ -45,22 +90,43 @@ func (s *templateService) LoadTemplate(templateID string, fileType string) ([]by
 	return data, nil
 }
 
-// GetAllTemplates 获取所有模板信息
-func (s *templateService) GetAllTemplates() ([]model.TemplateInfo, error) {
-	var templates []model.TemplateInfo
+// GetAllTemplates 获取所有模板信息，按文件类型和标签筛选；模板信息取自模板索引，模板目录变化后由 Reload 更新
+func (s *templateService) GetAllTemplates(filter model.TemplateFilter) ([]model.TemplateInfo, error) {
+	index := s.index.Load()
+	if index.err != nil {
+		return nil, index.err
+	}
 
-	// 遍历所有模板类型目录
-	for _, fileType := range []string{"excel", "word", "pdf"} {
-		typeDir := filepath.Join(s.templateDir, fileType)
-		if _, err := os.Stat(typeDir); os.IsNotExist(err) {
+	templates := []model.TemplateInfo{}
+	for _, entry := range index.entries {
+		if filter.Type != "" && filter.Type != entry.info.Type {
 			continue
 		}
+		if !matchTags(entry.info.Tags, filter.Tags) {
+			continue
+		}
+		templates = append(templates, entry.info)
+	}
+	return templates, nil
+}
+
+// scanTemplates 扫描模板存储，读取全部模板的信息
+func (s *templateService) scanTemplates() ([]indexEntry, error) {
+	var entries []indexEntry
+
+	// 遍历所有模板类型目录，目录不存在时没有文件
+	for _, fileType := range []string{"excel", "word", "pdf"} {
+		extension, _ := templateExtension(fileType)
 
 		// 读取目录下所有文件
-		files, err := ioutil.ReadDir(typeDir)
+		files, err := s.storage().ReadDir(fileType)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read template directory: %v", err)
 		}
+		fileInfos := make(map[string]fs.FileInfo, len(files))
+		for _, file := range files {
+			fileInfos[file.Name()] = file
+		}
 
 		// 处理每个模板文件
 		for _, file := range files {
@@ -69,49 +135,166 @@ func (s *templateService) GetAllTemplates() ([]model.TemplateInfo, error) {
 			}
 
 			fileName := file.Name()
-			// 过滤掉Excel临时文件（以~$开头）
-			if strings.HasPrefix(fileName, "~") {
+			// 只处理该类型的模板文件，过滤掉Excel临时文件（以~$开头）、发布版本时的临时文件（以.开头）、
+			// 缩略图等资源文件，以及与PDF版式同为 .yaml 的命名样式和描述文件
+			if path.Ext(fileName) != extension || strings.HasPrefix(fileName, "~") || strings.HasPrefix(fileName, ".") ||
+				strings.HasSuffix(fileName, metaSuffix) || strings.HasSuffix(strings.TrimSuffix(fileName, path.Ext(fileName)), stylesSuffix) {
 				continue
 			}
-			templateID := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+			templateID := strings.TrimSuffix(fileName, path.Ext(fileName))
 
 			templateInfo := model.TemplateInfo{
 				ID:          templateID,
 				Name:        fileName,
 				Description: fmt.Sprintf("%s template", fileType),
 				Type:        fileType,
-				Path:        filepath.Join(typeDir, fileName),
+				Path:        s.storage().Location(path.Join(fileType, fileName)),
+			}
+			// 描述文件无效时只记录日志，模板照常列出；导出该模板时再返回错误
+			meta, err := s.LoadMeta(templateID, fileType)
+			if err != nil {
+				log.Printf("Ignoring meta of template %s/%s: %v", fileType, templateID, err)
+			} else if meta != nil {
+				applyMeta(&templateInfo, meta)
+			}
+
+			// 通过接口上传的模板使用当前版本的名称和说明
+			current, err := s.currentVersion(templateID, fileType)
+			if err != nil {
+				return nil, err
+			}
+			if current != nil {
+				templateInfo.Version = current.Version
+				if current.Name != "" {
+					templateInfo.Name = current.Name
+				}
+				if current.Description != "" {
+					templateInfo.Description = current.Description
+				}
 			}
 
-			templates = append(templates, templateInfo)
+			entries = append(entries, indexEntry{info: templateInfo, stamp: fileStamp(templateID, fileType, fileInfos)})
 		}
 	}
 
-	return templates, nil
+	return entries, nil
 }
 
-// GetTemplatePath 获取模板文件路径
+// GetTemplatePath 获取模板文件的位置：本地存储为文件路径，其他存储为 embed://、s3:// 等形式，只用于展示和日志
 func (s *templateService) GetTemplatePath(templateID string, fileType string) (string, error) {
+	name, err := s.templateName(templateID, fileType)
+	if err != nil {
+		return "", err
+	}
+
+	// 检查文件是否存在
+	if _, err := s.storage().Stat(name); errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("template not found: %s", s.storage().Location(name))
+	}
+
+	return s.storage().Location(name), nil
+}
+
+// templateName 模板文件在模板存储中的路径
+func (s *templateService) templateName(templateID string, fileType string) (string, error) {
 	// 根据文件类型确定文件扩展名
-	extension := ""
+	extension, err := templateExtension(fileType)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.fileDir(templateID, fileType), cleanName(templateID+extension)), nil
+}
+
+// templateExtension 模板文件类型对应的扩展名
+func templateExtension(fileType string) (string, error) {
 	switch fileType {
 	case "excel":
-		extension = ".xlsx"
+		return ".xlsx", nil
 	case "word":
-		extension = ".docx"
+		return ".docx", nil
 	case "pdf":
-		extension = ".pdf"
+		return ".yaml", nil
 	default:
 		return "", fmt.Errorf("unsupported file type: %s", fileType)
 	}
+}
 
-	// 构建模板文件路径
-	filePath := filepath.Join(s.templateDir, fileType, templateID+extension)
+// LoadAsset 读取模板目录下的资源文件（如图片），路径不允许跳出模板目录
+func (s *templateService) LoadAsset(assetPath string) ([]byte, error) {
+	data, err := s.storage().ReadFile(cleanName(assetPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset file: %v", err)
+	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("template not found: %s", filePath)
+	return data, nil
+}
+
+// AssetStamp 资源文件的大小和修改时间，用于判断资源是否变化；文件不存在时返回空字符串
+func (s *templateService) AssetStamp(assetPath string) (string, error) {
+	info, err := s.storage().Stat(cleanName(assetPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat asset file: %v", err)
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// LoadSchema 读取模板声明的数据JSON Schema，模板未声明时返回 nil
+func (s *templateService) LoadSchema(templateID string, fileType string) ([]byte, error) {
+	data, err := s.storage().ReadFile(path.Join(s.fileDir(templateID, fileType), cleanName(templateID+schemaSuffix)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %v", err)
+	}
+
+	return data, nil
+}
+
+// LoadSample 读取模板的示例数据，用于预览；没有 .sample.json 时使用描述文件中的示例数据
+func (s *templateService) LoadSample(templateID string, fileType string) ([]byte, error) {
+	data, err := s.storage().ReadFile(path.Join(s.fileDir(templateID, fileType), cleanName(templateID+sampleSuffix)))
+	if errors.Is(err, fs.ErrNotExist) {
+		meta, err := s.LoadMeta(templateID, fileType)
+		if err != nil {
+			return nil, err
+		}
+		if meta == nil || meta.Sample == nil {
+			return nil, fmt.Errorf("sample data not found for template: %s", templateID)
+		}
+		return json.Marshal(meta.Sample)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sample file: %v", err)
+	}
+
+	return data, nil
+}
 
-	return filePath, nil
+// LoadStyles 读取模板的命名样式文件（<template_id>.styles.yaml 或 .json），模板未定义时返回 nil
+func (s *templateService) LoadStyles(templateID string, fileType string) ([]byte, error) {
+	return s.loadStylesFile(path.Join(s.fileDir(templateID, fileType), cleanName(templateID+stylesSuffix)))
+}
+
+// LoadSharedStyles 读取模板根目录下所有模板共用的命名样式文件（styles.yaml 或 .json），不存在时返回 nil
+func (s *templateService) LoadSharedStyles() ([]byte, error) {
+	return s.loadStylesFile(sharedStylesName)
+}
+
+// loadStylesFile 按支持的扩展名依次查找并读取命名样式文件，baseName 不含扩展名
+func (s *templateService) loadStylesFile(baseName string) ([]byte, error) {
+	for _, ext := range stylesExtensions {
+		data, err := s.storage().ReadFile(baseName + ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read styles file: %v", err)
+		}
+		return data, nil
+	}
+	return nil, nil
 }
@@ -0,0 +1,62 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+)
+
+// writeTemplateFiles 将 files（相对模板目录的路径到内容）写入 root
+func writeTemplateFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInvalidMetaDoesNotHideTemplates(t *testing.T) {
+	root := t.TempDir()
+	writeTemplateFiles(t, root, map[string]string{
+		"excel/good.xlsx":      "xlsx",
+		"excel/good.meta.yaml": "name: 报价单\ntags: [报价]\n",
+		"excel/bad.xlsx":       "xlsx",
+		"excel/bad.meta.yaml":  "name: [未闭合\n",
+	})
+	useStorage(t, func(cfg *config.Config) {
+		cfg.Template.Storage = "fs"
+		cfg.Template.Path = root
+	})
+	svc := NewTemplateService()
+
+	list, err := svc.GetAllTemplates(model.TemplateFilter{})
+	if err != nil {
+		t.Fatalf("GetAllTemplates: %v", err)
+	}
+	names := map[string]string{}
+	for _, info := range list {
+		names[info.ID] = info.Name
+	}
+	if names["good"] != "报价单" || names["bad"] != "bad.xlsx" || len(names) != 2 {
+		t.Errorf("templates = %v, want good with meta name and bad without meta", names)
+	}
+	if _, err := svc.Reload(); err != nil {
+		t.Errorf("Reload: %v", err)
+	}
+
+	if _, err := svc.LoadMeta("bad", "excel"); err == nil || !strings.Contains(err.Error(), "excel/bad.meta.yaml") {
+		t.Errorf("LoadMeta(bad) = %v, want an error naming the meta file", err)
+	}
+	if meta, err := svc.LoadMeta("good", "excel"); err != nil || meta.Name != "报价单" {
+		t.Errorf("LoadMeta(good) = %+v, %v", meta, err)
+	}
+}
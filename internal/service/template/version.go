@@ -0,0 +1,478 @@
+package template
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"office-export-server/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 模板版本：每个版本是 <type>/.versions/<id>/<n>/ 下的一组文件（模板文件及其Schema、示例数据、命名样式、描述文件）和 version.json，
+// 发布后不再修改；发布新版本时把该版本的文件复制到 <type>/ 下作为当前版本，现有的读取逻辑无需感知版本。
+// version.json 最后写入，没有 version.json 的版本目录是未写完的版本，读取时忽略
+
+var (
+	// ErrTemplateExists 创建的模板已存在
+	ErrTemplateExists = errors.New("template already exists")
+	// ErrTemplateNotFound 模板不存在
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrVersionNotFound 模板版本不存在
+	ErrVersionNotFound = errors.New("template version not found")
+	// ErrInvalidTemplate 上传的模板文件或参数无效
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+const (
+	versionsDirName = ".versions"    // 版本目录，位于各类型模板目录下
+	versionMetaName = "version.json" // 版本信息文件
+)
+
+// templateIDRe 模板ID只允许字母、数字、下划线和连字符，避免路径穿越
+var templateIDRe = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// writeMu 串行执行模板的创建、发布和删除
+var writeMu sync.Mutex
+
+// templatePin 固定使用某个版本的模板
+type templatePin struct {
+	templateID string
+	fileType   string
+	dir        string // 版本目录在模板存储中的路径
+}
+
+// fileDir 模板文件及附属文件所在目录，固定版本的模板使用版本目录
+func (s *templateService) fileDir(templateID, fileType string) string {
+	if p := s.pin; p != nil && p.templateID == templateID && p.fileType == fileType {
+		return p.dir
+	}
+	return fileType
+}
+
+// versionsDir 模板的版本目录
+func (s *templateService) versionsDir(templateID, fileType string) string {
+	return path.Join(fileType, versionsDirName, templateID)
+}
+
+// versionDir 模板指定版本的目录
+func (s *templateService) versionDir(templateID, fileType string, version int) string {
+	return path.Join(s.versionsDir(templateID, fileType), strconv.Itoa(version))
+}
+
+// Pin 返回固定使用模板指定版本的模板服务，其他模板和共用资源仍使用当前版本
+func (s *templateService) Pin(templateID string, fileType string, version int) (TemplateService, error) {
+	if !templateIDRe.MatchString(templateID) {
+		return nil, ErrVersionNotFound
+	}
+	dir := s.versionDir(templateID, fileType, version)
+	if _, err := s.storage().Stat(path.Join(dir, versionMetaName)); err != nil {
+		return nil, ErrVersionNotFound
+	}
+	pinned := *s
+	pinned.pin = &templatePin{templateID: templateID, fileType: fileType, dir: dir}
+	return &pinned, nil
+}
+
+// ListVersions 列出模板的全部版本，按版本号升序；未通过接口上传过的模板没有版本
+func (s *templateService) ListVersions(templateID string, fileType string) ([]model.TemplateVersion, error) {
+	if err := checkTemplateRef(templateID, fileType); err != nil {
+		return nil, err
+	}
+	numbers, err := s.versionNumbers(templateID, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []model.TemplateVersion{}
+	for _, n := range numbers {
+		version, err := s.readVersion(templateID, fileType, n)
+		if err == ErrVersionNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+	if len(versions) == 0 {
+		if len(s.currentFiles(templateID, fileType)) == 0 {
+			return nil, ErrTemplateNotFound
+		}
+		return versions, nil
+	}
+	versions[len(versions)-1].Current = true
+	return versions, nil
+}
+
+// currentVersion 模板的当前版本（版本号最大的完整版本），没有版本时返回 nil
+func (s *templateService) currentVersion(templateID, fileType string) (*model.TemplateVersion, error) {
+	numbers, err := s.versionNumbers(templateID, fileType)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(numbers) - 1; i >= 0; i-- {
+		version, err := s.readVersion(templateID, fileType, numbers[i])
+		if err == ErrVersionNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		version.Current = true
+		return version, nil
+	}
+	return nil, nil
+}
+
+// CreateTemplate 上传新模板，作为版本1发布
+func (s *templateService) CreateTemplate(upload *model.TemplateUpload) (*model.TemplateVersion, error) {
+	if err := checkUpload(upload); err != nil {
+		return nil, err
+	}
+	if upload.Template == nil {
+		return nil, fmt.Errorf("%w: template file is required", ErrInvalidTemplate)
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	current, err := s.currentVersion(upload.ID, upload.Type)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil || len(s.currentFiles(upload.ID, upload.Type)) > 0 {
+		return nil, ErrTemplateExists
+	}
+	files := uploadFiles(upload, nil)
+	return s.release(upload.ID, upload.Type, 1, upload.Name, upload.Description, files)
+}
+
+// PublishVersion 发布模板的新版本，未上传的文件、名称和说明沿用上一版本
+// 手动放入模板目录、还没有版本的模板，先把现有文件保存为版本1
+func (s *templateService) PublishVersion(upload *model.TemplateUpload) (*model.TemplateVersion, error) {
+	if err := checkUpload(upload); err != nil {
+		return nil, err
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	numbers, err := s.versionNumbers(upload.ID, upload.Type)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.currentVersion(upload.ID, upload.Type)
+	if err != nil {
+		return nil, err
+	}
+	// 新版本号大于全部已有版本目录，不会写入未写完的版本目录
+	number := 1
+	if len(numbers) > 0 {
+		number = numbers[len(numbers)-1] + 1
+	}
+	var previousFiles map[string][]byte
+	if previous == nil {
+		previousFiles = s.currentFiles(upload.ID, upload.Type)
+		if len(previousFiles) == 0 {
+			return nil, ErrTemplateNotFound
+		}
+		if previous, err = s.writeVersion(upload.ID, upload.Type, number, "", "", previousFiles); err != nil {
+			return nil, err
+		}
+		number++
+	} else {
+		if previousFiles, err = s.readVersionFiles(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	name, description := upload.Name, upload.Description
+	if name == "" {
+		name = previous.Name
+	}
+	if description == "" {
+		description = previous.Description
+	}
+	files := uploadFiles(upload, previousFiles)
+	return s.release(upload.ID, upload.Type, number, name, description, files)
+}
+
+// DeleteTemplate 删除模板的当前文件和全部版本
+func (s *templateService) DeleteTemplate(templateID string, fileType string) error {
+	if err := checkTemplateRef(templateID, fileType); err != nil {
+		return err
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	current := s.currentFiles(templateID, fileType)
+	numbers, err := s.versionNumbers(templateID, fileType)
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 && len(numbers) == 0 {
+		return ErrTemplateNotFound
+	}
+
+	for name := range current {
+		if err := s.storage().RemoveAll(path.Join(fileType, name)); err != nil {
+			return fmt.Errorf("failed to remove template file: %w", err)
+		}
+	}
+	if err := s.storage().RemoveAll(s.versionsDir(templateID, fileType)); err != nil {
+		return fmt.Errorf("failed to remove template versions: %w", err)
+	}
+	s.refresh()
+	return nil
+}
+
+// release 保存新版本并将其发布为当前版本，同时更新模板索引
+func (s *templateService) release(templateID, fileType string, number int, name, description string, files map[string][]byte) (*model.TemplateVersion, error) {
+	version, err := s.writeVersion(templateID, fileType, number, name, description, files)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.publish(templateID, fileType, files); err != nil {
+		return nil, err
+	}
+	s.refresh()
+	version.Current = true
+	return version, nil
+}
+
+// writeVersion 将一组文件保存为版本：先写入版本的文件，最后写入 version.json，写入中途失败的版本会被忽略
+func (s *templateService) writeVersion(templateID, fileType string, number int, name, description string, files map[string][]byte) (*model.TemplateVersion, error) {
+	dir := s.versionDir(templateID, fileType, number)
+
+	ext, _ := templateExtension(fileType)
+	checksum := sha256.Sum256(files[templateID+ext])
+	version := &model.TemplateVersion{
+		ID:          templateID,
+		Type:        fileType,
+		Version:     number,
+		Name:        name,
+		Description: description,
+		Checksum:    hex.EncodeToString(checksum[:]),
+		CreatedAt:   time.Now(),
+	}
+	for fileName, data := range files {
+		if err := s.storage().WriteFile(path.Join(dir, fileName), data); err != nil {
+			return nil, fmt.Errorf("failed to write version file: %w", err)
+		}
+		version.Files = append(version.Files, fileName)
+	}
+	sort.Strings(version.Files)
+
+	meta, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode version info: %v", err)
+	}
+	if err := s.storage().WriteFile(path.Join(dir, versionMetaName), meta); err != nil {
+		return nil, fmt.Errorf("failed to save version %d: %w", number, err)
+	}
+	return version, nil
+}
+
+// publish 用版本的文件替换模板的当前文件，逐个文件原子替换，删除新版本中没有的附属文件
+func (s *templateService) publish(templateID, fileType string, files map[string][]byte) error {
+	for fileName, data := range files {
+		if err := s.storage().WriteFile(path.Join(fileType, fileName), data); err != nil {
+			return fmt.Errorf("failed to publish template file: %w", err)
+		}
+	}
+	for _, fileName := range templateFileNames(templateID, fileType) {
+		if _, ok := files[fileName]; ok {
+			continue
+		}
+		if _, err := s.storage().Stat(path.Join(fileType, fileName)); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := s.storage().RemoveAll(path.Join(fileType, fileName)); err != nil {
+			return fmt.Errorf("failed to remove template file: %w", err)
+		}
+	}
+	return nil
+}
+
+// versionNumbers 模板已有的版本目录的版本号，升序；其中可能有未写完的版本
+func (s *templateService) versionNumbers(templateID, fileType string) ([]int, error) {
+	entries, err := s.storage().ReadDir(s.versionsDir(templateID, fileType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version directory: %v", err)
+	}
+	var numbers []int
+	for _, entry := range entries {
+		if n, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// readVersion 读取版本信息
+func (s *templateService) readVersion(templateID, fileType string, number int) (*model.TemplateVersion, error) {
+	data, err := s.storage().ReadFile(path.Join(s.versionDir(templateID, fileType, number), versionMetaName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version info: %v", err)
+	}
+	var version model.TemplateVersion
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, fmt.Errorf("invalid version info of %s version %d: %v", templateID, number, err)
+	}
+	return &version, nil
+}
+
+// readVersionFiles 读取版本的全部文件
+func (s *templateService) readVersionFiles(version *model.TemplateVersion) (map[string][]byte, error) {
+	dir := s.versionDir(version.ID, version.Type, version.Version)
+	files := make(map[string][]byte, len(version.Files))
+	for _, fileName := range version.Files {
+		data, err := s.storage().ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version file: %v", err)
+		}
+		files[fileName] = data
+	}
+	return files, nil
+}
+
+// currentFiles 读取模板存储中模板的当前文件，模板不存在时返回空
+func (s *templateService) currentFiles(templateID, fileType string) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, fileName := range templateFileNames(templateID, fileType) {
+		if data, err := s.storage().ReadFile(path.Join(fileType, fileName)); err == nil {
+			files[fileName] = data
+		}
+	}
+	ext, _ := templateExtension(fileType)
+	if _, ok := files[templateID+ext]; !ok {
+		return nil
+	}
+	return files
+}
+
+// templateFileNames 模板文件及全部附属文件可能的文件名
+func templateFileNames(templateID, fileType string) []string {
+	ext, _ := templateExtension(fileType)
+	names := []string{templateID + ext, templateID + schemaSuffix, templateID + sampleSuffix, templateID + metaSuffix}
+	for _, stylesExt := range stylesExtensions {
+		names = append(names, templateID+stylesSuffix+stylesExt)
+	}
+	return names
+}
+
+// uploadFiles 合并上传的文件和上一版本的文件，上传的命名样式统一保存为 .styles.yaml（JSON也是有效的YAML）
+func uploadFiles(upload *model.TemplateUpload, previous map[string][]byte) map[string][]byte {
+	files := make(map[string][]byte)
+	for fileName, data := range previous {
+		files[fileName] = data
+	}
+	ext, _ := templateExtension(upload.Type)
+	if upload.Template != nil {
+		files[upload.ID+ext] = upload.Template
+	}
+	if upload.Schema != nil {
+		files[upload.ID+schemaSuffix] = upload.Schema
+	}
+	if upload.Sample != nil {
+		files[upload.ID+sampleSuffix] = upload.Sample
+	}
+	if upload.Meta != nil {
+		files[upload.ID+metaSuffix] = upload.Meta
+	}
+	if upload.Styles != nil {
+		for _, stylesExt := range stylesExtensions {
+			delete(files, upload.ID+stylesSuffix+stylesExt)
+		}
+		files[upload.ID+stylesSuffix+".yaml"] = upload.Styles
+	}
+	return files
+}
+
+// checkTemplateRef 检查模板类型和ID
+func checkTemplateRef(templateID, fileType string) error {
+	if _, err := templateExtension(fileType); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	if !templateIDRe.MatchString(templateID) {
+		return fmt.Errorf("%w: template id may only contain letters, digits, _ and -", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// checkUpload 检查上传的模板类型、ID和文件内容
+func checkUpload(upload *model.TemplateUpload) error {
+	if err := checkTemplateRef(upload.ID, upload.Type); err != nil {
+		return err
+	}
+	if upload.Template != nil {
+		if err := checkTemplateFile(upload.Type, upload.Template); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	if upload.Schema != nil && !json.Valid(upload.Schema) {
+		return fmt.Errorf("%w: schema is not valid JSON", ErrInvalidTemplate)
+	}
+	if upload.Sample != nil {
+		var sample map[string]interface{}
+		if err := json.Unmarshal(upload.Sample, &sample); err != nil {
+			return fmt.Errorf("%w: sample must be a JSON object: %v", ErrInvalidTemplate, err)
+		}
+	}
+	if upload.Styles != nil {
+		var styles map[string]model.StyleDef
+		if err := yaml.Unmarshal(upload.Styles, &styles); err != nil {
+			return fmt.Errorf("%w: invalid styles: %v", ErrInvalidTemplate, err)
+		}
+	}
+	if upload.Meta != nil {
+		if _, err := parseMeta(upload.Meta); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	return nil
+}
+
+// checkTemplateFile 检查模板文件格式：Excel和Word为Office文档（ZIP），PDF为YAML版式
+func checkTemplateFile(fileType string, data []byte) error {
+	if fileType == "pdf" {
+		var layout model.PDFLayout
+		if err := yaml.Unmarshal(data, &layout); err != nil {
+			return fmt.Errorf("invalid pdf layout: %v", err)
+		}
+		return nil
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("not an office document: %v", err)
+	}
+	part := "xl/workbook.xml"
+	if fileType == "word" {
+		part = "word/document.xml"
+	}
+	for _, file := range reader.File {
+		if file.Name == part {
+			return nil
+		}
+	}
+	return fmt.Errorf("not a %s template: missing %s", fileType, part)
+}
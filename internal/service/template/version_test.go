@@ -0,0 +1,179 @@
+package template
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"office-export-server/internal/config"
+	"office-export-server/internal/model"
+)
+
+// officeFile 只包含 part 的Office文档，content 用于区分不同版本
+func officeFile(t *testing.T, part, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// useVersionStorage 使用空的本地模板目录，返回目录路径和模板服务
+func useVersionStorage(t *testing.T) (string, TemplateService) {
+	t.Helper()
+	root := t.TempDir()
+	useStorage(t, func(cfg *config.Config) {
+		cfg.Template.Storage = "fs"
+		cfg.Template.Path = root
+	})
+	return root, NewTemplateService()
+}
+
+func TestTemplateVersions(t *testing.T) {
+	_, svc := useVersionStorage(t)
+	v1 := officeFile(t, "xl/workbook.xml", "v1")
+	v2 := officeFile(t, "xl/workbook.xml", "v2")
+
+	created, err := svc.CreateTemplate(&model.TemplateUpload{ID: "quote", Type: "excel", Name: "报价单", Template: v1, Schema: []byte(`{"type": "object"}`)})
+	if err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+	if created.Version != 1 || !created.Current || len(created.Files) != 2 {
+		t.Errorf("created = %+v, want current version 1 with template and schema", created)
+	}
+	if _, err := svc.CreateTemplate(&model.TemplateUpload{ID: "quote", Type: "excel", Template: v1}); !errors.Is(err, ErrTemplateExists) {
+		t.Errorf("CreateTemplate twice = %v, want ErrTemplateExists", err)
+	}
+	if _, err := svc.CreateTemplate(&model.TemplateUpload{ID: "../quote", Type: "excel", Template: v1}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("CreateTemplate with a path = %v, want ErrInvalidTemplate", err)
+	}
+	if _, err := svc.CreateTemplate(&model.TemplateUpload{ID: "bad", Type: "excel", Template: []byte("xlsx")}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("CreateTemplate with a non-office file = %v, want ErrInvalidTemplate", err)
+	}
+
+	// 新版本只上传模板文件，Schema和名称沿用版本1
+	published, err := svc.PublishVersion(&model.TemplateUpload{ID: "quote", Type: "excel", Template: v2})
+	if err != nil {
+		t.Fatalf("PublishVersion: %v", err)
+	}
+	if published.Version != 2 || published.Name != "报价单" || len(published.Files) != 2 {
+		t.Errorf("published = %+v, want version 2 keeping the name and schema", published)
+	}
+	if data, _ := svc.LoadTemplate("quote", "excel"); !bytes.Equal(data, v2) {
+		t.Error("current template is not version 2")
+	}
+	versions, err := svc.ListVersions("quote", "excel")
+	if err != nil || len(versions) != 2 || versions[0].Current || !versions[1].Current {
+		t.Errorf("ListVersions = %+v, %v; want versions 1 and 2 with 2 current", versions, err)
+	}
+	list, _ := svc.GetAllTemplates(model.TemplateFilter{})
+	if len(list) != 1 || list[0].Version != 2 || list[0].Name != "报价单" {
+		t.Errorf("GetAllTemplates = %+v, want quote at version 2", list)
+	}
+
+	pinned, err := svc.Pin("quote", "excel", 1)
+	if err != nil {
+		t.Fatalf("Pin(1): %v", err)
+	}
+	if data, _ := pinned.LoadTemplate("quote", "excel"); !bytes.Equal(data, v1) {
+		t.Error("pinned template is not version 1")
+	}
+	if schema, _ := pinned.LoadSchema("quote", "excel"); string(schema) != `{"type": "object"}` {
+		t.Errorf("pinned schema = %q", schema)
+	}
+	if _, err := svc.Pin("quote", "excel", 3); err != ErrVersionNotFound {
+		t.Errorf("Pin(3) = %v, want ErrVersionNotFound", err)
+	}
+	if _, err := svc.Pin("../quote", "excel", 1); err != ErrVersionNotFound {
+		t.Errorf("Pin with a path = %v, want ErrVersionNotFound", err)
+	}
+
+	if err := svc.DeleteTemplate("quote", "excel"); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if _, err := svc.ListVersions("quote", "excel"); err != ErrTemplateNotFound {
+		t.Errorf("ListVersions after delete = %v, want ErrTemplateNotFound", err)
+	}
+	if _, err := svc.Pin("quote", "excel", 1); err != ErrVersionNotFound {
+		t.Errorf("Pin after delete = %v, want ErrVersionNotFound", err)
+	}
+	if err := svc.DeleteTemplate("quote", "excel"); err != ErrTemplateNotFound {
+		t.Errorf("DeleteTemplate twice = %v, want ErrTemplateNotFound", err)
+	}
+	if list, _ := svc.GetAllTemplates(model.TemplateFilter{}); len(list) != 0 {
+		t.Errorf("GetAllTemplates after delete = %+v", list)
+	}
+}
+
+func TestPublishManualTemplate(t *testing.T) {
+	root, _ := useVersionStorage(t)
+	manual := officeFile(t, "word/document.xml", "manual")
+	writeTemplateFiles(t, root, map[string]string{"word/letter.docx": string(manual), "word/letter.sample.json": `{"title": "函"}`})
+	svc := NewTemplateService()
+
+	if versions, err := svc.ListVersions("letter", "word"); err != nil || len(versions) != 0 {
+		t.Errorf("ListVersions = %+v, %v; want no versions", versions, err)
+	}
+	published, err := svc.PublishVersion(&model.TemplateUpload{ID: "letter", Type: "word", Template: officeFile(t, "word/document.xml", "v2")})
+	if err != nil {
+		t.Fatalf("PublishVersion: %v", err)
+	}
+	if published.Version != 2 {
+		t.Errorf("published version = %d, want 2 after saving the manual files as version 1", published.Version)
+	}
+	pinned, err := svc.Pin("letter", "word", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := pinned.LoadTemplate("letter", "word"); !bytes.Equal(data, manual) {
+		t.Error("version 1 is not the manually placed template")
+	}
+	if sample, _ := svc.LoadSample("letter", "word"); string(sample) != `{"title": "函"}` {
+		t.Errorf("sample of version 2 = %q, want the manual sample kept", sample)
+	}
+}
+
+func TestInterruptedPublishIgnored(t *testing.T) {
+	root, svc := useVersionStorage(t)
+	v1 := officeFile(t, "xl/workbook.xml", "v1")
+	if _, err := svc.CreateTemplate(&model.TemplateUpload{ID: "quote", Type: "excel", Template: v1}); err != nil {
+		t.Fatal(err)
+	}
+	// 版本2的文件已写入，但 version.json 未写入
+	writeTemplateFiles(t, root, map[string]string{"excel/.versions/quote/2/quote.xlsx": "partial"})
+
+	versions, err := svc.ListVersions("quote", "excel")
+	if err != nil || len(versions) != 1 || versions[0].Version != 1 || !versions[0].Current {
+		t.Errorf("ListVersions = %+v, %v; want only version 1", versions, err)
+	}
+	if _, err := svc.Pin("quote", "excel", 2); err != ErrVersionNotFound {
+		t.Errorf("Pin(2) = %v, want ErrVersionNotFound", err)
+	}
+	if list, _ := svc.GetAllTemplates(model.TemplateFilter{}); len(list) != 1 || list[0].Version != 1 {
+		t.Errorf("GetAllTemplates = %+v, want version 1", list)
+	}
+
+	// 下一个版本不复用未写完的版本目录
+	published, err := svc.PublishVersion(&model.TemplateUpload{ID: "quote", Type: "excel", Template: officeFile(t, "xl/workbook.xml", "v3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.Version != 3 {
+		t.Errorf("published version = %d, want 3", published.Version)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "excel", ".versions", "quote", "2", "quote.xlsx")); err != nil || string(data) != "partial" {
+		t.Errorf("interrupted version files = %q, %v; want them left untouched", data, err)
+	}
+}
@@ -0,0 +1,9 @@
+// Package templates 内置的默认模板和命名样式，配置 template.storage: embed 时直接从程序中读取
+package templates
+
+import "embed"
+
+// FS 内置模板，目录结构与模板目录相同；fonts 目录不编译进程序
+//
+//go:embed excel word pdf styles.yaml
+var FS embed.FS
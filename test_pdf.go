@@ -7,16 +7,24 @@ import (
 
 	"office-export-server/internal/model"
 	"office-export-server/internal/service/export"
+	"office-export-server/internal/service/template"
 )
 
 func main() {
 	// 创建PDF导出服务
-	pdfService := export.NewPDFService()
+	pdfService := export.NewPDFService(template.NewTemplateService())
 
 	// 构建测试请求
 	req := &model.ExportRequest{
 		Data: map[string]interface{}{
 			"title": "全宅智能定制方案",
+			"brand": map[string]interface{}{
+				"name":   "ORVIBO欧瑞博",
+				"slogan": "5G时代全宅智能 就选欧瑞博",
+			},
+			"pricing": map[string]interface{}{
+				"service_fee_rate": 20,
+			},
 			"project": map[string]interface{}{
 				"name":            "全宅智能定制方案20260112",
 				"clientName":      "测试客户",